@@ -470,6 +470,12 @@ func (c *ReplicaConfig) toInternalReplicaConfigWithOriginConfig(
 		if c.Sink.AdvanceTimeoutInSec != nil {
 			res.Sink.AdvanceTimeoutInSec = util.AddressOf(*c.Sink.AdvanceTimeoutInSec)
 		}
+		if c.Sink.EmitRetryLimit != nil {
+			res.Sink.EmitRetryLimit = util.AddressOf(*c.Sink.EmitRetryLimit)
+		}
+		if c.Sink.NonSplitTxnSizeLimit != nil {
+			res.Sink.NonSplitTxnSizeLimit = util.AddressOf(*c.Sink.NonSplitTxnSizeLimit)
+		}
 
 	}
 	if c.Mounter != nil {
@@ -754,6 +760,12 @@ func ToAPIReplicaConfig(c *config.ReplicaConfig) *ReplicaConfig {
 		if cloned.Sink.AdvanceTimeoutInSec != nil {
 			res.Sink.AdvanceTimeoutInSec = util.AddressOf(*cloned.Sink.AdvanceTimeoutInSec)
 		}
+		if cloned.Sink.EmitRetryLimit != nil {
+			res.Sink.EmitRetryLimit = util.AddressOf(*cloned.Sink.EmitRetryLimit)
+		}
+		if cloned.Sink.NonSplitTxnSizeLimit != nil {
+			res.Sink.NonSplitTxnSizeLimit = util.AddressOf(*cloned.Sink.NonSplitTxnSizeLimit)
+		}
 	}
 	if cloned.Consistent != nil {
 		res.Consistent = &ConsistentConfig{
@@ -915,6 +927,8 @@ type SinkConfig struct {
 	MySQLConfig                      *MySQLConfig        `json:"mysql_config,omitempty"`
 	CloudStorageConfig               *CloudStorageConfig `json:"cloud_storage_config,omitempty"`
 	AdvanceTimeoutInSec              *uint               `json:"advance_timeout,omitempty"`
+	EmitRetryLimit                   *uint               `json:"emit_retry_limit,omitempty"`
+	NonSplitTxnSizeLimit             *uint64             `json:"non_split_txn_size_limit,omitempty"`
 }
 
 // CSVConfig denotes the csv config
@@ -118,8 +118,10 @@ func TestToAPIReplicaConfig(t *testing.T) {
 				Columns: []string{"a", "b"},
 			},
 		},
-		SchemaRegistry: util.AddressOf("bbb"),
-		TxnAtomicity:   util.AddressOf(config.AtomicityLevel("aa")),
+		SchemaRegistry:       util.AddressOf("bbb"),
+		TxnAtomicity:         util.AddressOf(config.AtomicityLevel("aa")),
+		EmitRetryLimit:       util.AddressOf(uint(5)),
+		NonSplitTxnSizeLimit: util.AddressOf(uint64(1024)),
 	}
 	cfg.Consistent = &config.ConsistentConfig{
 		Level:             "1",
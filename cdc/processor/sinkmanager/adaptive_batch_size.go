@@ -0,0 +1,73 @@
+// Copyright 2023 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinkmanager
+
+import "time"
+
+// adaptiveBatchSize tunes how many bytes are buffered by a sink task before
+// emitting them to the table sink, based on the latency of recent emits.
+// Larger batches improve the throughput if the downstream is fast, and smaller
+// batches reduce the tail latency if it's slow.
+// It's only used by one worker, so it's unnecessary to be thread-safe.
+type adaptiveBatchSize struct {
+	minSize uint64
+	maxSize uint64
+	// targetLatency is the expected latency of one emit. The batch size is
+	// halved if an emit is slower than it, and doubled if an emit is faster
+	// than half of it.
+	targetLatency time.Duration
+
+	size uint64
+}
+
+func newAdaptiveBatchSize(minSize, maxSize uint64, targetLatency time.Duration) *adaptiveBatchSize {
+	b := &adaptiveBatchSize{
+		minSize:       minSize,
+		maxSize:       maxSize,
+		targetLatency: targetLatency,
+	}
+	b.size = b.clamp(maxUpdateIntervalSize)
+	return b
+}
+
+// get returns the current batch size. A nil adaptiveBatchSize means the batch
+// size is fixed to maxUpdateIntervalSize.
+func (b *adaptiveBatchSize) get() uint64 {
+	if b == nil {
+		return maxUpdateIntervalSize
+	}
+	return b.size
+}
+
+// observe adjusts the batch size with the latency of an emit.
+func (b *adaptiveBatchSize) observe(latency time.Duration) {
+	if b == nil {
+		return
+	}
+	if latency > b.targetLatency {
+		b.size = b.clamp(b.size / 2)
+	} else if latency < b.targetLatency/2 {
+		b.size = b.clamp(b.size * 2)
+	}
+}
+
+func (b *adaptiveBatchSize) clamp(size uint64) uint64 {
+	if size < b.minSize {
+		return b.minSize
+	}
+	if size > b.maxSize {
+		return b.maxSize
+	}
+	return size
+}
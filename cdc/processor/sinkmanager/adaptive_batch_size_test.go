@@ -0,0 +1,49 @@
+// Copyright 2023 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinkmanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdaptiveBatchSize(t *testing.T) {
+	t.Parallel()
+
+	b := newAdaptiveBatchSize(1024, 16*1024, 100*time.Millisecond)
+	require.Equal(t, uint64(16*1024), b.get())
+
+	// Slow emits shrink the batch size until the lower bound.
+	for _, expected := range []uint64{8 * 1024, 4 * 1024, 2 * 1024, 1024, 1024} {
+		b.observe(200 * time.Millisecond)
+		require.Equal(t, expected, b.get())
+	}
+
+	// Emits close to the target latency keep the batch size.
+	b.observe(80 * time.Millisecond)
+	require.Equal(t, uint64(1024), b.get())
+
+	// Fast emits grow the batch size until the upper bound.
+	for _, expected := range []uint64{2 * 1024, 4 * 1024, 8 * 1024, 16 * 1024, 16 * 1024} {
+		b.observe(10 * time.Millisecond)
+		require.Equal(t, expected, b.get())
+	}
+
+	// A nil adaptiveBatchSize is fixed to maxUpdateIntervalSize.
+	var fixed *adaptiveBatchSize
+	fixed.observe(time.Hour)
+	require.Equal(t, maxUpdateIntervalSize, fixed.get())
+}
@@ -0,0 +1,279 @@
+// Copyright 2023 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinkmanager
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/processor/memquota"
+	"github.com/pingcap/tiflow/cdc/processor/tablepb"
+	"github.com/pingcap/tiflow/pkg/spanz"
+)
+
+// fairMemQuota shares one MemQuota among concurrent tables. Every table has a
+// soft cap within the shared quota: once a table holds more memory than the
+// cap, it can't try or block acquire more memory until some of its memory is
+// released, so that a greedy table can't monopolize the shared quota. The cap
+// is only enforced while other tables hold or wait for memory, so a single busy
+// table can still use the whole quota.
+// ForceAcquire is never rejected because it's required for correctness, but
+// it's still counted to the table. Zero softCap means no cap.
+type fairMemQuota struct {
+	*memquota.MemQuota
+	softCap uint64
+
+	// mu protects closed, tables and activeTables.
+	mu     sync.Mutex
+	cond   *sync.Cond
+	closed bool
+	// tables is the memory held by each table, including the memory recorded
+	// but not released yet.
+	tables *spanz.HashMap[*fairTableMemory]
+	// activeTables is the count of tables which hold or wait for memory.
+	activeTables int
+}
+
+type fairTableMemory struct {
+	used uint64
+	// waiting is the count of acquirers of the table blocked by the soft cap
+	// or the shared quota.
+	waiting int
+	records []*memquota.MemConsumeRecord
+}
+
+func (t *fairTableMemory) active() bool {
+	return t.used > 0 || t.waiting > 0
+}
+
+var _ TableMemQuota = (*fairMemQuota)(nil)
+
+func newFairMemQuota(quota *memquota.MemQuota, softCap uint64) *fairMemQuota {
+	f := &fairMemQuota{
+		MemQuota: quota,
+		softCap:  softCap,
+		tables:   spanz.NewHashMap[*fairTableMemory](),
+	}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+// ForTable implements TableMemQuota.
+func (f *fairMemQuota) ForTable(span tablepb.Span, initial uint64) MemQuota {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	table := f.getTableLocked(span)
+	f.updateLocked(span, table, func() { table.used += initial })
+	return &fairTableMemQuota{fairMemQuota: f, span: span}
+}
+
+func (f *fairMemQuota) getTableLocked(span tablepb.Span) *fairTableMemory {
+	table, ok := f.tables.Get(span)
+	if !ok {
+		table = &fairTableMemory{}
+		f.tables.ReplaceOrInsert(span, table)
+	}
+	return table
+}
+
+// updateLocked calls fn to update the table, and maintains activeTables.
+// Tables which have been deleted are not counted any more.
+func (f *fairMemQuota) updateLocked(span tablepb.Span, table *fairTableMemory, fn func()) {
+	wasActive := table.active()
+	fn()
+	if cur, ok := f.tables.Get(span); !ok || cur != table || wasActive == table.active() {
+		return
+	}
+	if wasActive {
+		f.activeTables--
+	} else {
+		f.activeTables++
+	}
+}
+
+// addUsed adds nBytes to the memory held by the table.
+func (f *fairMemQuota) addUsed(span tablepb.Span, nBytes uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	table := f.getTableLocked(span)
+	f.updateLocked(span, table, func() { table.used += nBytes })
+}
+
+// subUsed subtracts nBytes from the memory held by the table.
+func (f *fairMemQuota) subUsed(span tablepb.Span, nBytes uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subUsedLocked(span, f.getTableLocked(span), nBytes)
+}
+
+func (f *fairMemQuota) subUsedLocked(span tablepb.Span, table *fairTableMemory, nBytes uint64) {
+	f.updateLocked(span, table, func() {
+		if table.used < nBytes {
+			table.used = 0
+		} else {
+			table.used -= nBytes
+		}
+	})
+	f.cond.Broadcast()
+}
+
+// exceedSoftCapLocked returns true if the table can't acquire nBytes more.
+// A table holding nothing can always acquire, otherwise it never progresses
+// if nBytes is larger than the cap. The cap is ignored if no other table
+// holds or waits for memory.
+func (f *fairMemQuota) exceedSoftCapLocked(table *fairTableMemory, nBytes uint64) bool {
+	if f.softCap == 0 || table.used == 0 || table.used+nBytes <= f.softCap {
+		return false
+	}
+	others := f.activeTables
+	if table.active() {
+		others--
+	}
+	return others > 0
+}
+
+// Record implements MemQuota.
+func (f *fairMemQuota) Record(span tablepb.Span, resolved model.ResolvedTs, nBytes uint64) {
+	f.MemQuota.Record(span, resolved, nBytes)
+	if nBytes == 0 {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	table := f.getTableLocked(span)
+	table.records = append(table.records, &memquota.MemConsumeRecord{
+		ResolvedTs: resolved,
+		Size:       nBytes,
+	})
+}
+
+// Release implements TableMemQuota.
+func (f *fairMemQuota) Release(span tablepb.Span, resolved model.ResolvedTs) {
+	f.MemQuota.Release(span, resolved)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	table, ok := f.tables.Get(span)
+	if !ok {
+		return
+	}
+	i := sort.Search(len(table.records), func(i int) bool {
+		return table.records[i].ResolvedTs.Greater(resolved)
+	})
+	var toRelease uint64
+	for j := 0; j < i; j++ {
+		toRelease += table.records[j].Size
+	}
+	table.records = table.records[i:]
+	f.subUsedLocked(span, table, toRelease)
+}
+
+// ClearTable implements MemQuota.
+func (f *fairMemQuota) ClearTable(span tablepb.Span) uint64 {
+	cleaned := f.MemQuota.ClearTable(span)
+	f.deleteTable(span)
+	return cleaned
+}
+
+// RemoveTable implements TableMemQuota.
+func (f *fairMemQuota) RemoveTable(span tablepb.Span) uint64 {
+	cleaned := f.MemQuota.RemoveTable(span)
+	f.deleteTable(span)
+	return cleaned
+}
+
+// deleteTable forgets the memory held by the table, and wakes up acquirers
+// of the table blocked by the soft cap.
+func (f *fairMemQuota) deleteTable(span tablepb.Span) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if table, ok := f.tables.Get(span); ok && table.active() {
+		f.activeTables--
+	}
+	f.tables.Delete(span)
+	f.cond.Broadcast()
+}
+
+// Close implements MemQuota.
+func (f *fairMemQuota) Close() {
+	f.mu.Lock()
+	f.closed = true
+	f.cond.Broadcast()
+	f.mu.Unlock()
+	f.MemQuota.Close()
+}
+
+// fairTableMemQuota acquires memory from a fairMemQuota for one table.
+type fairTableMemQuota struct {
+	*fairMemQuota
+	span tablepb.Span
+}
+
+// TryAcquire implements MemQuota.
+func (t *fairTableMemQuota) TryAcquire(nBytes uint64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	table := t.getTableLocked(t.span)
+	if t.exceedSoftCapLocked(table, nBytes) || !t.MemQuota.TryAcquire(nBytes) {
+		return false
+	}
+	t.updateLocked(t.span, table, func() { table.used += nBytes })
+	return true
+}
+
+// ForceAcquire implements MemQuota.
+func (t *fairTableMemQuota) ForceAcquire(nBytes uint64) {
+	t.MemQuota.ForceAcquire(nBytes)
+	t.addUsed(t.span, nBytes)
+}
+
+// BlockAcquire implements MemQuota.
+func (t *fairTableMemQuota) BlockAcquire(nBytes uint64) error {
+	t.mu.Lock()
+	for !t.closed {
+		table := t.getTableLocked(t.span)
+		if !t.exceedSoftCapLocked(table, nBytes) {
+			break
+		}
+		t.updateLocked(t.span, table, func() { table.waiting++ })
+		t.cond.Wait()
+		t.updateLocked(t.span, table, func() { table.waiting-- })
+	}
+	if t.closed {
+		t.mu.Unlock()
+		return context.Canceled
+	}
+	// Waiting for the shared quota also makes the table compete with others.
+	table := t.getTableLocked(t.span)
+	t.updateLocked(t.span, table, func() { table.waiting++ })
+	t.mu.Unlock()
+
+	err := t.MemQuota.BlockAcquire(nBytes)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.updateLocked(t.span, table, func() { table.waiting-- })
+	if err != nil {
+		return err
+	}
+	table = t.getTableLocked(t.span)
+	t.updateLocked(t.span, table, func() { table.used += nBytes })
+	return nil
+}
+
+// Refund implements MemQuota.
+func (t *fairTableMemQuota) Refund(nBytes uint64) {
+	t.MemQuota.Refund(nBytes)
+	t.subUsed(t.span, nBytes)
+}
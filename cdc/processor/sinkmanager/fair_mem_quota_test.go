@@ -0,0 +1,193 @@
+// Copyright 2023 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinkmanager
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/processor/memquota"
+	"github.com/pingcap/tiflow/cdc/processor/tablepb"
+	"github.com/pingcap/tiflow/pkg/spanz"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFairMemQuotaGreedyTableDoesNotStarveOthers(t *testing.T) {
+	t.Parallel()
+
+	quota := memquota.NewMemQuota(model.DefaultChangeFeedID("fair-mem-quota"), 1000, "sink")
+	fair := newFairMemQuota(quota, 300)
+	defer fair.Close()
+
+	// The greedy table force acquires a large transaction, then keeps trying
+	// to acquire more memory without releasing any, like a table whose sink
+	// is stuck.
+	greedySpan := spanz.TableIDToComparableSpan(1)
+	quota.AddTable(greedySpan)
+	greedy := fair.ForTable(greedySpan, 0)
+	greedy.ForceAcquire(400)
+	greedy.Record(greedySpan, model.NewResolvedTs(1), 400)
+
+	// Modest tables hold a little memory for their tasks, like the memory
+	// acquired by the sink manager before a task starts.
+	modestSpans := make([]tablepb.Span, 0, 4)
+	modestQuotas := make([]MemQuota, 0, 4)
+	for id := int64(2); id <= 5; id++ {
+		span := spanz.TableIDToComparableSpan(id)
+		quota.AddTable(span)
+		modest := fair.ForTable(span, 0)
+		modest.ForceAcquire(50)
+		modestSpans = append(modestSpans, span)
+		modestQuotas = append(modestQuotas, modest)
+	}
+
+	stop := make(chan struct{})
+	var greedyWg sync.WaitGroup
+	greedyWg.Add(1)
+	go func() {
+		defer greedyWg.Done()
+		for i := uint64(2); ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if greedy.TryAcquire(100) {
+				greedy.Record(greedySpan, model.NewResolvedTs(i), 100)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	// Modest tables acquire a little memory and release it soon.
+	var modestWg sync.WaitGroup
+	for i := range modestSpans {
+		span, modest := modestSpans[i], modestQuotas[i]
+		modestWg.Add(1)
+		go func() {
+			defer modestWg.Done()
+			for i := uint64(1); i <= 20; i++ {
+				require.NoError(t, modest.BlockAcquire(100))
+				modest.Record(span, model.NewResolvedTs(i), 100)
+				fair.Release(span, model.NewResolvedTs(i))
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		modestWg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		require.FailNow(t, "modest tables are starved by the greedy table")
+	}
+	close(stop)
+	greedyWg.Wait()
+
+	// The greedy table can't acquire more memory beyond its soft cap while
+	// other tables hold memory.
+	require.Equal(t, uint64(600), quota.GetUsedBytes())
+	require.False(t, greedy.TryAcquire(100))
+
+	// A blocked acquire of the greedy table is woken up once its memory is released.
+	acquired := make(chan error, 1)
+	go func() { acquired <- greedy.BlockAcquire(100) }()
+	select {
+	case <-acquired:
+		require.FailNow(t, "the greedy table should be blocked")
+	case <-time.After(50 * time.Millisecond):
+	}
+	fair.Release(greedySpan, model.NewResolvedTs(1))
+	require.NoError(t, <-acquired)
+	require.Equal(t, uint64(300), quota.GetUsedBytes())
+}
+
+func TestFairMemQuotaSingleTableUsesWholeQuota(t *testing.T) {
+	t.Parallel()
+
+	quota := memquota.NewMemQuota(model.DefaultChangeFeedID("fair-mem-quota-single"), 1000, "sink")
+	fair := newFairMemQuota(quota, 500)
+	defer fair.Close()
+
+	span := spanz.TableIDToComparableSpan(1)
+	fair.AddTable(span)
+	table := fair.ForTable(span, 0)
+	for i := uint64(1); i <= 5; i++ {
+		require.True(t, table.TryAcquire(100))
+		table.Record(span, model.NewResolvedTs(i), 100)
+	}
+	for i := uint64(6); i <= 10; i++ {
+		require.NoError(t, table.BlockAcquire(100))
+		table.Record(span, model.NewResolvedTs(i), 100)
+	}
+	require.Equal(t, uint64(1000), fair.GetUsedBytes())
+	require.False(t, table.TryAcquire(100))
+
+	// The cap is enforced once another table needs memory.
+	fair.Release(span, model.NewResolvedTs(2))
+	otherSpan := spanz.TableIDToComparableSpan(2)
+	fair.AddTable(otherSpan)
+	other := fair.ForTable(otherSpan, 0)
+	require.True(t, other.TryAcquire(100))
+	require.False(t, table.TryAcquire(100))
+	require.True(t, other.TryAcquire(100))
+}
+
+func TestFairMemQuotaCloseWakesBlockedAcquire(t *testing.T) {
+	t.Parallel()
+
+	quota := memquota.NewMemQuota(model.DefaultChangeFeedID("fair-mem-quota-close"), 1000, "sink")
+	fair := newFairMemQuota(quota, 100)
+
+	span := spanz.TableIDToComparableSpan(1)
+	table := fair.ForTable(span, 100)
+	// Another table holds memory, so the soft cap is enforced.
+	fair.ForTable(spanz.TableIDToComparableSpan(2), 100)
+	acquired := make(chan error, 1)
+	go func() { acquired <- table.BlockAcquire(100) }()
+	fair.Close()
+	require.ErrorIs(t, <-acquired, context.Canceled)
+}
+
+func TestFairMemQuotaRemoveTableReleasesSoftCap(t *testing.T) {
+	t.Parallel()
+
+	quota := memquota.NewMemQuota(model.DefaultChangeFeedID("fair-mem-quota-remove"), 1000, "sink")
+	fair := newFairMemQuota(quota, 100)
+	defer fair.Close()
+
+	span := spanz.TableIDToComparableSpan(1)
+	fair.AddTable(span)
+	table := fair.ForTable(span, 0)
+	table.ForceAcquire(100)
+	table.Record(span, model.NewResolvedTs(1), 100)
+	// Another table holds memory, so the soft cap is enforced.
+	otherSpan := spanz.TableIDToComparableSpan(2)
+	fair.AddTable(otherSpan)
+	fair.ForTable(otherSpan, 0).ForceAcquire(100)
+	require.False(t, table.TryAcquire(100))
+
+	// Removing the table forgets its memory, so a blocked acquire is woken up.
+	acquired := make(chan error, 1)
+	go func() { acquired <- table.BlockAcquire(100) }()
+	require.Equal(t, uint64(100), fair.RemoveTable(span))
+	require.NoError(t, <-acquired)
+	require.Equal(t, uint64(200), fair.GetUsedBytes())
+}
@@ -59,6 +59,9 @@ type TableStats struct {
 	CheckpointTs model.Ts
 	ResolvedTs   model.Ts
 	BarrierTs    model.Ts
+	// LastEmittedCommitTs is the commit ts of the last event emitted to the
+	// table sink, it can be used to calculate the lag of the table.
+	LastEmittedCommitTs model.Ts
 }
 
 // SinkManager is the implementation of SinkManager.
@@ -105,7 +108,8 @@ type SinkManager struct {
 	sinkTaskChan        chan *sinkTask
 	sinkWorkerAvailable chan struct{}
 	// sinkMemQuota is used to control the total memory usage of the table sink.
-	sinkMemQuota *memquota.MemQuota
+	// It's shared by tables fairly.
+	sinkMemQuota TableMemQuota
 	sinkRetry    *retry.ErrorRetry
 	// redoWorkers used to pull data from source manager.
 	redoWorkers []*redoWorker
@@ -165,12 +169,12 @@ func New(
 		m.redoWorkerAvailable = make(chan struct{}, 1)
 
 		// Use 3/4 memory quota as redo quota, and 1/2 again for redo cache.
-		m.sinkMemQuota = memquota.NewMemQuota(changefeedID, changefeedInfo.Config.MemoryQuota/4*1, "sink")
+		m.sinkMemQuota = newSinkMemQuota(changefeedID, changefeedInfo.Config.MemoryQuota/4*1)
 		redoQuota := changefeedInfo.Config.MemoryQuota / 4 * 3
 		m.redoMemQuota = memquota.NewMemQuota(changefeedID, redoQuota, "redo")
 		m.eventCache = newRedoEventCache(changefeedID, redoQuota/2*1)
 	} else {
-		m.sinkMemQuota = memquota.NewMemQuota(changefeedID, changefeedInfo.Config.MemoryQuota, "sink")
+		m.sinkMemQuota = newSinkMemQuota(changefeedID, changefeedInfo.Config.MemoryQuota)
 		m.redoMemQuota = memquota.NewMemQuota(changefeedID, 0, "redo")
 	}
 
@@ -179,6 +183,13 @@ func New(
 	return m
 }
 
+// newSinkMemQuota creates the memory quota of table sinks. While other tables
+// hold or wait for memory, a table can hold at most tableMemSoftCapRatio of it.
+func newSinkMemQuota(changefeedID model.ChangeFeedID, totalBytes uint64) TableMemQuota {
+	quota := memquota.NewMemQuota(changefeedID, totalBytes, "sink")
+	return newFairMemQuota(quota, uint64(float64(totalBytes)*tableMemSoftCapRatio))
+}
+
 // Run implements util.Runnable.
 // When it returns, all sub-goroutines should be closed.
 func (m *SinkManager) Run(ctx context.Context, warnings ...chan<- error) (err error) {
@@ -392,10 +403,16 @@ func (m *SinkManager) putSinkFactoryError(err error, version uint64) (success bo
 }
 
 func (m *SinkManager) startSinkWorkers(ctx context.Context, eg *errgroup.Group, splitTxn bool) {
+	emitRetryLimit := config.DefaultEmitRetryLimit
+	if m.changefeedInfo.Config.Sink.EmitRetryLimit != nil {
+		emitRetryLimit = *m.changefeedInfo.Config.Sink.EmitRetryLimit
+	}
 	for i := 0; i < sinkWorkerNum; i++ {
 		w := newSinkWorker(m.changefeedID, m.sourceManager,
 			m.sinkMemQuota, m.redoMemQuota,
 			m.eventCache, splitTxn)
+		w.emitRetryLimit = emitRetryLimit
+		w.maxNonSplitTxnSize = util.GetOrZero(m.changefeedInfo.Config.Sink.NonSplitTxnSizeLimit)
 		m.sinkWorkers = append(m.sinkWorkers, w)
 		eg.Go(func() error { return w.handleTasks(ctx, m.sinkTaskChan) })
 	}
@@ -478,7 +495,7 @@ func (m *SinkManager) getUpperBound(tableSinkUpperBoundTs model.Ts) sorter.Posit
 		// schemaTs == math.MaxUint64 means it's in tests.
 		tableSinkUpperBoundTs = schemaTs + 1
 	}
-	return sorter.Position{StartTs: tableSinkUpperBoundTs - 1, CommitTs: tableSinkUpperBoundTs}
+	return sorter.GenCommitFence(tableSinkUpperBoundTs)
 }
 
 // generateSinkTasks generates tasks to fetch data from the source manager.
@@ -573,8 +590,9 @@ func (m *SinkManager) generateSinkTasks(ctx context.Context) error {
 				continue
 			}
 
-			// The table has no available progress.
-			if lowerBound.Compare(upperBound) >= 0 {
+			// The table has no available progress. A dropped table still needs
+			// a task to close its table sink.
+			if lowerBound.Compare(upperBound) >= 0 && !tableSink.dropped.Load() {
 				m.sinkProgressHeap.push(slowestTableProgress)
 				continue
 			}
@@ -595,7 +613,8 @@ func (m *SinkManager) generateSinkTasks(ctx context.Context) error {
 				lowerBound:    lowerBound,
 				getUpperBound: m.getUpperBound,
 				tableSink:     tableSink,
-				callback: func(lastWrittenPos sorter.Position) {
+				callback: func(lastWrittenPos sorter.Position, lastCommitTs model.Ts) {
+					tableSink.updateLastEmittedCommitTs(lastCommitTs)
 					p := &progress{
 						span:              tableSink.span,
 						nextLowerBoundPos: lastWrittenPos.Next(),
@@ -610,6 +629,7 @@ func (m *SinkManager) generateSinkTasks(ctx context.Context) error {
 				isCanceled: func() bool {
 					return tableSink.getState() != tablepb.TableStateReplicating
 				},
+				tableRemoved: tableSink.dropped.Load(),
 			}
 			select {
 			case <-ctx.Done():
@@ -727,7 +747,7 @@ func (m *SinkManager) generateRedoTasks(ctx context.Context) error {
 				lowerBound:    lowerBound,
 				getUpperBound: m.getUpperBound,
 				tableSink:     tableSink,
-				callback: func(lastWrittenPos sorter.Position) {
+				callback: func(lastWrittenPos sorter.Position, _ model.Ts) {
 					p := &progress{
 						span:              tableSink.span,
 						nextLowerBoundPos: lastWrittenPos.Next(),
@@ -892,6 +912,26 @@ func (m *SinkManager) StartTable(span tablepb.Span, startTs model.Ts) error {
 	return nil
 }
 
+// MarkTableDropped marks the table as dropped in upstream. The following sink
+// task of the table emits all its events and a terminal resolved ts, then
+// closes the table sink. It should be called after all events of the table
+// are received by the sorter. The table still needs to be stopped and removed.
+func (m *SinkManager) MarkTableDropped(span tablepb.Span) {
+	tableSink, ok := m.tableSinks.Load(span)
+	if !ok {
+		log.Warn("Table sink not found when marking table dropped",
+			zap.String("namespace", m.changefeedID.Namespace),
+			zap.String("changefeed", m.changefeedID.ID),
+			zap.Stringer("span", &span))
+		return
+	}
+	tableSink.(*tableSinkWrapper).dropped.Store(true)
+	log.Info("Table sink is marked as dropped",
+		zap.String("namespace", m.changefeedID.Namespace),
+		zap.String("changefeed", m.changefeedID.ID),
+		zap.Stringer("span", &span))
+}
+
 // AsyncStopTable sets the table(TableSink) state to stopped.
 func (m *SinkManager) AsyncStopTable(span tablepb.Span) bool {
 	tableSink, ok := m.tableSinks.Load(span)
@@ -937,6 +977,12 @@ func (m *SinkManager) RemoveTable(span tablepb.Span) {
 	if m.eventCache != nil {
 		m.eventCache.removeTable(span)
 	}
+	TableSinkBacklogEventCount.DeleteLabelValues(m.changefeedID.Namespace, m.changefeedID.ID, span.String())
+	TableSinkConsecutiveForceAcquireCount.DeleteLabelValues(m.changefeedID.Namespace, m.changefeedID.ID, span.String())
+	TableSinkTaskPeakMemory.DeleteLabelValues(m.changefeedID.Namespace, m.changefeedID.ID, span.String())
+	TableSinkEventTypeCount.DeletePartialMatch(prometheus.Labels{
+		"namespace": m.changefeedID.Namespace, "changefeed": m.changefeedID.ID, "span": span.String(),
+	})
 }
 
 // GetAllCurrentTableSpans returns all spans in the sinkManager.
@@ -1038,9 +1084,10 @@ func (m *SinkManager) GetTableStats(span tablepb.Span) TableStats {
 			zap.Any("checkpointTs", checkpointTs))
 	}
 	return TableStats{
-		CheckpointTs: checkpointTs.ResolvedMark(),
-		ResolvedTs:   resolvedTs,
-		BarrierTs:    tableSink.barrierTs.Load(),
+		CheckpointTs:        checkpointTs.ResolvedMark(),
+		ResolvedTs:          resolvedTs,
+		BarrierTs:           tableSink.barrierTs.Load(),
+		LastEmittedCommitTs: tableSink.lastEmittedCommitTs.Load(),
 	}
 }
 
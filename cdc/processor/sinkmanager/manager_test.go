@@ -173,6 +173,44 @@ func TestRemoveTable(t *testing.T) {
 	require.Equal(t, uint64(0), manager.sinkMemQuota.GetUsedBytes(), "After remove table, the memory usage should be 0.")
 }
 
+func TestMarkTableDropped(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changefeedInfo := getChangefeedInfo()
+	manager, _, e := CreateManagerWithMemEngine(t, ctx, model.DefaultChangeFeedID("1"),
+		changefeedInfo, make(chan error, 1))
+	defer func() {
+		cancel()
+		manager.Close()
+	}()
+
+	span := spanz.TableIDToComparableSpan(1)
+	manager.AddTable(span, 1, 100)
+	err := manager.StartTable(span, 0)
+	require.NoError(t, err)
+	addTableAndAddEventsToSortEngine(t, e, span)
+	manager.UpdateBarrierTs(4, nil)
+	manager.UpdateReceivedSorterResolvedTs(span, 5)
+	manager.schemaStorage.AdvanceResolvedTs(5)
+	manager.MarkTableDropped(span)
+
+	// The sink task of the dropped table closes the table sink after all
+	// events are emitted.
+	require.Eventually(t, func() bool {
+		state, ok := manager.GetTableState(span)
+		require.True(t, ok)
+		return state == tablepb.TableStateStopped
+	}, 5*time.Second, 10*time.Millisecond)
+	tableSink, ok := manager.tableSinks.Load(span)
+	require.True(t, ok)
+	require.Equal(t, uint64(4), tableSink.(*tableSinkWrapper).getCheckpointTs().ResolvedMark())
+
+	require.True(t, manager.AsyncStopTable(span))
+	manager.RemoveTable(span)
+	require.Equal(t, uint64(0), manager.sinkMemQuota.GetUsedBytes(), "After remove table, the memory usage should be 0.")
+}
+
 func TestGenerateTableSinkTaskWithBarrierTs(t *testing.T) {
 	t.Parallel()
 
@@ -397,7 +435,7 @@ func TestSinkManagerRestartTableSinks(t *testing.T) {
 	select {
 	case task := <-manager.sinkTaskChan:
 		require.Equal(t, sorter.Position{StartTs: 0, CommitTs: 3}, task.lowerBound)
-		task.callback(sorter.Position{StartTs: 3, CommitTs: 4})
+		task.callback(sorter.Position{StartTs: 3, CommitTs: 4}, 4)
 	case <-time.After(2 * time.Second):
 		panic("should always get a sink task")
 	}
@@ -409,7 +447,7 @@ func TestSinkManagerRestartTableSinks(t *testing.T) {
 	select {
 	case task := <-manager.sinkTaskChan:
 		require.Equal(t, sorter.Position{StartTs: 2, CommitTs: 2}, task.lowerBound)
-		task.callback(sorter.Position{StartTs: 3, CommitTs: 4})
+		task.callback(sorter.Position{StartTs: 3, CommitTs: 4}, 4)
 	case <-time.After(2 * time.Second):
 		panic("should always get a sink task")
 	}
@@ -0,0 +1,59 @@
+// Copyright 2023 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinkmanager
+
+import (
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/processor/memquota"
+	"github.com/pingcap/tiflow/cdc/processor/sourcemanager/sorter"
+	"github.com/pingcap/tiflow/cdc/processor/tablepb"
+)
+
+// MemQuota is the memory quota used by sink workers and redo workers.
+// It makes it possible to plug other quota strategies into workers.
+type MemQuota interface {
+	// TryAcquire, ForceAcquire and Refund are also used by the
+	// iterator to fetch events from the sort engine.
+	sorter.MemQuota
+
+	// BlockAcquire blocks until the quota is available or the quota is closed.
+	BlockAcquire(nBytes uint64) error
+	// Record records the memory usage of a table until the resolved ts.
+	Record(span tablepb.Span, resolved model.ResolvedTs, nBytes uint64)
+	// ClearTable clears all memory usage records of a table, and returns
+	// the cleaned size.
+	ClearTable(span tablepb.Span) uint64
+	// Close closes the quota and wakes up all blocked acquirers.
+	Close()
+}
+
+var _ MemQuota = (*memquota.MemQuota)(nil)
+
+// TableMemQuota is a MemQuota shared by tables. It manages the memory usage of
+// each table, and can acquire memory for each table separately.
+type TableMemQuota interface {
+	MemQuota
+
+	// AddTable adds a table to the quota.
+	AddTable(span tablepb.Span)
+	// Release releases the memory recorded by the table before resolved.
+	Release(span tablepb.Span, resolved model.ResolvedTs)
+	// RemoveTable removes a table from the quota, and returns the cleaned size.
+	RemoveTable(span tablepb.Span) uint64
+	// GetUsedBytes returns the memory used by all tables.
+	GetUsedBytes() uint64
+	// ForTable returns a MemQuota which acquires memory for the table. initial
+	// is the memory already acquired for the table, outside of the returned quota.
+	ForTable(span tablepb.Span, initial uint64) MemQuota
+}
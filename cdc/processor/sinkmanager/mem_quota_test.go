@@ -0,0 +1,121 @@
+// Copyright 2023 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinkmanager
+
+import (
+	"sync"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/processor/tablepb"
+)
+
+// fakeMemQuota is a TableMemQuota which only records how the memory is used.
+type fakeMemQuota struct {
+	mu       sync.Mutex
+	total    uint64
+	used     uint64
+	acquired uint64
+	refunded uint64
+	recorded uint64
+	closed   bool
+}
+
+func newFakeMemQuota(total uint64) *fakeMemQuota {
+	return &fakeMemQuota{total: total}
+}
+
+func (f *fakeMemQuota) TryAcquire(nBytes uint64) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.used+nBytes > f.total {
+		return false
+	}
+	f.used += nBytes
+	f.acquired += nBytes
+	return true
+}
+
+func (f *fakeMemQuota) ForceAcquire(nBytes uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.used += nBytes
+	f.acquired += nBytes
+}
+
+func (f *fakeMemQuota) BlockAcquire(nBytes uint64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return errors.New("fake memory quota is closed")
+	}
+	// Never block, it's enough for tests.
+	f.used += nBytes
+	f.acquired += nBytes
+	return nil
+}
+
+func (f *fakeMemQuota) Refund(nBytes uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.used -= nBytes
+	f.refunded += nBytes
+}
+
+func (f *fakeMemQuota) Record(_ tablepb.Span, _ model.ResolvedTs, nBytes uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.recorded += nBytes
+}
+
+func (f *fakeMemQuota) ClearTable(_ tablepb.Span) uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cleaned := f.recorded
+	f.used -= cleaned
+	f.recorded = 0
+	return cleaned
+}
+
+func (f *fakeMemQuota) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+}
+
+func (f *fakeMemQuota) AddTable(_ tablepb.Span) {}
+
+func (f *fakeMemQuota) Release(_ tablepb.Span, _ model.ResolvedTs) {}
+
+func (f *fakeMemQuota) RemoveTable(span tablepb.Span) uint64 {
+	return f.ClearTable(span)
+}
+
+func (f *fakeMemQuota) GetUsedBytes() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.used
+}
+
+// ForTable returns the fake quota itself, all tables share it.
+func (f *fakeMemQuota) ForTable(_ tablepb.Span, _ uint64) MemQuota {
+	return f
+}
+
+// stats returns the acquired, refunded and recorded bytes.
+func (f *fakeMemQuota) stats() (acquired, refunded, recorded uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.acquired, f.refunded, f.recorded
+}
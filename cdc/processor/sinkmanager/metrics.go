@@ -50,6 +50,86 @@ var (
 		// type includes hit and miss.
 		[]string{"namespace", "changefeed", "type"})
 
+	// MemoryRefundRatio indicates the ratio of refunded(over-acquired) memory
+	// to consumed memory of the last sink task of a changefeed.
+	MemoryRefundRatio = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "ticdc",
+			Subsystem: "sinkmanager",
+			Name:      "memory_refund_ratio",
+			Help:      "ratio of refunded memory to consumed memory of sink tasks",
+		},
+		[]string{"namespace", "changefeed"})
+
+	// TableSinkBacklogEventCount indicates the estimated count of events to be
+	// emitted to a table sink when a sink task starts.
+	TableSinkBacklogEventCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "ticdc",
+			Subsystem: "sinkmanager",
+			Name:      "table_sink_backlog_event_count",
+			Help:      "estimated count of events to be emitted to the table sink",
+		},
+		[]string{"namespace", "changefeed", "span"})
+
+	// TableSinkConsecutiveForceAcquireCount indicates how many steps in a row
+	// a sink task of the table force acquires memory beyond the quota.
+	TableSinkConsecutiveForceAcquireCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "ticdc",
+			Subsystem: "sinkmanager",
+			Name:      "table_sink_consecutive_force_acquire_count",
+			Help:      "count of consecutive force acquires of memory quota of the table sink",
+		},
+		[]string{"namespace", "changefeed", "span"})
+
+	// TableSinkTaskPeakMemory indicates the peak memory held by the last sink
+	// task of a table before the events are recorded to the memory quota,
+	// which helps to right-size the memory quota.
+	TableSinkTaskPeakMemory = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "ticdc",
+			Subsystem: "sinkmanager",
+			Name:      "table_sink_task_peak_memory",
+			Help:      "peak memory held by the last sink task of the table",
+		},
+		[]string{"namespace", "changefeed", "span"})
+
+	// TableSinkEventTypeCount indicates how many row changed events of each
+	// type are emitted to a table sink.
+	TableSinkEventTypeCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "ticdc",
+			Subsystem: "sinkmanager",
+			Name:      "table_sink_event_type_count",
+			Help:      "count of row changed events emitted to the table sink by type",
+		},
+		// type includes insert, update and delete.
+		[]string{"namespace", "changefeed", "span", "type"})
+
+	// TableSinkIteratorCloseDuration indicates how long it takes to close the
+	// iterator of the sort engine at the end of a sink task.
+	TableSinkIteratorCloseDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "ticdc",
+			Subsystem: "sinkmanager",
+			Name:      "table_sink_iterator_close_duration",
+			Help:      "Bucketed histogram of the duration to close the iterator of sink tasks",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 2.0, 20),
+		},
+		[]string{"namespace", "changefeed"})
+
+	// TableSinkTaskYieldCount indicates how many sink tasks yield because they
+	// run longer than the max task duration.
+	TableSinkTaskYieldCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "ticdc",
+			Subsystem: "sinkmanager",
+			Name:      "table_sink_task_yield_count",
+			Help:      "count of sink tasks which yield because they run too long",
+		},
+		[]string{"namespace", "changefeed"})
+
 	// outputEventCount is the metric that counts events output by the sorter.
 	outputEventCount = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "ticdc",
@@ -63,5 +143,12 @@ var (
 func InitMetrics(registry *prometheus.Registry) {
 	registry.MustRegister(RedoEventCache)
 	registry.MustRegister(RedoEventCacheAccess)
+	registry.MustRegister(MemoryRefundRatio)
+	registry.MustRegister(TableSinkBacklogEventCount)
+	registry.MustRegister(TableSinkConsecutiveForceAcquireCount)
+	registry.MustRegister(TableSinkTaskPeakMemory)
+	registry.MustRegister(TableSinkEventTypeCount)
+	registry.MustRegister(TableSinkIteratorCloseDuration)
+	registry.MustRegister(TableSinkTaskYieldCount)
 	registry.MustRegister(outputEventCount)
 }
@@ -0,0 +1,145 @@
+// Copyright 2023 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinkmanager
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/processor/sourcemanager/sorter"
+)
+
+// eventIterator is the iterator used by workers to fetch events.
+// sorter.MountedEventIter implements it.
+type eventIterator interface {
+	Next(ctx context.Context) (*model.PolymorphicEvent, sorter.Position, error)
+}
+
+type iterResult struct {
+	event *model.PolymorphicEvent
+	pos   sorter.Position
+	err   error
+	// size is the approximate bytes of the event counted by readAheadIter.
+	size uint64
+}
+
+// readAheadIter fetches events from the underlying iterator in a background
+// goroutine and buffers them, so that fetching events from a high-latency sort
+// engine can overlap with emitting events to sinks.
+//
+// Buffered events are not recorded by any memory quota until they're consumed,
+// so the buffer is bounded by both the count and the approximate bytes of events.
+//
+// NOTICE: The underlying iterator must not be used or closed until close returns.
+type readAheadIter struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	results chan iterResult
+	wg      sync.WaitGroup
+
+	// maxBytes is the upper bound of bufferedBytes. Zero means no limit.
+	// One event is always allowed even if it's larger than maxBytes.
+	maxBytes uint64
+	mu       sync.Mutex
+	// bufferedBytes is the approximate bytes of events fetched but not consumed.
+	bufferedBytes uint64
+	// consumed is notified when buffered events are consumed.
+	consumed chan struct{}
+}
+
+func newReadAheadIter(ctx context.Context, iter eventIterator, size int, maxBytes uint64) *readAheadIter {
+	ctx, cancel := context.WithCancel(ctx)
+	r := &readAheadIter{
+		ctx:      ctx,
+		cancel:   cancel,
+		results:  make(chan iterResult, size),
+		maxBytes: maxBytes,
+		consumed: make(chan struct{}, 1),
+	}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer close(r.results)
+		for {
+			e, pos, err := iter.Next(ctx)
+			var size uint64
+			if e != nil && e.Row != nil {
+				size = uint64(e.Row.ApproximateBytes())
+			}
+			if !r.reserveBytes(size) {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case r.results <- iterResult{event: e, pos: pos, err: err, size: size}:
+			}
+			// No more events or the iterator is broken.
+			if e == nil || err != nil {
+				return
+			}
+		}
+	}()
+	return r
+}
+
+// reserveBytes waits until the event of the given size can be buffered.
+// It returns false if the iterator is closed.
+func (r *readAheadIter) reserveBytes(size uint64) bool {
+	for {
+		r.mu.Lock()
+		if r.maxBytes == 0 || r.bufferedBytes == 0 || r.bufferedBytes+size <= r.maxBytes {
+			r.bufferedBytes += size
+			r.mu.Unlock()
+			return true
+		}
+		r.mu.Unlock()
+		select {
+		case <-r.ctx.Done():
+			return false
+		case <-r.consumed:
+		}
+	}
+}
+
+// Next returns the next prefetched event. It has the same semantic as
+// sorter.MountedEventIter.Next.
+func (r *readAheadIter) Next(ctx context.Context) (*model.PolymorphicEvent, sorter.Position, error) {
+	select {
+	case <-ctx.Done():
+		return nil, sorter.Position{}, ctx.Err()
+	case res, ok := <-r.results:
+		if !ok {
+			// The prefetch goroutine exits because of the context is canceled,
+			// it doesn't mean all events are fetched.
+			return nil, sorter.Position{}, r.ctx.Err()
+		}
+		r.mu.Lock()
+		r.bufferedBytes -= res.size
+		r.mu.Unlock()
+		select {
+		case r.consumed <- struct{}{}:
+		default:
+		}
+		return res.event, res.pos, res.err
+	}
+}
+
+// close stops the prefetch goroutine and waits for it to exit.
+// Prefetched but unconsumed events are dropped.
+func (r *readAheadIter) close() {
+	r.cancel()
+	r.wg.Wait()
+}
@@ -0,0 +1,205 @@
+// Copyright 2023 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinkmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/processor/sourcemanager/sorter"
+	"github.com/pingcap/tiflow/pkg/spanz"
+	"github.com/stretchr/testify/require"
+)
+
+// mockEventIter returns the given events one by one, and returns err
+// after all events are returned if err is not nil.
+type mockEventIter struct {
+	events []*model.PolymorphicEvent
+	err    error
+	delay  time.Duration
+	next   int
+}
+
+func newMockEventIter(n int) *mockEventIter {
+	span := spanz.TableIDToComparableSpan(1)
+	events := make([]*model.PolymorphicEvent, 0, n)
+	for i := 0; i < n; i++ {
+		// Two events per transaction.
+		commitTs := uint64(i/2 + 2)
+		events = append(events, genPolymorphicEvent(commitTs-1, commitTs, span))
+	}
+	return &mockEventIter{events: events}
+}
+
+func (m *mockEventIter) Next(ctx context.Context) (*model.PolymorphicEvent, sorter.Position, error) {
+	if m.delay > 0 {
+		time.Sleep(m.delay)
+	}
+	if m.next >= len(m.events) {
+		return nil, sorter.Position{}, m.err
+	}
+	e := m.events[m.next]
+	m.next++
+	var pos sorter.Position
+	if m.next == len(m.events) || m.events[m.next].CRTs != e.CRTs {
+		pos = sorter.Position{StartTs: e.StartTs, CommitTs: e.CRTs}
+	}
+	return e, pos, nil
+}
+
+func drainEventIter(t *testing.T, iter eventIterator) ([]*model.PolymorphicEvent, []sorter.Position, error) {
+	var (
+		events    []*model.PolymorphicEvent
+		positions []sorter.Position
+	)
+	for {
+		e, pos, err := iter.Next(context.Background())
+		if err != nil || e == nil {
+			return events, positions, err
+		}
+		events = append(events, e)
+		positions = append(positions, pos)
+		require.Less(t, len(events), 1024, "too many events")
+	}
+}
+
+func TestReadAheadIterSameAsSynchronous(t *testing.T) {
+	t.Parallel()
+
+	for _, size := range []int{1, 3, 16} {
+		expectedEvents, expectedPositions, err := drainEventIter(t, newMockEventIter(10))
+		require.NoError(t, err)
+
+		r := newReadAheadIter(context.Background(), newMockEventIter(10), size, 0)
+		events, positions, err := drainEventIter(t, r)
+		require.NoError(t, err)
+		r.close()
+
+		require.Len(t, events, 10)
+		for i := range events {
+			require.Equal(t, expectedEvents[i].CRTs, events[i].CRTs)
+			require.Equal(t, expectedEvents[i].StartTs, events[i].StartTs)
+		}
+		require.Equal(t, expectedPositions, positions)
+	}
+}
+
+func TestReadAheadIterBoundedByBytes(t *testing.T) {
+	t.Parallel()
+
+	eventSize := uint64(newMockEventIter(1).events[0].Row.ApproximateBytes())
+	bufferedEvents := func(r *readAheadIter) int {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return int(r.bufferedBytes / eventSize)
+	}
+
+	r := newReadAheadIter(context.Background(), newMockEventIter(10), 16, eventSize*2)
+	require.Eventually(t, func() bool { return bufferedEvents(r) == 2 }, 5*time.Second, 10*time.Millisecond)
+	// The buffer is full of bytes, even if there are free slots.
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, 2, bufferedEvents(r))
+	require.Len(t, r.results, 2)
+
+	events, _, err := drainEventIter(t, r)
+	require.NoError(t, err)
+	require.Len(t, events, 10)
+	r.close()
+
+	// An event larger than the limit can still be fetched.
+	r = newReadAheadIter(context.Background(), newMockEventIter(10), 16, 1)
+	events, _, err = drainEventIter(t, r)
+	require.NoError(t, err)
+	require.Len(t, events, 10)
+	r.close()
+}
+
+func TestReadAheadIterPropagateError(t *testing.T) {
+	t.Parallel()
+
+	iter := newMockEventIter(4)
+	iter.err = errors.New("fetch failed")
+	r := newReadAheadIter(context.Background(), iter, 2, 0)
+	defer r.close()
+
+	events, _, err := drainEventIter(t, r)
+	require.Len(t, events, 4)
+	require.ErrorContains(t, err, "fetch failed")
+}
+
+func TestReadAheadIterCloseAndCancel(t *testing.T) {
+	t.Parallel()
+
+	// Close without draining all events.
+	r := newReadAheadIter(context.Background(), newMockEventIter(100), 2, 0)
+	e, _, err := r.Next(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, e)
+	r.close()
+
+	// A canceled context should not be treated as all events are fetched.
+	ctx, cancel := context.WithCancel(context.Background())
+	iter := newMockEventIter(100)
+	iter.delay = 10 * time.Millisecond
+	r = newReadAheadIter(ctx, iter, 2, 0)
+	defer r.close()
+	cancel()
+	for {
+		e, _, err = r.Next(context.Background())
+		if e == nil {
+			require.ErrorIs(t, err, context.Canceled)
+			break
+		}
+	}
+}
+
+func BenchmarkReadAheadIter(b *testing.B) {
+	const eventCount = 100
+	// Simulates a high-latency sort engine and sink.
+	const fetchDelay = 10 * time.Microsecond
+	consume := func() { time.Sleep(10 * time.Microsecond) }
+
+	b.Run("synchronous", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			iter := newMockEventIter(eventCount)
+			iter.delay = fetchDelay
+			for {
+				e, _, _ := iter.Next(context.Background())
+				if e == nil {
+					break
+				}
+				consume()
+			}
+		}
+	})
+
+	b.Run("read-ahead", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			iter := newMockEventIter(eventCount)
+			iter.delay = fetchDelay
+			r := newReadAheadIter(context.Background(), iter, 16, 0)
+			for {
+				e, _, _ := r.Next(context.Background())
+				if e == nil {
+					break
+				}
+				consume()
+			}
+			r.close()
+		}
+	})
+}
@@ -19,7 +19,6 @@ import (
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
 	"github.com/pingcap/tiflow/cdc/model"
-	"github.com/pingcap/tiflow/cdc/processor/memquota"
 	"github.com/pingcap/tiflow/cdc/processor/sourcemanager/sorter"
 	"github.com/pingcap/tiflow/cdc/redo"
 	"go.uber.org/zap"
@@ -31,7 +30,7 @@ type redoLogAdvancer struct {
 	// redoDMLManager is used to write the redo log.
 	redoDMLManager redo.DMLManager
 	// memQuota is used to acquire memory quota for the redo log writer.
-	memQuota *memquota.MemQuota
+	memQuota MemQuota
 	// NOTICE: First time to run the task, we have initialized memory quota for the table.
 	// It is defaultRequestMemSize.
 	availableMem uint64
@@ -44,6 +43,8 @@ type redoLogAdvancer struct {
 	lastPos sorter.Position
 	// Buffer the events to be written to the redo log.
 	events []*model.RowChangedEvent
+	// Used to record the commit ts of the last event emitted to the redo log.
+	lastEmittedCommitTs model.Ts
 
 	// emittedCommitTs is used to record the last emitted transaction commit ts.
 	emittedCommitTs uint64
@@ -58,7 +59,7 @@ type redoLogAdvancer struct {
 
 func newRedoLogAdvancer(
 	task *redoTask,
-	memQuota *memquota.MemQuota,
+	memQuota MemQuota,
 	availableMem uint64,
 	redoDMLManager redo.DMLManager,
 ) *redoLogAdvancer {
@@ -94,6 +95,7 @@ func (a *redoLogAdvancer) advance(ctx context.Context, cachedSize uint64) error
 			a.events...); err != nil {
 			return errors.Trace(err)
 		}
+		a.lastEmittedCommitTs = a.events[len(a.events)-1].CommitTs
 		a.events = a.events[:0]
 		if cap(a.events) > bufferSize {
 			a.events = make([]*model.RowChangedEvent, 0, bufferSize)
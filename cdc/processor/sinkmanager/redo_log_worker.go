@@ -20,7 +20,6 @@ import (
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
 	"github.com/pingcap/tiflow/cdc/model"
-	"github.com/pingcap/tiflow/cdc/processor/memquota"
 	"github.com/pingcap/tiflow/cdc/processor/sourcemanager"
 	"github.com/pingcap/tiflow/cdc/redo"
 	"github.com/tikv/client-go/v2/oracle"
@@ -30,7 +29,7 @@ import (
 type redoWorker struct {
 	changefeedID   model.ChangeFeedID
 	sourceManager  *sourcemanager.SourceManager
-	memQuota       *memquota.MemQuota
+	memQuota       MemQuota
 	redoDMLManager redo.DMLManager
 	eventCache     *redoEventCache
 }
@@ -38,7 +37,7 @@ type redoWorker struct {
 func newRedoWorker(
 	changefeedID model.ChangeFeedID,
 	sourceManager *sourcemanager.SourceManager,
-	quota *memquota.MemQuota,
+	quota MemQuota,
 	redoDMLMgr redo.DMLManager,
 	eventCache *redoEventCache,
 ) *redoWorker {
@@ -109,7 +108,7 @@ func (w *redoWorker) handleTask(ctx context.Context, task *redoTask) (finalErr e
 
 		if finalErr == nil {
 			// Otherwise we can't ensure all events before `lastPos` are emitted.
-			task.callback(advancer.lastPos)
+			task.callback(advancer.lastPos, advancer.lastEmittedCommitTs)
 		}
 	}()
 
@@ -115,7 +115,7 @@ func (suite *redoLogWorkerSuite) TestHandleTaskGotSomeFilteredEvents() {
 		require.Equal(suite.T(), context.Canceled, err)
 	}()
 
-	callback := func(lastWritePos sorter.Position) {
+	callback := func(lastWritePos sorter.Position, _ model.Ts) {
 		require.Equal(suite.T(), sorter.Position{
 			StartTs:  1,
 			CommitTs: 4,
@@ -165,7 +165,7 @@ func (suite *redoLogWorkerSuite) TestHandleTaskAbortWhenNoMemAndOneTxnFinished()
 		require.Equal(suite.T(), context.Canceled, err)
 	}()
 
-	callback := func(lastWritePos sorter.Position) {
+	callback := func(lastWritePos sorter.Position, _ model.Ts) {
 		require.Equal(suite.T(), sorter.Position{
 			StartTs:  1,
 			CommitTs: 3,
@@ -213,7 +213,7 @@ func (suite *redoLogWorkerSuite) TestHandleTaskAbortWhenNoMemAndBlocked() {
 		require.ErrorIs(suite.T(), err, context.Canceled)
 	}()
 
-	callback := func(lastWritePos sorter.Position) {
+	callback := func(lastWritePos sorter.Position, _ model.Ts) {
 		require.Equal(suite.T(), sorter.Position{
 			StartTs:  0,
 			CommitTs: 0,
@@ -259,7 +259,7 @@ func (suite *redoLogWorkerSuite) TestHandleTaskWithSplitTxnAndAdvanceIfNoWorkloa
 		require.ErrorIs(suite.T(), err, context.Canceled)
 	}()
 
-	callback := func(lastWritePos sorter.Position) {
+	callback := func(lastWritePos sorter.Position, _ model.Ts) {
 		require.Equal(suite.T(), sorter.Position{
 			StartTs:  3,
 			CommitTs: 4,
@@ -311,7 +311,7 @@ func (suite *redoLogWorkerSuite) TestHandleTaskWithoutMemory() {
 	defer sink.Close()
 
 	chShouldBeClosed := make(chan struct{}, 1)
-	callback := func(lastWritePos sorter.Position) {
+	callback := func(lastWritePos sorter.Position, _ model.Ts) {
 		require.Equal(suite.T(), genLowerBound().Prev(), lastWritePos)
 		close(chShouldBeClosed)
 	}
@@ -14,21 +14,77 @@
 package sinkmanager
 
 import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"time"
+
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
 	"github.com/pingcap/tiflow/cdc/model"
-	"github.com/pingcap/tiflow/cdc/processor/memquota"
 	"github.com/pingcap/tiflow/cdc/processor/sourcemanager/sorter"
+	cerrors "github.com/pingcap/tiflow/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 )
 
+// eventEmitter emits events and resolved ts of a table to its table sink.
+// It's implemented by tableSinkWrapper, and can be faked in tests.
+type eventEmitter interface {
+	appendRowChangedEvents(events ...*model.RowChangedEvent) error
+	getSuggestedDelay() time.Duration
+	updateResolvedTs(ts model.ResolvedTs) error
+}
+
+var _ eventEmitter = (*tableSinkWrapper)(nil)
+
 type tableSinkAdvancer struct {
 	// NOTICE: This task is immutable, so please never modify it.
 	task *sinkTask
+	// emitter emits events to the table sink. It's the table sink of the task
+	// by default.
+	emitter eventEmitter
+	// ctx cancels waiting between retries of emits. It's the context of
+	// the worker handling the task.
+	ctx context.Context
 	// splitTxn indicates whether to split the transaction into multiple batches.
 	splitTxn bool
+	// sortByPK indicates whether to sort the buffered events of each transaction
+	// by primary key before appending them to the table sink.
+	sortByPK bool
+	// coalesceByPK indicates whether to merge adjacent events on the same
+	// primary key of each transaction before appending them to the table sink.
+	coalesceByPK bool
+	// allowedEventTypes is the types of events allowed to be appended to the
+	// table sink, others are dropped. Zero means all types are allowed.
+	allowedEventTypes eventTypeSet
+	// slowEmitThreshold is used to log slow emits to the table sink.
+	// Zero means never log.
+	slowEmitThreshold time.Duration
+	// slowEmitLogLimiter limits the rate of slow emit logs. It is shared by
+	// all tasks of one worker.
+	slowEmitLogLimiter *rate.Limiter
+	// maxNonSplitTxnSize is the hard limit of buffered bytes of one transaction
+	// if splitTxn is false. Zero means no limit.
+	maxNonSplitTxnSize uint64
+	// emitRetryLimit is how many times to retry appending events to the table
+	// sink if the error is transient.
+	emitRetryLimit uint
+	// batchSize is how many bytes are buffered before emitting them to the
+	// table sink. nil means it's fixed to maxUpdateIntervalSize.
+	batchSize *adaptiveBatchSize
 	// sinkMemQuota is used to acquire memory quota for the table sink.
-	sinkMemQuota *memquota.MemQuota
+	sinkMemQuota MemQuota
+	// consecutiveForceAcquires is how many steps in a row force acquire memory
+	// beyond the quota. It's reset by a step within the quota.
+	consecutiveForceAcquires int
+	// forceAcquireGauge reports consecutiveForceAcquires. nil means never report.
+	forceAcquireGauge prometheus.Gauge
+	// eventTypeCounter counts emitted events by the "type" label.
+	// nil means never count.
+	eventTypeCounter *prometheus.CounterVec
 	// NOTICE: First time to run the task, we have initialized memory quota for the table.
 	// It is defaultRequestMemSize.
 	availableMem uint64
@@ -36,11 +92,19 @@ type tableSinkAdvancer struct {
 	// This is used to calculate how much memory we need to acquire.
 	// Only when usedMem > availableMem we need to acquire memory.
 	usedMem uint64
+	// The most memory held by buffered and unrecorded events at any time.
+	// Unlike usedMem it doesn't count the memory already handed over to
+	// the table sink.
+	peakMem uint64
 	// Used to record the last written position.
 	// We need to use it to update the lower bound of the table sink.
 	lastPos sorter.Position
 	// Buffer the events to be written to the table sink.
 	events []*model.RowChangedEvent
+	// Used to record the commit ts of the last event appended to the table sink.
+	lastEmittedCommitTs model.Ts
+	// The delay suggested by the table sink after the last append.
+	suggestedDelay time.Duration
 
 	// Used to record the size of already appended transaction.
 	committedTxnSize uint64
@@ -56,11 +120,13 @@ type tableSinkAdvancer struct {
 func newTableSinkAdvancer(
 	task *sinkTask,
 	splitTxn bool,
-	sinkMemQuota *memquota.MemQuota,
+	sinkMemQuota MemQuota,
 	availableMem uint64,
 ) *tableSinkAdvancer {
 	return &tableSinkAdvancer{
 		task:         task,
+		emitter:      task.tableSink,
+		ctx:          context.Background(),
 		splitTxn:     splitTxn,
 		sinkMemQuota: sinkMemQuota,
 		availableMem: availableMem,
@@ -74,11 +140,32 @@ func newTableSinkAdvancer(
 // If it is the last time, and we still have some events in the buffer,
 // we need to record the memory usage and append the events to the table sink.
 func (a *tableSinkAdvancer) advance(isLastTime bool) (err error) {
+	// Dropped events are still advanced by the resolved ts below.
+	if a.allowedEventTypes != 0 {
+		a.events = filterEventsByType(a.events, a.allowedEventTypes)
+	}
+	if a.sortByPK {
+		sortEventsByPrimaryKey(a.events)
+	}
+	if a.coalesceByPK {
+		a.events = coalesceEventsByPrimaryKey(a.events)
+	}
 	// Append the events to the table sink first.
 	if len(a.events) > 0 {
-		if err = a.task.tableSink.appendRowChangedEvents(a.events...); err != nil {
+		start := time.Now()
+		// A failed append never takes effect, so it's safe to retry.
+		err = emitWithRetry(a.ctx, a.task, a.emitRetryLimit, func() error {
+			return a.emitter.appendRowChangedEvents(a.events...)
+		})
+		if err != nil {
 			return
 		}
+		a.countEventTypes()
+		duration := time.Since(start)
+		a.checkSlowEmit(duration, len(a.events))
+		a.batchSize.observe(duration)
+		a.lastEmittedCommitTs = a.events[len(a.events)-1].CommitTs
+		a.suggestedDelay = a.emitter.getSuggestedDelay()
 		a.events = a.events[:0]
 		if cap(a.events) > bufferSize {
 			a.events = make([]*model.RowChangedEvent, 0, bufferSize)
@@ -97,14 +184,14 @@ func (a *tableSinkAdvancer) advance(isLastTime bool) (err error) {
 	if a.currTxnCommitTs == a.lastPos.CommitTs {
 		// All transactions before currTxnCommitTs are resolved.
 		if a.lastPos.IsCommitFence() {
-			err = advanceTableSink(a.task, a.currTxnCommitTs,
+			err = advanceTableSink(a.task, a.emitter, a.currTxnCommitTs,
 				a.committedTxnSize+a.pendingTxnSize, a.sinkMemQuota)
 		} else {
 			// This means all events of the current transaction have been fetched, but we can't
 			// ensure whether there are more transaction with the same CommitTs or not.
 			// So we need to advance the table sink with a batchID. It will make sure that
 			// we do not cross the CommitTs boundary.
-			err = advanceTableSinkWithBatchID(a.task, a.currTxnCommitTs,
+			err = advanceTableSinkWithBatchID(a.task, a.emitter, a.currTxnCommitTs,
 				a.committedTxnSize+a.pendingTxnSize, batchID.Load(), a.sinkMemQuota)
 			batchID.Add(1)
 		}
@@ -116,7 +203,7 @@ func (a *tableSinkAdvancer) advance(isLastTime bool) (err error) {
 		// we can advance the table sink with the current commit ts.
 		// This will advance some complete transactions before currTxnCommitTs,
 		// and one partial transaction with `batchID`.
-		err = advanceTableSinkWithBatchID(a.task, a.currTxnCommitTs,
+		err = advanceTableSinkWithBatchID(a.task, a.emitter, a.currTxnCommitTs,
 			a.committedTxnSize+a.pendingTxnSize, batchID.Load(), a.sinkMemQuota)
 
 		batchID.Add(1)
@@ -125,20 +212,51 @@ func (a *tableSinkAdvancer) advance(isLastTime bool) (err error) {
 	} else if !a.splitTxn && a.lastTxnCommitTs > 0 {
 		// We just got a new commit ts. Because we don't split the transaction,
 		// we **only** advance the table sink by the last transaction commit ts.
-		err = advanceTableSink(a.task, a.lastTxnCommitTs,
+		err = advanceTableSink(a.task, a.emitter, a.lastTxnCommitTs,
 			a.committedTxnSize, a.sinkMemQuota)
 		a.committedTxnSize = 0
-		// If it is the last time we call `advance`, but `pendingTxnSize`
-		// hasn't been recorded yet. To avoid losing it, record it manually.
-		if isLastTime && a.pendingTxnSize > 0 {
-			a.sinkMemQuota.Record(a.task.span,
-				model.NewResolvedTs(a.currTxnCommitTs), a.pendingTxnSize)
-			a.pendingTxnSize = 0
-		}
+	}
+
+	// If it is the last time we call `advance`, but `pendingTxnSize`
+	// hasn't been recorded yet. To avoid losing it, record it manually.
+	// It also happens if the task stops in its first transaction, which
+	// can't be advanced at all.
+	if isLastTime && a.pendingTxnSize > 0 {
+		a.sinkMemQuota.Record(a.task.span,
+			model.NewResolvedTs(a.currTxnCommitTs), a.pendingTxnSize)
+		a.pendingTxnSize = 0
 	}
 	return
 }
 
+// checkSlowEmit logs the emit if it costs more than slowEmitThreshold.
+func (a *tableSinkAdvancer) checkSlowEmit(duration time.Duration, batchSize int) {
+	if a.slowEmitThreshold <= 0 || duration <= a.slowEmitThreshold {
+		return
+	}
+	if a.slowEmitLogLimiter != nil && !a.slowEmitLogLimiter.Allow() {
+		return
+	}
+	log.Warn("Emit events to table sink is too slow",
+		zap.String("namespace", a.task.tableSink.changefeed.Namespace),
+		zap.String("changefeed", a.task.tableSink.changefeed.ID),
+		zap.Stringer("span", &a.task.span),
+		zap.Int("batchSize", batchSize),
+		zap.Duration("duration", duration),
+		zap.Duration("threshold", a.slowEmitThreshold))
+}
+
+// takeSuggestedDelay returns the delay suggested by the table sink, which is
+// bounded by maxSuggestedDelay, and resets it.
+func (a *tableSinkAdvancer) takeSuggestedDelay() time.Duration {
+	delay := a.suggestedDelay
+	a.suggestedDelay = 0
+	if delay > maxSuggestedDelay {
+		delay = maxSuggestedDelay
+	}
+	return delay
+}
+
 // lastTimeAdvance only happens when there is no enough memory quota to
 // acquire, and the task is not finished.
 // In this case, we need to try to advance the table sink as much as possible.
@@ -156,6 +274,24 @@ func (a *tableSinkAdvancer) tryAdvanceAndAcquireMem(
 	allFetched bool,
 	txnFinished bool,
 ) error {
+	// A non-split transaction is buffered entirely before being emitted, so
+	// refuse to buffer it beyond the limit if there is one.
+	if !a.splitTxn && a.maxNonSplitTxnSize > 0 && a.pendingTxnSize > a.maxNonSplitTxnSize {
+		log.Warn("Transaction is too large to be buffered without splitting",
+			zap.String("namespace", a.task.tableSink.changefeed.Namespace),
+			zap.String("changefeed", a.task.tableSink.changefeed.ID),
+			zap.Stringer("span", &a.task.span),
+			zap.Uint64("commitTs", a.currTxnCommitTs),
+			zap.Uint64("txnSize", a.pendingTxnSize),
+			zap.Uint64("limit", a.maxNonSplitTxnSize))
+		return cerrors.ErrSinkTxnTooLarge.GenWithStackByArgs(a.pendingTxnSize, a.maxNonSplitTxnSize)
+	}
+
+	forced := false
+	defer func() {
+		a.updateConsecutiveForceAcquires(forced)
+	}()
+
 	// If used memory size exceeds the required limit, do a force acquire to
 	// make sure the memory quota is not exceeded or leak.
 	// For example, if the memory quota is 100MB, and current usedMem is 90MB,
@@ -165,6 +301,7 @@ func (a *tableSinkAdvancer) tryAdvanceAndAcquireMem(
 	exceedAvailableMem := a.availableMem < a.usedMem
 	if exceedAvailableMem {
 		a.sinkMemQuota.ForceAcquire(a.usedMem - a.availableMem)
+		forced = true
 		log.Debug("MemoryQuotaTracing: force acquire memory for table sink task",
 			zap.String("namespace", a.task.tableSink.changefeed.Namespace),
 			zap.String("changefeed", a.task.tableSink.changefeed.ID),
@@ -176,9 +313,9 @@ func (a *tableSinkAdvancer) tryAdvanceAndAcquireMem(
 	// Do emit in such situations:
 	// 1. we use more memory than we required;
 	// 2. all events are received.
-	// 3. the pending batch size exceeds maxUpdateIntervalSize;
+	// 3. the pending batch size exceeds the batch size;
 	if exceedAvailableMem || allFetched ||
-		needEmitAndAdvance(a.splitTxn, a.committedTxnSize, a.pendingTxnSize) {
+		needEmitAndAdvance(a.splitTxn, a.committedTxnSize, a.pendingTxnSize, a.batchSize.get()) {
 		if err := a.advance(false); err != nil {
 			return errors.Trace(err)
 		}
@@ -209,6 +346,7 @@ func (a *tableSinkAdvancer) tryAdvanceAndAcquireMem(
 			// to the next round.
 			if !a.splitTxn {
 				a.sinkMemQuota.ForceAcquire(requestMemSize)
+				forced = true
 				a.availableMem += requestMemSize
 				log.Debug("MemoryQuotaTracing: force acquire memory for table sink task",
 					zap.String("namespace", a.task.tableSink.changefeed.Namespace),
@@ -234,6 +372,47 @@ func (a *tableSinkAdvancer) tryAdvanceAndAcquireMem(
 	return nil
 }
 
+// updateConsecutiveForceAcquires records whether the last step force acquired
+// memory. A table which keeps force acquiring memory means the memory quota
+// is too small for it.
+func (a *tableSinkAdvancer) updateConsecutiveForceAcquires(forced bool) {
+	if forced {
+		a.consecutiveForceAcquires++
+	} else {
+		a.consecutiveForceAcquires = 0
+	}
+	if a.forceAcquireGauge != nil {
+		a.forceAcquireGauge.Set(float64(a.consecutiveForceAcquires))
+	}
+}
+
+// countEventTypes counts the buffered events by operation type.
+func (a *tableSinkAdvancer) countEventTypes() {
+	if a.eventTypeCounter == nil {
+		return
+	}
+	var inserts, updates, deletes int
+	for _, e := range a.events {
+		switch {
+		case e.IsInsert():
+			inserts++
+		case e.IsUpdate():
+			updates++
+		case e.IsDelete():
+			deletes++
+		}
+	}
+	if inserts > 0 {
+		a.eventTypeCounter.WithLabelValues("insert").Add(float64(inserts))
+	}
+	if updates > 0 {
+		a.eventTypeCounter.WithLabelValues("update").Add(float64(updates))
+	}
+	if deletes > 0 {
+		a.eventTypeCounter.WithLabelValues("delete").Add(float64(deletes))
+	}
+}
+
 // tryMoveToNextTxn tries to move to the next transaction.
 // If the commitTs is different from the current transaction, it means
 // the current transaction is finished. We need to move to the next transaction.
@@ -268,6 +447,10 @@ func (a *tableSinkAdvancer) appendEvents(events []*model.RowChangedEvent, size u
 	// Record the pending transaction size. It means how many events we do
 	// not flush to the table sink.
 	a.pendingTxnSize += size
+	// The held memory only grows here, it's reset after being recorded.
+	if held := a.committedTxnSize + a.pendingTxnSize; held > a.peakMem {
+		a.peakMem = held
+	}
 }
 
 // hasEnoughMem returns whether the table sink task has enough memory to continue.
@@ -276,24 +459,27 @@ func (a *tableSinkAdvancer) hasEnoughMem() bool {
 }
 
 // cleanup cleans up the memory usage.
-// Refund the memory usage if we do not use it.
-func (a *tableSinkAdvancer) cleanup() {
+// Refund the memory usage if we do not use it, and return the refunded size.
+func (a *tableSinkAdvancer) cleanup() (refunded uint64) {
 	if a.availableMem > a.usedMem {
-		a.sinkMemQuota.Refund(a.availableMem - a.usedMem)
+		refunded = a.availableMem - a.usedMem
+		a.sinkMemQuota.Refund(refunded)
 		log.Debug("MemoryQuotaTracing: refund memory for table sink task",
 			zap.String("namespace", a.task.tableSink.changefeed.Namespace),
 			zap.String("changefeed", a.task.tableSink.changefeed.ID),
 			zap.Stringer("span", &a.task.span),
-			zap.Uint64("memory", a.availableMem-a.usedMem))
+			zap.Uint64("memory", refunded))
 	}
+	return
 }
 
 func advanceTableSinkWithBatchID(
 	t *sinkTask,
+	emitter eventEmitter,
 	commitTs model.Ts,
 	size uint64,
 	batchID uint64,
-	sinkMemQuota *memquota.MemQuota,
+	sinkMemQuota MemQuota,
 ) error {
 	resolvedTs := model.NewResolvedTs(commitTs)
 	resolvedTs.Mode = model.BatchResolvedMode
@@ -307,14 +493,18 @@ func advanceTableSinkWithBatchID(
 	if size > 0 {
 		sinkMemQuota.Record(t.span, resolvedTs, size)
 	}
-	return t.tableSink.updateResolvedTs(resolvedTs)
+	// Never retry it. The table sink takes the resolved ts and the buffered
+	// events before writing them to the backend sink, so a retry succeeds
+	// without writing anything.
+	return emitter.updateResolvedTs(resolvedTs)
 }
 
 func advanceTableSink(
 	t *sinkTask,
+	emitter eventEmitter,
 	commitTs model.Ts,
 	size uint64,
-	sinkMemQuota *memquota.MemQuota,
+	sinkMemQuota MemQuota,
 ) error {
 	resolvedTs := model.NewResolvedTs(commitTs)
 	log.Debug("Advance table sink without batch ID",
@@ -326,17 +516,337 @@ func advanceTableSink(
 	if size > 0 {
 		sinkMemQuota.Record(t.span, resolvedTs, size)
 	}
-	return t.tableSink.updateResolvedTs(resolvedTs)
+	// Never retry it. The table sink takes the resolved ts and the buffered
+	// events before writing them to the backend sink, so a retry succeeds
+	// without writing anything.
+	return emitter.updateResolvedTs(resolvedTs)
+}
+
+// emitWithRetry calls emit, and retries it at most retryLimit times with
+// backoff if the error is transient. Other errors are returned immediately.
+// A transient error must mean emit takes no effect, so it's safe to retry.
+func emitWithRetry(ctx context.Context, t *sinkTask, retryLimit uint, emit func() error) error {
+	backoff := emitRetryBackoff
+	for retry := uint(0); ; retry++ {
+		err := emit()
+		if err == nil || retry >= retryLimit || !isTransientEmitError(err) {
+			return err
+		}
+		log.Warn("Emit to table sink failed with a transient error, retry it",
+			zap.String("namespace", t.tableSink.changefeed.Namespace),
+			zap.String("changefeed", t.tableSink.changefeed.ID),
+			zap.Stringer("span", &t.span),
+			zap.Uint("retry", retry+1),
+			zap.Duration("backoff", backoff),
+			zap.Error(err))
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			// Return the emit error rather than the context error, so that
+			// the unfinished emit is never taken as done.
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// transientError is implemented by errors which may disappear if the
+// operation is retried.
+type transientError interface {
+	Transient() bool
+}
+
+// transientEmitErrors are errors of sinks which may disappear if the emit is
+// retried, such as network errors.
+var transientEmitErrors = []*errors.Error{
+	cerrors.ErrMySQLConnectionError,
+	cerrors.ErrMySQLTxnError,
+	cerrors.ErrKafkaSendMessage,
+	cerrors.ErrKafkaAsyncSendMessage,
+	cerrors.ErrPulsarSendMessage,
+	cerrors.ErrExternalStorageAPI,
+}
+
+// isTransientEmitError checks whether the error is transient. Errors of
+// a dead table sink aren't transient, so they are returned immediately to
+// restart the table sink.
+func isTransientEmitError(err error) bool {
+	if err == nil || cerrors.ShouldFailChangefeed(err) {
+		return false
+	}
+	// Errors from table sinks are wrapped by tablesink.SinkInternalError, which
+	// only supports the standard `Unwrap`, so both `Unwrap` and `Cause` are
+	// followed to find the root cause.
+	for cause := err; cause != nil; cause = unwrapEmitError(cause) {
+		if cause == context.Canceled || cause == context.DeadlineExceeded {
+			return false
+		}
+		if e, ok := cause.(transientError); ok {
+			return e.Transient()
+		}
+		for _, e := range transientEmitErrors {
+			if e.Equal(cause) {
+				return true
+			}
+			if code, ok := cerrors.RFCCode(cause); ok && code == e.RFCCode() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unwrapEmitError returns the error wrapped by err, or nil if there is none.
+func unwrapEmitError(err error) error {
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		return e.Unwrap()
+	case interface{ Cause() error }:
+		return e.Cause()
+	}
+	return nil
 }
 
-func needEmitAndAdvance(splitTxn bool, committedTxnSize uint64, pendingTxnSize uint64) bool {
+func needEmitAndAdvance(splitTxn bool, committedTxnSize uint64, pendingTxnSize uint64, batchSize uint64) bool {
 	// If splitTxn is true, we can safely emit all the events in the last transaction
 	// and current transaction. So we use `committedTxnSize+pendingTxnSize`.
-	splitTxnEmitCondition := splitTxn && committedTxnSize+pendingTxnSize >= maxUpdateIntervalSize
+	splitTxnEmitCondition := splitTxn && committedTxnSize+pendingTxnSize >= batchSize
 	// If splitTxn is false, we need to emit the events when the size of the
-	// transaction is greater than maxUpdateIntervalSize.
+	// transaction is greater than batchSize.
 	// This could help to reduce the overhead of emit and advance too frequently.
-	noSplitTxnEmitCondition := !splitTxn && committedTxnSize >= maxUpdateIntervalSize
+	noSplitTxnEmitCondition := !splitTxn && committedTxnSize >= batchSize
 	return splitTxnEmitCondition ||
 		noSplitTxnEmitCondition
 }
+
+// sortEventsByPrimaryKey sorts events by primary key within each transaction.
+// Events of different transactions keep their original order. A transaction
+// is only sorted if reordering its rows can't break any unique constraint of
+// the downstream, see canSortByPrimaryKey.
+func sortEventsByPrimaryKey(events []*model.RowChangedEvent) {
+	for start := 0; start < len(events); {
+		end := start + 1
+		for end < len(events) &&
+			events[end].CommitTs == events[start].CommitTs &&
+			events[end].StartTs == events[start].StartTs {
+			end++
+		}
+		txn := events[start:end]
+		start = end
+		if !canSortByPrimaryKey(txn) {
+			continue
+		}
+		keys := make(map[*model.RowChangedEvent][]interface{}, len(txn))
+		for _, e := range txn {
+			keys[e] = primaryKeyValues(e)
+		}
+		sort.SliceStable(txn, func(i, j int) bool {
+			return comparePrimaryKeyValues(keys[txn[i]], keys[txn[j]]) < 0
+		})
+	}
+}
+
+// canSortByPrimaryKey checks whether the rows of a transaction can be
+// reordered by primary key. It requires every row has a primary key as the
+// handle key, no other unique key, and no update changes the primary key.
+// Otherwise, e.g. a delete of a unique key value followed by an insert of the
+// same value with a smaller primary key would be swapped, and the downstream
+// would fail with a duplicate entry error.
+func canSortByPrimaryKey(txn []*model.RowChangedEvent) bool {
+	for _, e := range txn {
+		cols := e.Columns
+		if e.IsDelete() {
+			cols = e.PreColumns
+		}
+		hasHandleKey := false
+		for _, col := range cols {
+			if col == nil {
+				continue
+			}
+			if col.Flag.IsHandleKey() != col.Flag.IsPrimaryKey() {
+				return false
+			}
+			if col.Flag.IsUniqueKey() && !col.Flag.IsPrimaryKey() {
+				return false
+			}
+			hasHandleKey = hasHandleKey || col.Flag.IsHandleKey()
+		}
+		if !hasHandleKey {
+			return false
+		}
+		if e.IsUpdate() && comparePrimaryKeyValues(
+			primaryKeyValuesOf(e.PreColumns), primaryKeyValuesOf(e.Columns)) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// eventTypeSet is a set of row changed event types.
+type eventTypeSet uint8
+
+const (
+	eventTypeInsert eventTypeSet = 1 << iota
+	eventTypeUpdate
+	eventTypeDelete
+)
+
+// getEventType returns the type of the row changed event.
+func getEventType(e *model.RowChangedEvent) eventTypeSet {
+	switch {
+	case e.IsInsert():
+		return eventTypeInsert
+	case e.IsUpdate():
+		return eventTypeUpdate
+	default:
+		return eventTypeDelete
+	}
+}
+
+// filterEventsByType drops the events whose types are not in allowed in place.
+// The split marker of a dropped event is carried over to the next kept event
+// of the same transaction.
+func filterEventsByType(events []*model.RowChangedEvent, allowed eventTypeSet) []*model.RowChangedEvent {
+	n := 0
+	var splitFrom *model.RowChangedEvent
+	for _, e := range events {
+		if splitFrom != nil && (splitFrom.CommitTs != e.CommitTs || splitFrom.StartTs != e.StartTs) {
+			splitFrom = nil
+		}
+		if getEventType(e)&allowed == 0 {
+			if e.SplitTxn && splitFrom == nil {
+				splitFrom = e
+			}
+			continue
+		}
+		if splitFrom != nil {
+			e.SplitTxn = true
+			splitFrom = nil
+		}
+		events[n] = e
+		n++
+	}
+	// Don't hold the dropped events in the buffer.
+	for i := n; i < len(events); i++ {
+		events[i] = nil
+	}
+	return events[:n]
+}
+
+// coalesceEventsByPrimaryKey merges adjacent events on the same primary key
+// of one transaction, and returns the remaining events. It reuses the memory
+// of the given slice. The rules are:
+//   - insert + update = insert with the new values
+//   - update + update = update from the oldest to the newest values
+//   - update + delete = delete with the oldest values
+//   - insert + delete = nothing
+//
+// Other sequences, such as delete + insert, are kept as they are.
+func coalesceEventsByPrimaryKey(events []*model.RowChangedEvent) []*model.RowChangedEvent {
+	result := events[:0]
+	for _, next := range events {
+		if len(result) == 0 {
+			result = append(result, next)
+			continue
+		}
+		prev := result[len(result)-1]
+		if !canCoalesce(prev, next) {
+			result = append(result, next)
+			continue
+		}
+		if prev.IsInsert() && next.IsDelete() {
+			result = result[:len(result)-1]
+			continue
+		}
+		// Never modify the events in place, they may be shared with others.
+		merged := *prev
+		merged.Columns = next.Columns
+		merged.ApproximateDataSize = next.ApproximateDataSize
+		result[len(result)-1] = &merged
+	}
+	return result
+}
+
+// canCoalesce checks whether next can be merged into prev, which requires
+// both of them belong to the same transaction, the new values of prev are
+// the old values of next, and the merged event is still valid.
+func canCoalesce(prev, next *model.RowChangedEvent) bool {
+	if prev.CommitTs != next.CommitTs || prev.StartTs != next.StartTs || next.SplitTxn {
+		return false
+	}
+	if prev.IsDelete() || next.IsInsert() {
+		return false
+	}
+	// Dropping both events would lose the split mark of the transaction.
+	if prev.IsInsert() && next.IsDelete() && prev.SplitTxn {
+		return false
+	}
+	prevKey := primaryKeyValuesOf(prev.Columns)
+	nextKey := primaryKeyValuesOf(next.PreColumns)
+	return len(prevKey) > 0 && len(prevKey) == len(nextKey) &&
+		comparePrimaryKeyValues(prevKey, nextKey) == 0
+}
+
+func primaryKeyValues(e *model.RowChangedEvent) []interface{} {
+	if e.IsDelete() {
+		return primaryKeyValuesOf(e.PreColumns)
+	}
+	return primaryKeyValuesOf(e.Columns)
+}
+
+func primaryKeyValuesOf(cols []*model.Column) []interface{} {
+	var values []interface{}
+	for _, col := range cols {
+		if col != nil && col.Flag.IsPrimaryKey() {
+			values = append(values, col.Value)
+		}
+	}
+	return values
+}
+
+// comparePrimaryKeyValues compares two primary keys column by column.
+func comparePrimaryKeyValues(a, b []interface{}) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareColumnValue(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+func compareColumnValue(a, b interface{}) int {
+	switch x := a.(type) {
+	case int64:
+		if y, ok := b.(int64); ok {
+			return compareOrdered(x, y)
+		}
+	case uint64:
+		if y, ok := b.(uint64); ok {
+			return compareOrdered(x, y)
+		}
+	case float64:
+		if y, ok := b.(float64); ok {
+			return compareOrdered(x, y)
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y)
+		}
+	case []byte:
+		if y, ok := b.([]byte); ok {
+			return bytes.Compare(x, y)
+		}
+	}
+	return strings.Compare(model.ColumnValueString(a), model.ColumnValueString(b))
+}
+
+func compareOrdered[T int64 | uint64 | float64](x, y T) int {
+	if x < y {
+		return -1
+	} else if x > y {
+		return 1
+	}
+	return 0
+}
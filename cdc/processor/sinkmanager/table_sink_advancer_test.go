@@ -19,13 +19,22 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
 	"github.com/pingcap/tiflow/cdc/model"
 	"github.com/pingcap/tiflow/cdc/processor/memquota"
 	"github.com/pingcap/tiflow/cdc/processor/sourcemanager/sorter"
 	"github.com/pingcap/tiflow/cdc/processor/tablepb"
+	"github.com/pingcap/tiflow/cdc/sink/tablesink"
+	cerrors "github.com/pingcap/tiflow/pkg/errors"
 	"github.com/pingcap/tiflow/pkg/spanz"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"golang.org/x/time/rate"
 )
 
 type tableSinkAdvancerSuite struct {
@@ -118,7 +127,7 @@ func (suite *tableSinkAdvancerSuite) TestNeedEmitAndAdvance() {
 	} {
 		suite.Run(tc.name, func() {
 			require.Equal(suite.T(), tc.expected,
-				needEmitAndAdvance(tc.splitTxn, tc.committedTxnSize, tc.pendingTxnSize))
+				needEmitAndAdvance(tc.splitTxn, tc.committedTxnSize, tc.pendingTxnSize, maxUpdateIntervalSize))
 		})
 	}
 }
@@ -130,7 +139,7 @@ func (suite *tableSinkAdvancerSuite) TestAdvanceTableSinkWithBatchID() {
 	advancer := newTableSinkAdvancer(task, true, memoryQuota, 512)
 	require.NotNil(suite.T(), advancer)
 
-	err := advanceTableSinkWithBatchID(task, 2, 256, 1, memoryQuota)
+	err := advanceTableSinkWithBatchID(task, task.tableSink, 2, 256, 1, memoryQuota)
 	require.NoError(suite.T(), err)
 
 	expectedResolvedTs := model.NewResolvedTs(2)
@@ -147,7 +156,7 @@ func (suite *tableSinkAdvancerSuite) TestAdvanceTableSink() {
 	advancer := newTableSinkAdvancer(task, true, memoryQuota, 512)
 	require.NotNil(suite.T(), advancer)
 
-	err := advanceTableSink(task, 2, 256, memoryQuota)
+	err := advanceTableSink(task, task.tableSink, 2, 256, memoryQuota)
 	require.NoError(suite.T(), err)
 
 	expectedResolvedTs := model.NewResolvedTs(2)
@@ -155,6 +164,156 @@ func (suite *tableSinkAdvancerSuite) TestAdvanceTableSink() {
 	require.Equal(suite.T(), expectedResolvedTs, checkpointTs)
 }
 
+type transientTestError struct{}
+
+func (transientTestError) Error() string   { return "transient test error" }
+func (transientTestError) Transient() bool { return true }
+
+// flakyTableSink fails to update resolved ts with err for the first failures times.
+type flakyTableSink struct {
+	tablesink.TableSink
+	err      error
+	failures int
+	calls    int
+}
+
+func (s *flakyTableSink) UpdateResolvedTs(ts model.ResolvedTs) error {
+	s.calls++
+	if s.calls <= s.failures {
+		return tablesink.NewSinkInternalError(s.err)
+	}
+	return s.TableSink.UpdateResolvedTs(ts)
+}
+
+// Updating resolved ts is never retried, because the table sink takes the
+// resolved ts even if it fails, and a retry would write nothing.
+func (suite *tableSinkAdvancerSuite) TestAdvanceTableSinkNeverRetry() {
+	emitRetryBackoff = time.Millisecond
+	defer func() { emitRetryBackoff = 10 * time.Millisecond }()
+
+	task, _ := suite.genSinkTask()
+	memoryQuota := suite.genMemQuota(512)
+	defer memoryQuota.Close()
+	advancer := newTableSinkAdvancer(task, true, memoryQuota, 512)
+	advancer.emitRetryLimit = 3
+	flaky := &flakyTableSink{
+		TableSink: task.tableSink.tableSink.s,
+		err:       cerrors.ErrMySQLConnectionError.GenWithStackByArgs(),
+		failures:  1,
+	}
+	task.tableSink.tableSink.s = flaky
+
+	err := advanceTableSink(task, task.tableSink, 2, 256, memoryQuota)
+	require.ErrorContains(suite.T(), err, "ErrMySQLConnectionError")
+	require.Equal(suite.T(), 1, flaky.calls)
+
+	flaky.calls = 0
+	err = advanceTableSinkWithBatchID(task, task.tableSink, 3, 256, 1, memoryQuota)
+	require.ErrorContains(suite.T(), err, "ErrMySQLConnectionError")
+	require.Equal(suite.T(), 1, flaky.calls)
+
+	// The advancer doesn't retry either.
+	flaky.calls = 0
+	flaky.failures = 1
+	advancer.lastPos = sorter.Position{StartTs: 3, CommitTs: 4}
+	advancer.tryMoveToNextTxn(4)
+	require.Error(suite.T(), advancer.advance(false))
+	require.Equal(suite.T(), 1, flaky.calls)
+}
+
+func TestIsTransientEmitError(t *testing.T) {
+	t.Parallel()
+
+	require.False(t, isTransientEmitError(nil))
+	require.False(t, isTransientEmitError(context.Canceled))
+	require.False(t, isTransientEmitError(errors.New("dead dmlSink")))
+	require.False(t, isTransientEmitError(cerrors.ErrSinkURIInvalid.GenWithStackByArgs()))
+	require.True(t, isTransientEmitError(transientTestError{}))
+	require.True(t, isTransientEmitError(cerrors.ErrMySQLConnectionError.GenWithStackByArgs()))
+	require.True(t, isTransientEmitError(errors.Trace(
+		tablesink.NewSinkInternalError(cerrors.ErrKafkaSendMessage.GenWithStackByArgs()))))
+	// Errors returned by table sinks.
+	require.True(t, isTransientEmitError(
+		tablesink.NewSinkInternalError(cerrors.ErrMySQLConnectionError.Wrap(errors.New("i/o timeout")))))
+	require.True(t, isTransientEmitError(
+		tablesink.NewSinkInternalError(errors.Trace(transientTestError{}))))
+	require.False(t, isTransientEmitError(
+		tablesink.NewSinkInternalError(errors.Trace(context.Canceled))))
+	require.False(t, isTransientEmitError(
+		tablesink.NewSinkInternalError(cerrors.ErrSinkURIInvalid.GenWithStackByArgs())))
+	require.False(t, isTransientEmitError(tablesink.NewSinkInternalError(errors.New("table sink cleared"))))
+}
+
+// flakyEmitter fails to append events with err for the first failures times.
+type flakyEmitter struct {
+	eventEmitter
+	err      error
+	failures int
+	calls    int
+}
+
+func (e *flakyEmitter) appendRowChangedEvents(events ...*model.RowChangedEvent) error {
+	e.calls++
+	if e.calls <= e.failures {
+		return e.err
+	}
+	return e.eventEmitter.appendRowChangedEvents(events...)
+}
+
+func (suite *tableSinkAdvancerSuite) TestAdvanceAppendWithRetry() {
+	emitRetryBackoff = time.Millisecond
+	defer func() { emitRetryBackoff = 10 * time.Millisecond }()
+
+	task, sink := suite.genSinkTask()
+	memoryQuota := suite.genMemQuota(512)
+	defer memoryQuota.Close()
+	advancer := newTableSinkAdvancer(task, true, memoryQuota, 512)
+	advancer.emitRetryLimit = 3
+	flaky := &flakyEmitter{
+		eventEmitter: task.tableSink,
+		err:          tablesink.NewSinkInternalError(cerrors.ErrMySQLConnectionError.GenWithStackByArgs()),
+		failures:     1,
+	}
+	advancer.emitter = flaky
+
+	advancer.appendEvents([]*model.RowChangedEvent{{CommitTs: 2}}, 256)
+	advancer.lastPos = sorter.Position{StartTs: 1, CommitTs: 2}
+	advancer.tryMoveToNextTxn(2)
+	require.NoError(suite.T(), advancer.advance(false))
+	require.Equal(suite.T(), 2, flaky.calls)
+	require.Len(suite.T(), sink.GetEvents(), 1)
+}
+
+func (suite *tableSinkAdvancerSuite) TestAdvanceAppendRetryCanceled() {
+	emitRetryBackoff = time.Hour
+	defer func() { emitRetryBackoff = 10 * time.Millisecond }()
+
+	task, sink := suite.genSinkTask()
+	memoryQuota := suite.genMemQuota(512)
+	defer memoryQuota.Close()
+	advancer := newTableSinkAdvancer(task, true, memoryQuota, 512)
+	advancer.emitRetryLimit = 3
+	flaky := &flakyEmitter{
+		eventEmitter: task.tableSink,
+		err:          tablesink.NewSinkInternalError(cerrors.ErrMySQLConnectionError.GenWithStackByArgs()),
+		failures:     10,
+	}
+	advancer.emitter = flaky
+
+	// The backoff is interrupted once the context is canceled, and the emit
+	// error is returned.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	advancer.ctx = ctx
+	advancer.appendEvents([]*model.RowChangedEvent{{CommitTs: 2}}, 256)
+	advancer.lastPos = sorter.Position{StartTs: 1, CommitTs: 2}
+	advancer.tryMoveToNextTxn(2)
+	err := advancer.advance(false)
+	require.Equal(suite.T(), flaky.err, err)
+	require.Equal(suite.T(), 1, flaky.calls)
+	require.Len(suite.T(), sink.GetEvents(), 0)
+}
+
 func (suite *tableSinkAdvancerSuite) TestNewTableSinkAdvancer() {
 	task, _ := suite.genSinkTask()
 	memoryQuota := suite.genMemQuota(512)
@@ -253,6 +412,32 @@ func (suite *tableSinkAdvancerSuite) TestTryMoveMoveToNextTxn() {
 	require.Equal(suite.T(), uint64(2), advancer.currTxnCommitTs)
 }
 
+func (suite *tableSinkAdvancerSuite) TestPeakMemExcludesRecordedEvents() {
+	memoryQuota := suite.genMemQuota(768)
+	defer memoryQuota.Close()
+	task, _ := suite.genSinkTask()
+	advancer := newTableSinkAdvancer(task, true, memoryQuota, 768)
+	require.NotNil(suite.T(), advancer)
+
+	// Buffer 2 transactions.
+	advancer.appendEvents([]*model.RowChangedEvent{{CommitTs: 1}}, 256)
+	advancer.tryMoveToNextTxn(1)
+	advancer.appendEvents([]*model.RowChangedEvent{{CommitTs: 2}}, 256)
+	advancer.tryMoveToNextTxn(2)
+	require.Equal(suite.T(), uint64(512), advancer.peakMem)
+
+	// All buffered events are recorded after advancing.
+	advancer.lastPos = sorter.Position{StartTs: 1, CommitTs: 2}
+	require.NoError(suite.T(), advancer.advance(false))
+	require.Equal(suite.T(), uint64(0), advancer.committedTxnSize)
+	require.Equal(suite.T(), uint64(0), advancer.pendingTxnSize)
+
+	// The used memory keeps growing, but the peak memory doesn't.
+	advancer.appendEvents([]*model.RowChangedEvent{{CommitTs: 3}}, 128)
+	require.Equal(suite.T(), uint64(640), advancer.usedMem)
+	require.Equal(suite.T(), uint64(512), advancer.peakMem)
+}
+
 // Test Scenario:
 // When we meet a commit fence, we should flush all the events and advance the
 // table sink with the commit ts of the commit fence.
@@ -509,6 +694,42 @@ func (suite *tableSinkAdvancerSuite) TestLastTimeAdvanceDifferentCommitTsEventsW
 	require.Equal(suite.T(), uint64(1), batchID.Load())
 }
 
+// Test Scenario:
+// The task stops in the middle of its first transaction, and we do **not**
+// support split txn. The buffered events should still be flushed and their
+// memory should be recorded, otherwise the memory quota will be leaked.
+func (suite *tableSinkAdvancerSuite) TestLastTimeAdvanceInFirstTxnWithoutSplitTxn() {
+	memoryQuota := suite.genMemQuota(768)
+	defer memoryQuota.Close()
+	task, sink := suite.genSinkTask()
+	// Do not split txn.
+	advancer := newTableSinkAdvancer(task, false, memoryQuota, 768)
+	require.NotNil(suite.T(), advancer)
+	advancer.lastPos = sorter.Position{StartTs: 0, CommitTs: 1}
+
+	// 1. append 2 events with commit ts 2, the transaction isn't finished.
+	advancer.tryMoveToNextTxn(2)
+	for i := 0; i < 2; i++ {
+		advancer.appendEvents([]*model.RowChangedEvent{
+			{CommitTs: 2},
+		}, 256)
+	}
+	require.Equal(suite.T(), uint64(512), advancer.pendingTxnSize)
+
+	// 2. advance for the last time.
+	err := advancer.lastTimeAdvance()
+	require.NoError(suite.T(), err)
+	require.Empty(suite.T(), advancer.events, "all events should be flushed")
+	require.Equal(suite.T(), uint64(0), advancer.pendingTxnSize)
+	// The transaction isn't finished, so nothing can be emitted.
+	require.Empty(suite.T(), sink.GetEvents())
+
+	// 3. The memory can be released after the transaction is emitted.
+	require.Equal(suite.T(), uint64(768), memoryQuota.GetUsedBytes())
+	memoryQuota.Release(suite.testSpan, model.NewResolvedTs(2))
+	require.Equal(suite.T(), uint64(256), memoryQuota.GetUsedBytes())
+}
+
 // Test Scenario:
 // We receive some events and exceed the available memory quota.
 // We should advance the table sink and also make up the difference
@@ -718,6 +939,178 @@ func (suite *tableSinkAdvancerSuite) TestTryAdvanceAndForceAcquireWithoutSplitTx
 	require.Equal(suite.T(), uint64(1), batchID.Load())
 }
 
+// Test Scenario:
+// We receive a big transaction with a tiny memory quota and do not support
+// split txn. The count of consecutive force acquires should climb until a
+// step within the quota.
+func (suite *tableSinkAdvancerSuite) TestTryAdvanceCountConsecutiveForceAcquires() {
+	memoryQuota := suite.genMemQuota(requestMemSize)
+	defer memoryQuota.Close()
+	task, _ := suite.genSinkTask()
+	advancer := newTableSinkAdvancer(task, false, memoryQuota, requestMemSize)
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test"})
+	advancer.forceAcquireGauge = gauge
+
+	advancer.tryMoveToNextTxn(2)
+	for i := 1; i <= 3; i++ {
+		advancer.appendEvents([]*model.RowChangedEvent{
+			{CommitTs: 2},
+		}, requestMemSize)
+		require.NoError(suite.T(), advancer.tryAdvanceAndAcquireMem(false, false))
+		require.Equal(suite.T(), i, advancer.consecutiveForceAcquires)
+		require.Equal(suite.T(), float64(i), testutil.ToFloat64(gauge))
+	}
+
+	// The memory is enough for the finished transaction.
+	advancer.lastPos = sorter.Position{StartTs: 1, CommitTs: 2}
+	require.NoError(suite.T(), advancer.tryAdvanceAndAcquireMem(false, true))
+	require.Equal(suite.T(), 0, advancer.consecutiveForceAcquires)
+	require.Equal(suite.T(), float64(0), testutil.ToFloat64(gauge))
+}
+
+// Test Scenario:
+// We receive a transaction with mixed types of events. They should be
+// counted by type when they are emitted to the table sink.
+func (suite *tableSinkAdvancerSuite) TestAdvanceCountEventTypes() {
+	memoryQuota := suite.genMemQuota(requestMemSize)
+	defer memoryQuota.Close()
+	task, sink := suite.genSinkTask()
+	advancer := newTableSinkAdvancer(task, true, memoryQuota, requestMemSize)
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test"}, []string{"type"})
+	advancer.eventTypeCounter = counter
+
+	cols := []*model.Column{{Name: "a", Value: 1}}
+	advancer.tryMoveToNextTxn(2)
+	advancer.appendEvents([]*model.RowChangedEvent{
+		{CommitTs: 2, Columns: cols},
+		{CommitTs: 2, Columns: cols},
+		{CommitTs: 2, PreColumns: cols, Columns: cols},
+		{CommitTs: 2, PreColumns: cols},
+		{CommitTs: 2, Columns: cols},
+	}, 256)
+	advancer.lastPos = sorter.Position{StartTs: 1, CommitTs: 2}
+	require.NoError(suite.T(), advancer.advance(false))
+	require.Len(suite.T(), sink.GetEvents(), 5)
+
+	require.Equal(suite.T(), float64(3), testutil.ToFloat64(counter.WithLabelValues("insert")))
+	require.Equal(suite.T(), float64(1), testutil.ToFloat64(counter.WithLabelValues("update")))
+	require.Equal(suite.T(), float64(1), testutil.ToFloat64(counter.WithLabelValues("delete")))
+}
+
+// Test Scenario:
+// When only inserts are allowed, updates and deletes should be dropped, and
+// the table sink should still be advanced even if all events of a
+// transaction are dropped.
+func (suite *tableSinkAdvancerSuite) TestAdvanceWithAllowedEventTypes() {
+	memoryQuota := suite.genMemQuota(768)
+	defer memoryQuota.Close()
+	task, sink := suite.genSinkTask()
+	advancer := newTableSinkAdvancer(task, true, memoryQuota, 768)
+	advancer.allowedEventTypes = eventTypeInsert
+
+	cols := []*model.Column{{Name: "a", Value: 1}}
+	insert := &model.RowChangedEvent{StartTs: 1, CommitTs: 2, Columns: cols}
+	advancer.tryMoveToNextTxn(2)
+	advancer.appendEvents([]*model.RowChangedEvent{
+		{StartTs: 1, CommitTs: 2, PreColumns: cols, Columns: cols},
+		insert,
+		{StartTs: 1, CommitTs: 2, PreColumns: cols},
+	}, 384)
+	advancer.lastPos = sorter.Position{StartTs: 1, CommitTs: 2}
+	require.NoError(suite.T(), advancer.advance(false))
+	events := sink.GetEvents()
+	require.Len(suite.T(), events, 1)
+	require.Same(suite.T(), insert, events[0].Event)
+	require.Equal(suite.T(), model.NewResolvedTs(2), task.tableSink.tableSink.resolvedTs)
+
+	// All events of the transaction are dropped.
+	advancer.tryMoveToNextTxn(3)
+	advancer.appendEvents([]*model.RowChangedEvent{
+		{StartTs: 2, CommitTs: 3, PreColumns: cols, Columns: cols},
+		{StartTs: 2, CommitTs: 3, PreColumns: cols},
+	}, 256)
+	advancer.lastPos = sorter.Position{StartTs: 2, CommitTs: 3}
+	require.NoError(suite.T(), advancer.advance(false))
+	require.Len(suite.T(), sink.GetEvents(), 1)
+	require.Equal(suite.T(), model.NewResolvedTs(3), task.tableSink.tableSink.resolvedTs)
+	require.Equal(suite.T(), uint64(640), advancer.usedMem)
+
+	// The split marker of a dropped event is kept by the next insert of the
+	// same transaction, but not by the inserts of other transactions.
+	splitInsert := &model.RowChangedEvent{StartTs: 3, CommitTs: 4, Columns: cols}
+	nextInsert := &model.RowChangedEvent{StartTs: 5, CommitTs: 6, Columns: cols}
+	advancer.tryMoveToNextTxn(4)
+	advancer.appendEvents([]*model.RowChangedEvent{
+		{StartTs: 3, CommitTs: 4, PreColumns: cols, Columns: cols, SplitTxn: true},
+		splitInsert,
+	}, 256)
+	advancer.tryMoveToNextTxn(5)
+	advancer.appendEvents([]*model.RowChangedEvent{
+		{StartTs: 4, CommitTs: 5, PreColumns: cols, SplitTxn: true},
+		{StartTs: 4, CommitTs: 5, PreColumns: cols},
+	}, 256)
+	advancer.tryMoveToNextTxn(6)
+	advancer.appendEvents([]*model.RowChangedEvent{nextInsert}, 128)
+	advancer.lastPos = sorter.Position{StartTs: 5, CommitTs: 6}
+	require.NoError(suite.T(), advancer.advance(false))
+	events = sink.GetEvents()
+	require.Len(suite.T(), events, 3)
+	require.Same(suite.T(), splitInsert, events[1].Event)
+	require.True(suite.T(), splitInsert.SplitTxn)
+	require.Same(suite.T(), nextInsert, events[2].Event)
+	require.False(suite.T(), nextInsert.SplitTxn)
+}
+
+// Test Scenario:
+// We receive a transaction larger than maxNonSplitTxnSize and do not support
+// split txn. We should return an error instead of buffering it.
+func (suite *tableSinkAdvancerSuite) TestTryAdvanceWithOversizedNonSplitTxn() {
+	memoryQuota := suite.genMemQuota(768)
+	defer memoryQuota.Close()
+	task, sink := suite.genSinkTask()
+	advancer := newTableSinkAdvancer(task, false, memoryQuota, 768)
+	require.NotNil(suite.T(), advancer)
+	advancer.maxNonSplitTxnSize = 768
+
+	advancer.tryMoveToNextTxn(2)
+	for i := 0; i < 3; i++ {
+		advancer.appendEvents([]*model.RowChangedEvent{
+			{CommitTs: 2},
+		}, 256)
+		err := advancer.tryAdvanceAndAcquireMem(false, false)
+		require.NoError(suite.T(), err)
+	}
+	advancer.appendEvents([]*model.RowChangedEvent{
+		{CommitTs: 2},
+	}, 256)
+	err := advancer.tryAdvanceAndAcquireMem(false, false)
+	require.True(suite.T(), cerrors.ErrSinkTxnTooLarge.Equal(err))
+	require.Len(suite.T(), sink.GetEvents(), 0)
+	require.Equal(suite.T(), uint64(1024), advancer.pendingTxnSize)
+}
+
+// Test Scenario:
+// We receive a large transaction and do not support split txn, but there is
+// no limit of non-split transactions. We should keep buffering it.
+func (suite *tableSinkAdvancerSuite) TestTryAdvanceWithLargeNonSplitTxnWithoutLimit() {
+	memoryQuota := suite.genMemQuota(768)
+	defer memoryQuota.Close()
+	task, sink := suite.genSinkTask()
+	advancer := newTableSinkAdvancer(task, false, memoryQuota, 768)
+	require.NotNil(suite.T(), advancer)
+
+	advancer.tryMoveToNextTxn(2)
+	for i := 0; i < 4; i++ {
+		advancer.appendEvents([]*model.RowChangedEvent{
+			{CommitTs: 2},
+		}, 256)
+		err := advancer.tryAdvanceAndAcquireMem(false, false)
+		require.NoError(suite.T(), err)
+	}
+	require.Len(suite.T(), sink.GetEvents(), 0)
+	require.Equal(suite.T(), uint64(1024), advancer.pendingTxnSize)
+}
+
 // Test Scenario:
 // We receive some events and support split txn.
 // We should advance the table sink and block acquire memory for next txn.
@@ -784,3 +1177,277 @@ func (suite *tableSinkAdvancerSuite) TestTryAdvanceAndBlockAcquireWithSplitTxn()
 	}()
 	wg.Wait()
 }
+
+// Test Scenario:
+// When sortByPK is enabled, events of the same transaction should be emitted
+// in primary key order, and the memory accounting should not change.
+func (suite *tableSinkAdvancerSuite) TestAdvanceWithSortByPK() {
+	memoryQuota := suite.genMemQuota(768)
+	defer memoryQuota.Close()
+	task, sink := suite.genSinkTask()
+	advancer := newTableSinkAdvancer(task, true, memoryQuota, 768)
+	advancer.sortByPK = true
+
+	genRow := func(commitTs uint64, pk int64) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			StartTs:  commitTs - 1,
+			CommitTs: commitTs,
+			Columns: []*model.Column{
+				{Name: "id", Value: pk, Flag: model.PrimaryKeyFlag | model.HandleKeyFlag},
+			},
+		}
+	}
+	// PK-less events must keep their original order.
+	noPK1 := &model.RowChangedEvent{StartTs: 2, CommitTs: 3, Columns: []*model.Column{{Name: "a", Value: 2}}}
+	noPK2 := &model.RowChangedEvent{StartTs: 2, CommitTs: 3, Columns: []*model.Column{{Name: "a", Value: 1}}}
+
+	advancer.appendEvents([]*model.RowChangedEvent{genRow(2, 10), genRow(2, 9), genRow(2, 1)}, 384)
+	advancer.tryMoveToNextTxn(2)
+	advancer.appendEvents([]*model.RowChangedEvent{noPK1, noPK2}, 256)
+	advancer.tryMoveToNextTxn(3)
+	require.Equal(suite.T(), uint64(640), advancer.usedMem)
+
+	advancer.lastPos = sorter.Position{StartTs: 2, CommitTs: 3}
+	require.NoError(suite.T(), advancer.advance(false))
+	require.Equal(suite.T(), uint64(640), advancer.usedMem)
+
+	events := sink.GetEvents()
+	require.Len(suite.T(), events, 5)
+	var pks []interface{}
+	for _, e := range events[:3] {
+		pks = append(pks, e.Event.Columns[0].Value)
+	}
+	require.Equal(suite.T(), []interface{}{int64(1), int64(9), int64(10)}, pks)
+	require.Same(suite.T(), noPK1, events[3].Event)
+	require.Same(suite.T(), noPK2, events[4].Event)
+}
+
+func TestSortEventsByPrimaryKeyKeepsUnsafeTxns(t *testing.T) {
+	t.Parallel()
+
+	pkFlag := model.PrimaryKeyFlag | model.HandleKeyFlag
+	genCols := func(pk int64, pkFlag model.ColumnFlagType, uk string) []*model.Column {
+		return []*model.Column{
+			{Name: "id", Value: pk, Flag: pkFlag},
+			{Name: "uk", Value: uk, Flag: model.UniqueKeyFlag},
+		}
+	}
+	genRow := func(commitTs uint64, pre, cols []*model.Column) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			StartTs:    commitTs - 1,
+			CommitTs:   commitTs,
+			PreColumns: pre,
+			Columns:    cols,
+		}
+	}
+	pkOnly := func(pk int64, flag model.ColumnFlagType) []*model.Column {
+		return []*model.Column{{Name: "id", Value: pk, Flag: flag}}
+	}
+
+	testCases := []struct {
+		name   string
+		events []*model.RowChangedEvent
+	}{
+		{
+			// Swapping them breaks the unique key uk.
+			name: "unique key",
+			events: []*model.RowChangedEvent{
+				genRow(2, genCols(5, pkFlag, "x"), nil),
+				genRow(2, nil, genCols(3, pkFlag, "x")),
+			},
+		},
+		{
+			name: "primary key is not the handle",
+			events: []*model.RowChangedEvent{
+				genRow(2, nil, pkOnly(5, model.PrimaryKeyFlag)),
+				genRow(2, nil, pkOnly(3, model.PrimaryKeyFlag)),
+			},
+		},
+		{
+			// Moving the insert before the update duplicates pk 1.
+			name: "update changes the primary key",
+			events: []*model.RowChangedEvent{
+				genRow(2, pkOnly(1, pkFlag), pkOnly(5, pkFlag)),
+				genRow(2, nil, pkOnly(1, pkFlag)),
+			},
+		},
+	}
+	for _, tc := range testCases {
+		expected := append([]*model.RowChangedEvent(nil), tc.events...)
+		sortEventsByPrimaryKey(tc.events)
+		require.Equal(t, expected, tc.events, tc.name)
+	}
+
+	// Other transactions are still sorted.
+	sortable := genRow(3, nil, pkOnly(2, pkFlag))
+	events := []*model.RowChangedEvent{
+		genRow(2, genCols(5, pkFlag, "x"), nil),
+		genRow(2, nil, genCols(3, pkFlag, "x")),
+		genRow(3, nil, pkOnly(7, pkFlag)),
+		sortable,
+	}
+	expected := []*model.RowChangedEvent{events[0], events[1], sortable, events[2]}
+	sortEventsByPrimaryKey(events)
+	require.Equal(t, expected, events)
+}
+
+// Test Scenario:
+// When coalesceByPK is enabled, adjacent events on the same primary key of one
+// transaction should be merged, and events of different transactions should
+// never be merged.
+func (suite *tableSinkAdvancerSuite) TestAdvanceWithCoalesceByPK() {
+	memoryQuota := suite.genMemQuota(768)
+	defer memoryQuota.Close()
+	task, sink := suite.genSinkTask()
+	advancer := newTableSinkAdvancer(task, true, memoryQuota, 768)
+	advancer.coalesceByPK = true
+
+	genCols := func(pk int64, v string) []*model.Column {
+		return []*model.Column{
+			{Name: "id", Value: pk, Flag: model.PrimaryKeyFlag},
+			{Name: "v", Value: v},
+		}
+	}
+	genRow := func(commitTs uint64, pre, cols []*model.Column) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			StartTs:    commitTs - 1,
+			CommitTs:   commitTs,
+			PreColumns: pre,
+			Columns:    cols,
+		}
+	}
+
+	// insert + update on pk 1, update + update on pk 2, insert + delete on pk 3.
+	insert1 := genRow(2, nil, genCols(1, "a"))
+	update2 := genRow(2, genCols(2, "a"), genCols(2, "b"))
+	advancer.appendEvents([]*model.RowChangedEvent{
+		insert1,
+		genRow(2, genCols(1, "a"), genCols(1, "b")),
+		update2,
+		genRow(2, genCols(2, "b"), genCols(2, "c")),
+		genRow(2, nil, genCols(3, "a")),
+		genRow(2, genCols(3, "a"), nil),
+	}, 384)
+	advancer.tryMoveToNextTxn(2)
+	// The update of pk 1 belongs to another transaction.
+	update1 := genRow(3, genCols(1, "b"), genCols(1, "c"))
+	advancer.appendEvents([]*model.RowChangedEvent{update1}, 128)
+	advancer.tryMoveToNextTxn(3)
+
+	advancer.lastPos = sorter.Position{StartTs: 2, CommitTs: 3}
+	require.NoError(suite.T(), advancer.advance(false))
+	require.Equal(suite.T(), uint64(512), advancer.usedMem)
+
+	events := sink.GetEvents()
+	require.Len(suite.T(), events, 3)
+	require.True(suite.T(), events[0].Event.IsInsert())
+	require.Equal(suite.T(), genCols(1, "b"), events[0].Event.Columns)
+	require.True(suite.T(), events[1].Event.IsUpdate())
+	require.Equal(suite.T(), genCols(2, "a"), events[1].Event.PreColumns)
+	require.Equal(suite.T(), genCols(2, "c"), events[1].Event.Columns)
+	require.Same(suite.T(), update1, events[2].Event)
+	// The original events must not be modified.
+	require.Equal(suite.T(), genCols(1, "a"), insert1.Columns)
+	require.Equal(suite.T(), genCols(2, "b"), update2.Columns)
+}
+
+// slowTableSink is a table sink which is slow to append events.
+type slowTableSink struct {
+	tablesink.TableSink
+	delay time.Duration
+}
+
+func (s *slowTableSink) AppendRowChangedEvents(rows ...*model.RowChangedEvent) {
+	time.Sleep(s.delay)
+	s.TableSink.AppendRowChangedEvents(rows...)
+}
+
+// Test Scenario:
+// When emitting events to the table sink is slow, the advancer should log it
+// with rate limiting.
+func (suite *tableSinkAdvancerSuite) TestAdvanceWithSlowEmit() {
+	// For observing the logs
+	zapcore, logs := observer.New(zap.WarnLevel)
+	conf := &log.Config{Level: "warn", File: log.FileLogConfig{}}
+	_, r, _ := log.InitLogger(conf)
+	logger := zap.New(zapcore)
+	restoreFn := log.ReplaceGlobals(logger, r)
+	defer restoreFn()
+
+	memoryQuota := suite.genMemQuota(768)
+	defer memoryQuota.Close()
+	task, sink := suite.genSinkTask()
+	task.tableSink.tableSink.s = &slowTableSink{
+		TableSink: task.tableSink.tableSink.s,
+		delay:     50 * time.Millisecond,
+	}
+	advancer := newTableSinkAdvancer(task, true, memoryQuota, 768)
+	advancer.slowEmitThreshold = 10 * time.Millisecond
+	advancer.slowEmitLogLimiter = rate.NewLimiter(rate.Every(time.Hour), 1)
+
+	emit := func(commitTs uint64) {
+		advancer.appendEvents([]*model.RowChangedEvent{
+			{StartTs: commitTs - 1, CommitTs: commitTs},
+			{StartTs: commitTs - 1, CommitTs: commitTs},
+		}, 256)
+		advancer.tryMoveToNextTxn(commitTs)
+		advancer.lastPos = sorter.Position{StartTs: commitTs - 1, CommitTs: commitTs}
+		require.NoError(suite.T(), advancer.advance(false))
+	}
+
+	// Emit twice, but only log once because of the rate limit.
+	emit(2)
+	emit(3)
+	require.Len(suite.T(), sink.GetEvents(), 4)
+	slowLogs := logs.FilterMessage("Emit events to table sink is too slow")
+	require.Equal(suite.T(), 1, slowLogs.Len())
+	require.Equal(suite.T(), int64(2), slowLogs.All()[0].ContextMap()["batchSize"])
+
+	// No log if the threshold is not set.
+	advancer.slowEmitThreshold = 0
+	advancer.slowEmitLogLimiter = rate.NewLimiter(rate.Every(time.Hour), 1)
+	emit(4)
+	require.Equal(suite.T(), 1, logs.FilterMessage("Emit events to table sink is too slow").Len())
+}
+
+// Test Scenario:
+// The batch size of the advancer should adapt to the emit latency within bounds.
+func (suite *tableSinkAdvancerSuite) TestAdvanceWithAdaptiveBatchSize() {
+	memoryQuota := suite.genMemQuota(768)
+	defer memoryQuota.Close()
+	task, _ := suite.genSinkTask()
+	slowSink := &slowTableSink{
+		TableSink: task.tableSink.tableSink.s,
+		delay:     50 * time.Millisecond,
+	}
+	task.tableSink.tableSink.s = slowSink
+	advancer := newTableSinkAdvancer(task, true, memoryQuota, 768)
+	advancer.batchSize = newAdaptiveBatchSize(256, 1024, 20*time.Millisecond)
+	require.Equal(suite.T(), uint64(512), advancer.batchSize.get())
+
+	emit := func(commitTs uint64) {
+		advancer.appendEvents([]*model.RowChangedEvent{
+			{StartTs: commitTs - 1, CommitTs: commitTs},
+		}, 256)
+		advancer.tryMoveToNextTxn(commitTs)
+		advancer.lastPos = sorter.Position{StartTs: commitTs - 1, CommitTs: commitTs}
+		require.NoError(suite.T(), advancer.advance(false))
+	}
+
+	// Slow emits shrink the batch size to the lower bound.
+	emit(2)
+	require.Equal(suite.T(), uint64(256), advancer.batchSize.get())
+	emit(3)
+	require.Equal(suite.T(), uint64(256), advancer.batchSize.get())
+	// Only one event is enough to trigger an emit now.
+	require.True(suite.T(), needEmitAndAdvance(true, 0, 256, advancer.batchSize.get()))
+
+	// Fast emits grow the batch size to the upper bound.
+	slowSink.delay = 0
+	emit(4)
+	require.Equal(suite.T(), uint64(512), advancer.batchSize.get())
+	emit(5)
+	require.Equal(suite.T(), uint64(1024), advancer.batchSize.get())
+	emit(6)
+	require.Equal(suite.T(), uint64(1024), advancer.batchSize.get())
+}
@@ -15,6 +15,7 @@ package sinkmanager
 
 import (
 	"context"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -22,13 +23,15 @@ import (
 	"github.com/pingcap/failpoint"
 	"github.com/pingcap/log"
 	"github.com/pingcap/tiflow/cdc/model"
-	"github.com/pingcap/tiflow/cdc/processor/memquota"
 	"github.com/pingcap/tiflow/cdc/processor/sourcemanager"
 	"github.com/pingcap/tiflow/cdc/processor/sourcemanager/sorter"
+	"github.com/pingcap/tiflow/cdc/processor/tablepb"
 	"github.com/pingcap/tiflow/cdc/sink/tablesink"
+	"github.com/pingcap/tiflow/pkg/config"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/tikv/client-go/v2/oracle"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 )
 
 // batchID is used to advance table sink with a given CommitTs, even if not all
@@ -57,27 +60,100 @@ import (
 // strictly increasing one by one.
 var batchID atomic.Uint64
 
+// slowEmitLogInterval is the minimal interval between two slow emit logs of
+// one worker.
+const slowEmitLogInterval = 10 * time.Second
+
+// defaultSlowIterCloseThreshold indicates how long closing the iterator of a
+// sink task is considered slow and should be logged.
+const defaultSlowIterCloseThreshold = time.Second
+
+// defaultSlowEmitThreshold indicates how long an emit to the table sink is
+// considered slow and should be logged.
+const defaultSlowEmitThreshold = time.Second
+
+// defaultMaxTaskDuration indicates how long a sink task can run before it
+// yields, so that a busy table can't occupy a worker for too long.
+const defaultMaxTaskDuration = 10 * time.Second
+
+// progressReportInterval is the minimal interval between two progress reports
+// of one sink task. It's a variable for tests.
+var progressReportInterval = time.Second
+
 type sinkWorker struct {
 	changefeedID  model.ChangeFeedID
 	sourceManager *sourcemanager.SourceManager
-	sinkMemQuota  *memquota.MemQuota
-	redoMemQuota  *memquota.MemQuota
+	sinkMemQuota  TableMemQuota
+	redoMemQuota  MemQuota
 	eventCache    *redoEventCache
 	// splitTxn indicates whether to split the transaction into multiple batches.
 	splitTxn bool
+	// sortByPK indicates whether to sort events of one transaction by primary
+	// key before emitting them, which can reduce page splits for some downstreams.
+	sortByPK bool
+	// coalesceByPK indicates whether to merge adjacent changes on the same
+	// primary key of one transaction before emitting them, which can reduce
+	// the number of rows written to downstreams.
+	coalesceByPK bool
+	// allowedEventTypes indicates the types of events to be emitted to the
+	// table sink, e.g. only inserts for append-only sinks. Zero means all.
+	allowedEventTypes eventTypeSet
+	// slowEmitThreshold indicates how long an emit to the table sink is
+	// considered slow and should be logged. Zero means never log.
+	slowEmitThreshold  time.Duration
+	slowEmitLogLimiter *rate.Limiter
+	// slowIterCloseThreshold indicates how long closing the iterator of a task
+	// is considered slow and should be logged. Zero means never log.
+	slowIterCloseThreshold time.Duration
+	// emitBaselineResolvedTs indicates whether to emit a resolved ts at the
+	// lower bound of the first task of a table before any events.
+	emitBaselineResolvedTs bool
+	// maxTaskDuration indicates how long a task can run before it yields at
+	// the next transaction boundary, so that other tables can get a turn.
+	// Unlike the deadline of a task, it's not an error. Zero means never yield.
+	maxTaskDuration time.Duration
+	// progressCh is optional. If it's not nil, the progress of tables is sent to
+	// it periodically during tasks. The progress is dropped if it's full.
+	// NOTE: the sink manager has no consumer of it yet, only tests set it.
+	progressCh chan<- TableProgress
+	// readAhead indicates how many events can be prefetched from the source
+	// manager in background. Zero means fetching events synchronously.
+	readAhead int
+	// maxNonSplitTxnSize indicates the hard limit of buffered bytes of one
+	// transaction if splitTxn is false. Zero means no limit.
+	maxNonSplitTxnSize uint64
+	// emitRetryLimit indicates how many times to retry emitting events to
+	// the table sink if the error is transient.
+	emitRetryLimit uint
+	// batchSize is optional. If it's not nil, the bytes buffered before an emit
+	// are tuned by the latency of recent emits, instead of maxUpdateIntervalSize.
+	batchSize *adaptiveBatchSize
+	// emitter is optional. If it's not nil, events and resolved ts are emitted
+	// by it instead of the table sink of the task, which is used by tests.
+	emitter eventEmitter
+
+	// pauseMu protects paused and pauseStateChanged.
+	pauseMu sync.Mutex
+	// paused indicates whether the worker stops picking new tasks.
+	paused bool
+	// pauseStateChanged is closed and recreated once the worker is paused or resumed.
+	pauseStateChanged chan struct{}
 
 	// Metrics.
 	metricRedoEventCacheHit  prometheus.Counter
 	metricRedoEventCacheMiss prometheus.Counter
 	metricOutputEventCountKV prometheus.Counter
+	metricMemoryRefundRatio  prometheus.Gauge
+	metricIterCloseDuration  prometheus.Observer
+	metricTaskYieldCount     prometheus.Counter
 }
 
 // newSinkWorker creates a new sink worker.
 func newSinkWorker(
 	changefeedID model.ChangeFeedID,
 	sourceManager *sourcemanager.SourceManager,
-	sinkQuota *memquota.MemQuota,
-	redoQuota *memquota.MemQuota,
+	sinkQuota TableMemQuota,
+	redoQuota MemQuota,
 	eventCache *redoEventCache,
 	splitTxn bool,
 ) *sinkWorker {
@@ -89,23 +165,118 @@ func newSinkWorker(
 		eventCache:    eventCache,
 		splitTxn:      splitTxn,
 
+		emitRetryLimit:    config.DefaultEmitRetryLimit,
+		pauseStateChanged: make(chan struct{}),
+
+		slowEmitThreshold:      defaultSlowEmitThreshold,
+		slowEmitLogLimiter:     rate.NewLimiter(rate.Every(slowEmitLogInterval), 1),
+		slowIterCloseThreshold: defaultSlowIterCloseThreshold,
+		maxTaskDuration:        defaultMaxTaskDuration,
+		emitBaselineResolvedTs: true,
+
 		metricRedoEventCacheHit:  RedoEventCacheAccess.WithLabelValues(changefeedID.Namespace, changefeedID.ID, "hit"),
 		metricRedoEventCacheMiss: RedoEventCacheAccess.WithLabelValues(changefeedID.Namespace, changefeedID.ID, "miss"),
 		metricOutputEventCountKV: outputEventCount.WithLabelValues(changefeedID.Namespace, changefeedID.ID, "kv"),
+		metricMemoryRefundRatio:  MemoryRefundRatio.WithLabelValues(changefeedID.Namespace, changefeedID.ID),
+		metricIterCloseDuration:  TableSinkIteratorCloseDuration.WithLabelValues(changefeedID.Namespace, changefeedID.ID),
+		metricTaskYieldCount:     TableSinkTaskYieldCount.WithLabelValues(changefeedID.Namespace, changefeedID.ID),
+	}
+}
+
+// closeIter closes the iterator of a task, and reports how long it takes.
+func (w *sinkWorker) closeIter(span *tablepb.Span, iter interface{ Close() error }) {
+	start := time.Now()
+	err := iter.Close()
+	duration := time.Since(start)
+	w.metricIterCloseDuration.Observe(duration.Seconds())
+	if err != nil {
+		log.Error("Sink worker fails to close iterator",
+			zap.String("namespace", w.changefeedID.Namespace),
+			zap.String("changefeed", w.changefeedID.ID),
+			zap.Stringer("span", span),
+			zap.Error(err))
 	}
+	if w.slowIterCloseThreshold > 0 && duration > w.slowIterCloseThreshold {
+		log.Warn("Sink worker closes iterator too slowly",
+			zap.String("namespace", w.changefeedID.Namespace),
+			zap.String("changefeed", w.changefeedID.ID),
+			zap.Stringer("span", span),
+			zap.Duration("duration", duration),
+			zap.Duration("threshold", w.slowIterCloseThreshold))
+	}
+}
+
+// reportProgress sends the progress to progressCh without blocking.
+func (w *sinkWorker) reportProgress(progress TableProgress) {
+	if w.progressCh == nil {
+		return
+	}
+	select {
+	case w.progressCh <- progress:
+	default:
+	}
+}
+
+// emitterFor returns the emitter of events of the task.
+func (w *sinkWorker) emitterFor(task *sinkTask) eventEmitter {
+	if w.emitter != nil {
+		return w.emitter
+	}
+	return task.tableSink
+}
+
+// pause makes the worker stop picking new tasks, without closing it.
+// The task being handled isn't affected.
+func (w *sinkWorker) pause() {
+	w.setPaused(true)
+}
+
+// resume makes a paused worker pick new tasks again.
+func (w *sinkWorker) resume() {
+	w.setPaused(false)
+}
+
+func (w *sinkWorker) setPaused(paused bool) {
+	w.pauseMu.Lock()
+	defer w.pauseMu.Unlock()
+	if w.paused == paused {
+		return
+	}
+	w.paused = paused
+	close(w.pauseStateChanged)
+	w.pauseStateChanged = make(chan struct{})
+}
+
+func (w *sinkWorker) pauseState() (bool, <-chan struct{}) {
+	w.pauseMu.Lock()
+	defer w.pauseMu.Unlock()
+	return w.paused, w.pauseStateChanged
 }
 
 func (w *sinkWorker) handleTasks(ctx context.Context, taskChan <-chan *sinkTask) error {
 	failpoint.Inject("SinkWorkerTaskHandlePause", func() { <-ctx.Done() })
 	for {
+		paused, pauseStateChanged := w.pauseState()
+		// Don't receive from taskChan if the worker is paused,
+		// so that tasks are queued up in the channel.
+		var tasks <-chan *sinkTask
+		if !paused {
+			tasks = taskChan
+		}
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case task := <-taskChan:
+		case <-pauseStateChanged:
+		case task := <-tasks:
 			err := w.handleTask(ctx, task)
 			failpoint.Inject("SinkWorkerTaskError", func() {
 				err = errors.New("SinkWorkerTaskError")
 			})
+			// The task has been stopped and its callback has been performed,
+			// it's OK to handle next tasks.
+			if _, ok := errors.Cause(err).(taskDeadlineExceededError); ok {
+				continue
+			}
 			if err != nil {
 				return err
 			}
@@ -114,11 +285,60 @@ func (w *sinkWorker) handleTasks(ctx context.Context, taskChan <-chan *sinkTask)
 }
 
 func (w *sinkWorker) handleTask(ctx context.Context, task *sinkTask) (finalErr error) {
+	// The table sink can be closed after the task is generated. Skip it
+	// instead of failing when emitting events to the closed table sink.
+	if task.tableSink.getState() == tablepb.TableStateStopped {
+		log.Info("Sink task is skipped because the table sink is closed",
+			zap.String("namespace", w.changefeedID.Namespace),
+			zap.String("changefeed", w.changefeedID.ID),
+			zap.Stringer("span", &task.span),
+			zap.Any("lowerBound", task.lowerBound))
+		// The memory acquired by the sink manager for the task is never used.
+		w.sinkMemQuota.Refund(requestMemSize)
+		// Nothing is written, so the next task still starts from the lower bound.
+		task.callback(task.lowerBound.Prev(), 0)
+		if task.resultCallback != nil {
+			task.resultCallback(sinkTaskResult{
+				span:    task.span,
+				lastPos: task.lowerBound.Prev(),
+			})
+		}
+		return nil
+	}
+
+	start := time.Now()
 	// We need to use a new batch ID for each task.
 	batchID.Add(1)
-	advancer := newTableSinkAdvancer(task, w.splitTxn, w.sinkMemQuota, requestMemSize)
+	// The memory of the task is acquired for the table, including the memory
+	// acquired by the iterator.
+	sinkMemQuota := w.sinkMemQuota.ForTable(task.span, requestMemSize)
+	advancer := newTableSinkAdvancer(task, w.splitTxn, sinkMemQuota, requestMemSize)
+	advancer.emitter = w.emitterFor(task)
+	advancer.sortByPK = w.sortByPK
+	advancer.coalesceByPK = w.coalesceByPK
+	advancer.allowedEventTypes = w.allowedEventTypes
+	advancer.forceAcquireGauge = TableSinkConsecutiveForceAcquireCount.
+		WithLabelValues(w.changefeedID.Namespace, w.changefeedID.ID, task.span.String())
+	advancer.eventTypeCounter = TableSinkEventTypeCount.MustCurryWith(prometheus.Labels{
+		"namespace": w.changefeedID.Namespace, "changefeed": w.changefeedID.ID, "span": task.span.String(),
+	})
+	advancer.slowEmitThreshold = w.slowEmitThreshold
+	advancer.slowEmitLogLimiter = w.slowEmitLogLimiter
+	advancer.emitRetryLimit = w.emitRetryLimit
+	advancer.maxNonSplitTxnSize = w.maxNonSplitTxnSize
+	advancer.ctx = ctx
+	advancer.batchSize = w.batchSize
 	// The task is finished and some required memory isn't used.
-	defer advancer.cleanup()
+	defer func() {
+		refunded := advancer.cleanup()
+		// A high ratio means we acquire too much memory for the task.
+		if advancer.usedMem > 0 {
+			w.metricMemoryRefundRatio.Set(float64(refunded) / float64(advancer.usedMem))
+		}
+		TableSinkTaskPeakMemory.
+			WithLabelValues(w.changefeedID.Namespace, w.changefeedID.ID, task.span.String()).
+			Set(float64(advancer.peakMem))
+	}()
 
 	lowerBound, upperBound := validateAndAdjustBound(
 		w.changefeedID,
@@ -126,14 +346,27 @@ func (w *sinkWorker) handleTask(ctx context.Context, task *sinkTask) (finalErr e
 		task.lowerBound,
 		task.getUpperBound(task.tableSink.getUpperBoundTs()))
 	advancer.lastPos = lowerBound.Prev()
+	w.reportBacklog(task.span, lowerBound, upperBound)
 
 	allEventSize := uint64(0)
 	allEventCount := 0
+	// allRowCount and maxRowSize are about the events with rows, which helps
+	// to estimate the memory required by the next task.
+	allRowCount := 0
+	maxRowSize := uint64(0)
+	lastCRTs := advancer.lastPos.CommitTs
+	lastProgressReport := time.Now()
+	reportProgress := func() {
+		w.reportProgress(TableProgress{
+			Span: task.span, CRTs: lastCRTs, Rows: allEventCount, Bytes: allEventSize,
+		})
+		lastProgressReport = time.Now()
+	}
 
 	callbackIsPerformed := false
 	performCallback := func(pos sorter.Position) {
 		if !callbackIsPerformed {
-			task.callback(pos)
+			task.callback(pos, advancer.lastEmittedCommitTs)
 			callbackIsPerformed = true
 		}
 	}
@@ -142,6 +375,7 @@ func (w *sinkWorker) handleTask(ctx context.Context, task *sinkTask) (finalErr e
 		// Collect metrics.
 		w.metricRedoEventCacheMiss.Add(float64(allEventSize))
 		w.metricOutputEventCountKV.Add(float64(allEventCount))
+		reportProgress()
 
 		// If eventCache is nil, update sorter commit ts and range event count.
 		if w.eventCache == nil {
@@ -175,13 +409,46 @@ func (w *sinkWorker) handleTask(ctx context.Context, task *sinkTask) (finalErr e
 				w.sinkMemQuota.ClearTable(task.tableSink.span)
 				performCallback(advancer.lastPos)
 				finalErr = nil
+			// All events before `lastPos` are emitted when the deadline is exceeded.
+			case taskDeadlineExceededError:
+				performCallback(advancer.lastPos)
 			default:
 			}
 		}
+
+		if task.resultCallback != nil {
+			var avgRowSize uint64
+			if allRowCount > 0 {
+				avgRowSize = allEventSize / uint64(allRowCount)
+			}
+			task.resultCallback(sinkTaskResult{
+				span:         task.span,
+				lastPos:      advancer.lastPos,
+				lastCommitTs: advancer.lastEmittedCommitTs,
+				rows:         allEventCount,
+				bytes:        allEventSize,
+				avgRowBytes:  avgRowSize,
+				maxRowBytes:  maxRowSize,
+				duration:     time.Since(start),
+				err:          finalErr,
+			})
+		}
 	}()
 
+	// Downstream consumers can establish a baseline before any events of the
+	// table arrive. All events before the lower bound have been emitted, so the
+	// resolved ts is at the commit ts of its previous position.
+	if w.emitBaselineResolvedTs && task.tableSink.baselineResolvedTsEmitted.CompareAndSwap(false, true) {
+		if baseline := lowerBound.Prev(); baseline.IsCommitFence() {
+			// updateResolvedTs never moves the resolved ts backward.
+			if err := advancer.emitter.updateResolvedTs(model.NewResolvedTs(baseline.CommitTs)); err != nil {
+				return errors.Trace(err)
+			}
+		}
+	}
+
 	if w.eventCache != nil {
-		drained, err := w.fetchFromCache(task, &lowerBound, &upperBound)
+		drained, lastCommitTs, err := w.fetchFromCache(task, sinkMemQuota, &lowerBound, &upperBound)
 		failpoint.Inject("TableSinkWorkerFetchFromCache", func() {
 			err = tablesink.NewSinkInternalError(errors.New("TableSinkWorkerFetchFromCacheInjected"))
 		})
@@ -190,6 +457,7 @@ func (w *sinkWorker) handleTask(ctx context.Context, task *sinkTask) (finalErr e
 		}
 		// NOTE: lowerBound can be updated by `fetchFromCache`, so `lastPos` should also be updated.
 		advancer.lastPos = lowerBound.Prev()
+		advancer.lastEmittedCommitTs = lastCommitTs
 		if drained {
 			// If drained is true it means we have drained all events from the cache,
 			// we can return directly instead of get events from the source manager again.
@@ -199,63 +467,230 @@ func (w *sinkWorker) handleTask(ctx context.Context, task *sinkTask) (finalErr e
 	}
 
 	// lowerBound and upperBound are both closed intervals.
-	iter := w.sourceManager.FetchByTable(task.span, lowerBound, upperBound, w.sinkMemQuota)
-	defer func() {
-		if err := iter.Close(); err != nil {
-			log.Error("Sink worker fails to close iterator",
+	iter := w.sourceManager.FetchByTable(task.span, lowerBound, upperBound, sinkMemQuota)
+	defer w.closeIter(&task.span, iter)
+	var eventIter eventIterator = iter
+	if w.readAhead > 0 {
+		// Prefetched events are recorded by the memory quota only after they
+		// are consumed, so bound them by the size of one memory request.
+		readAheadIter := newReadAheadIter(ctx, iter, w.readAhead, requestMemSize)
+		// It must be closed before the underlying iterator.
+		defer readAheadIter.close()
+		eventIter = readAheadIter
+	}
+
+	// The last task may be interrupted in the middle of a split transaction,
+	// skip the events which have been emitted instead of emitting them again.
+	skipEvents := 0
+	if w.splitTxn {
+		skipEvents = task.tableSink.takeSplitTxnProgress(lowerBound)
+		if skipEvents > 0 {
+			log.Info("Sink task skips emitted events of a split transaction",
 				zap.String("namespace", w.changefeedID.Namespace),
 				zap.String("changefeed", w.changefeedID.ID),
 				zap.Stringer("span", &task.span),
-				zap.Error(err))
+				zap.Any("lowerBound", lowerBound),
+				zap.Int("skipEvents", skipEvents))
 		}
-	}()
+	}
+	// How many events of the current unfinished transaction are fetched.
+	pendingTxnEvents := 0
 
+	// Used to detect whether the task makes any progress.
+	startPos := advancer.lastPos
+	deadlineExceeded := false
+	yielded := false
+	ctxCanceled := false
+	quotaClosed := false
 	// 1. We have enough memory to collect events.
 	// 2. The task is not canceled.
+	// 3. The deadline of the task is not exceeded.
+	// 4. The worker is not canceled.
 	for advancer.hasEnoughMem() && !task.isCanceled() {
-		e, pos, err := iter.Next(ctx)
+		if task.deadlineExceeded() {
+			deadlineExceeded = true
+			break
+		}
+		// Flush the progress of the table before exiting, instead of
+		// abandoning all events fetched by the task.
+		if ctx.Err() != nil {
+			ctxCanceled = true
+			break
+		}
+		e, pos, err := eventIter.Next(ctx)
 		if err != nil {
+			if ctx.Err() != nil {
+				ctxCanceled = true
+				break
+			}
 			return errors.Trace(err)
 		}
 
 		// There is no more data. It means that we finish this scan task.
 		if e == nil {
-			return advancer.finish(upperBound)
+			if err := advancer.finish(upperBound); err != nil {
+				return errors.Trace(err)
+			}
+			if task.tableRemoved {
+				return w.closeRemovedTable(task, sinkMemQuota, upperBound)
+			}
+			return nil
 		}
 
 		allEventCount += 1
+		lastCRTs = e.CRTs
+
+		skipped := skipEvents > 0
+		if skipped {
+			skipEvents--
+		}
 
 		// Only record the last valid position.
 		// If the current txn is not finished, the position is not valid.
 		if pos.Valid() {
 			advancer.lastPos = pos
+			pendingTxnEvents = 0
+			skipEvents = 0
+		} else {
+			pendingTxnEvents++
 		}
 
 		// Meet a new commit ts, we need to emit the previous events.
 		advancer.tryMoveToNextTxn(e.CRTs)
 
 		// NOTICE: The event can be filtered by the event filter.
-		if e.Row != nil {
+		if e.Row != nil && !skipped {
 			// For all rows, we add table replicate ts, so mysql sink can determine safe-mode.
 			e.Row.ReplicatingTs = task.tableSink.replicateTs
 			x, size := handleRowChangedEvents(w.changefeedID, task.span, e)
 			advancer.appendEvents(x, size)
 			allEventSize += size
+			allRowCount++
+			if size > maxRowSize {
+				maxRowSize = size
+			}
+		}
+		if w.progressCh != nil && time.Since(lastProgressReport) >= progressReportInterval {
+			reportProgress()
 		}
 
 		if err := advancer.tryAdvanceAndAcquireMem(false, pos.Valid()); err != nil {
+			// The memory quota is closed when the worker is closing, which can
+			// race with a blocked acquisition. Flush the progress as if the
+			// worker is canceled, otherwise the callback is skipped.
+			if errors.Cause(err) == context.Canceled {
+				quotaClosed = true
+				break
+			}
 			return errors.Trace(err)
 		}
+
+		// Yield at a transaction boundary if the task runs too long.
+		if pos.Valid() && w.maxTaskDuration > 0 && time.Since(start) >= w.maxTaskDuration {
+			yielded = true
+			break
+		}
+
+		// Slow down if the table sink suggests so, to avoid buffering too many
+		// events in the table sink.
+		if delay := advancer.takeSuggestedDelay(); delay > 0 {
+			select {
+			// The cancellation is handled at the beginning of the next round.
+			case <-ctx.Done():
+			case <-time.After(delay):
+			}
+		}
+	}
+
+	// The task is interrupted before meeting any transaction boundary, so the
+	// checkpoint can't be advanced by this task. It usually means there is a
+	// large transaction which can't be finished within one task.
+	if allEventCount > 0 && advancer.lastPos.Compare(startPos) == 0 {
+		log.Warn("Sink task is interrupted in a large transaction without any progress",
+			zap.String("namespace", w.changefeedID.Namespace),
+			zap.String("changefeed", w.changefeedID.ID),
+			zap.Stringer("span", &task.span),
+			zap.Any("lowerBound", lowerBound),
+			zap.Any("upperBound", upperBound),
+			zap.Uint64("currTxnCommitTs", advancer.currTxnCommitTs),
+			zap.Int("receivedEvents", allEventCount),
+			zap.Uint64("receivedBytes", allEventSize))
 	}
 
-	return advancer.lastTimeAdvance()
+	if err := advancer.lastTimeAdvance(); err != nil {
+		return err
+	}
+	// All fetched events of the unfinished transaction are emitted with a batch
+	// resolved ts, and the events which are not fetched yet are still skipped.
+	if w.splitTxn && pendingTxnEvents > 0 {
+		task.tableSink.recordSplitTxnProgress(advancer.lastPos.Next(), pendingTxnEvents+skipEvents)
+	}
+	if ctxCanceled {
+		// All events before `lastPos` are emitted, so it's safe to report them.
+		performCallback(advancer.lastPos)
+		return errors.Trace(ctx.Err())
+	}
+	if quotaClosed {
+		performCallback(advancer.lastPos)
+		return errors.Trace(context.Canceled)
+	}
+	if deadlineExceeded {
+		return errors.Trace(taskDeadlineExceededError{deadline: task.deadline})
+	}
+	if yielded {
+		// The scheduler dispatches the table again from the next position.
+		w.metricTaskYieldCount.Inc()
+		log.Debug("Sink task yields because it runs too long",
+			zap.String("namespace", w.changefeedID.Namespace),
+			zap.String("changefeed", w.changefeedID.ID),
+			zap.Stringer("span", &task.span),
+			zap.Any("lastPos", advancer.lastPos),
+			zap.Duration("maxTaskDuration", w.maxTaskDuration))
+	}
+	return nil
+}
+
+// reportBacklog records the estimated count of events in [lowerBound, upperBound]
+// of the table. Nothing is recorded if the sort engine can't estimate it.
+func (w *sinkWorker) reportBacklog(span tablepb.Span, lowerBound, upperBound sorter.Position) {
+	count, ok := w.sourceManager.EstimateEventCountByTable(span, lowerBound, upperBound)
+	if !ok {
+		return
+	}
+	TableSinkBacklogEventCount.
+		WithLabelValues(w.changefeedID.Namespace, w.changefeedID.ID, span.String()).
+		Set(float64(count))
+}
+
+// closeRemovedTable emits a terminal resolved ts to the table sink, which marks
+// all events of the removed table have been emitted, then closes the table sink.
+// The table sink is closed asynchronously, and it's closed completely once all
+// emitted events are flushed.
+func (w *sinkWorker) closeRemovedTable(
+	task *sinkTask, sinkMemQuota MemQuota, upperBound sorter.Position,
+) error {
+	// A normal resolved ts flushes all events of the last transaction even if
+	// it has been advanced with a batch resolved ts.
+	if err := advanceTableSink(task, w.emitterFor(task), upperBound.CommitTs, 0,
+		sinkMemQuota); err != nil {
+		return errors.Trace(err)
+	}
+	closed := task.tableSink.asyncStop()
+	log.Info("Sink worker closes the table sink of a removed table",
+		zap.String("namespace", w.changefeedID.Namespace),
+		zap.String("changefeed", w.changefeedID.ID),
+		zap.Stringer("span", &task.span),
+		zap.Uint64("resolvedTs", upperBound.CommitTs),
+		zap.Bool("closed", closed))
+	return nil
 }
 
 func (w *sinkWorker) fetchFromCache(
 	task *sinkTask, // task is read-only here.
+	sinkMemQuota MemQuota,
 	lowerBound *sorter.Position,
 	upperBound *sorter.Position,
-) (cacheDrained bool, err error) {
+) (cacheDrained bool, lastCommitTs model.Ts, err error) {
 	newLowerBound := *lowerBound
 	newUpperBound := *upperBound
 
@@ -269,9 +704,10 @@ func (w *sinkWorker) fetchFromCache(
 		if len(popRes.events) > 0 {
 			w.metricOutputEventCountKV.Add(float64(popRes.pushCount))
 			w.metricRedoEventCacheHit.Add(float64(popRes.size))
-			if err = task.tableSink.appendRowChangedEvents(popRes.events...); err != nil {
+			if err = w.emitterFor(task).appendRowChangedEvents(popRes.events...); err != nil {
 				return
 			}
+			lastCommitTs = popRes.events[len(popRes.events)-1].CommitTs
 		}
 
 		// Get a resolvedTs so that we can record it into sink memory quota.
@@ -285,18 +721,14 @@ func (w *sinkWorker) fetchFromCache(
 				batchID.Add(1)
 			}
 		} else {
-			if isCommitFence {
-				resolvedTs = model.NewResolvedTs(popRes.upperBoundIfSuccess.CommitTs)
-			} else {
-				resolvedTs = model.NewResolvedTs(popRes.upperBoundIfSuccess.CommitTs - 1)
-			}
+			resolvedTs = model.NewResolvedTs(popRes.upperBoundIfSuccess.ResolvedTs())
 		}
 		// Transfer the memory usage from redoMemQuota to sinkMemQuota.
-		w.sinkMemQuota.ForceAcquire(popRes.releaseSize)
-		w.sinkMemQuota.Record(task.span, resolvedTs, popRes.releaseSize)
+		sinkMemQuota.ForceAcquire(popRes.releaseSize)
+		sinkMemQuota.Record(task.span, resolvedTs, popRes.releaseSize)
 		w.redoMemQuota.Refund(popRes.releaseSize)
 
-		err = task.tableSink.updateResolvedTs(resolvedTs)
+		err = w.emitterFor(task).updateResolvedTs(resolvedTs)
 		log.Debug("Advance table sink",
 			zap.String("namespace", w.changefeedID.Namespace),
 			zap.String("changefeed", w.changefeedID.ID),
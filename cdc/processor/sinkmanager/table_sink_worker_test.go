@@ -19,7 +19,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pingcap/errors"
 	"github.com/pingcap/failpoint"
+	"github.com/pingcap/log"
 	"github.com/pingcap/tiflow/cdc/entry"
 	"github.com/pingcap/tiflow/cdc/model"
 	"github.com/pingcap/tiflow/cdc/processor/memquota"
@@ -27,10 +29,15 @@ import (
 	"github.com/pingcap/tiflow/cdc/processor/sourcemanager/sorter"
 	"github.com/pingcap/tiflow/cdc/processor/sourcemanager/sorter/memory"
 	"github.com/pingcap/tiflow/cdc/processor/tablepb"
+	"github.com/pingcap/tiflow/cdc/sink/tablesink"
 	"github.com/pingcap/tiflow/pkg/spanz"
 	"github.com/pingcap/tiflow/pkg/upstream"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 // testEventSize is the size of a test event.
@@ -127,18 +134,24 @@ func (suite *tableSinkWorkerSuite) TearDownSuite() {
 func (suite *tableSinkWorkerSuite) createWorker(
 	ctx context.Context, memQuota uint64, splitTxn bool,
 ) (*sinkWorker, sorter.SortEngine) {
-	sortEngine := memory.New(context.Background())
-	// Only sourcemanager.FetcyByTable is used, so NewForTest is fine.
-	sm := sourcemanager.NewForTest(suite.testChangefeedID, upstream.NewUpstream4Test(&MockPD{}),
-		&entry.MockMountGroup{}, sortEngine, false)
-	go func() { sm.Run(ctx) }()
-
 	// To avoid refund or release panics.
 	quota := memquota.NewMemQuota(suite.testChangefeedID, memQuota, "sink")
 	// NOTICE: Do not forget the initial memory quota in the worker first time running.
 	quota.ForceAcquire(testEventSize)
 	quota.AddTable(suite.testSpan)
 
+	return suite.createWorkerWithMemQuota(ctx, newFairMemQuota(quota, 0), splitTxn)
+}
+
+func (suite *tableSinkWorkerSuite) createWorkerWithMemQuota(
+	ctx context.Context, quota TableMemQuota, splitTxn bool,
+) (*sinkWorker, sorter.SortEngine) {
+	sortEngine := memory.New(context.Background())
+	// Only sourcemanager.FetcyByTable is used, so NewForTest is fine.
+	sm := sourcemanager.NewForTest(suite.testChangefeedID, upstream.NewUpstream4Test(&MockPD{}),
+		&entry.MockMountGroup{}, sortEngine, false)
+	go func() { sm.Run(ctx) }()
+
 	return newSinkWorker(suite.testChangefeedID, sm, quota, nil, nil, splitTxn), sortEngine
 }
 
@@ -198,7 +211,7 @@ func (suite *tableSinkWorkerSuite) TestHandleTaskWithSplitTxnAndGotSomeFilteredE
 	}()
 
 	wrapper, sink := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
-	callback := func(lastWritePos sorter.Position) {
+	callback := func(lastWritePos sorter.Position, _ model.Ts) {
 		require.Equal(suite.T(), sorter.Position{
 			StartTs:  1,
 			CommitTs: 4,
@@ -249,7 +262,7 @@ func (suite *tableSinkWorkerSuite) TestHandleTaskWithSplitTxnAndAbortWhenNoMemAn
 	}()
 
 	wrapper, sink := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
-	callback := func(lastWritePos sorter.Position) {
+	callback := func(lastWritePos sorter.Position, _ model.Ts) {
 		require.Equal(suite.T(), sorter.Position{
 			StartTs:  1,
 			CommitTs: 3,
@@ -299,7 +312,7 @@ func (suite *tableSinkWorkerSuite) TestHandleTaskWithSplitTxnAndAbortWhenNoMemAn
 	}()
 
 	wrapper, sink := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
-	callback := func(lastWritePos sorter.Position) {
+	callback := func(lastWritePos sorter.Position, _ model.Ts) {
 		require.Equal(suite.T(), sorter.Position{
 			StartTs:  0,
 			CommitTs: 0,
@@ -320,7 +333,198 @@ func (suite *tableSinkWorkerSuite) TestHandleTaskWithSplitTxnAndAbortWhenNoMemAn
 	w.sinkMemQuota.Close()
 	cancel()
 	wg.Wait()
-	require.Len(suite.T(), sink.GetEvents(), 2, "Only two events should be sent to sink")
+	// The fetched event is flushed before the task exits.
+	require.Len(suite.T(), sink.GetEvents(), 3, "Only three events should be sent to sink")
+}
+
+// Test Scenario:
+// The memory quota can be closed at any time while the task is blocked
+// on it, and the callback should always be performed exactly once.
+func (suite *tableSinkWorkerSuite) TestHandleTaskWithSplitTxnAndCloseRaceWithBlocked() {
+	for i := 0; i < 10; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		events := []*model.PolymorphicEvent{
+			genPolymorphicEvent(1, 10, suite.testSpan),
+			genPolymorphicEvent(1, 10, suite.testSpan),
+			genPolymorphicEvent(1, 10, suite.testSpan),
+			genPolymorphicEvent(1, 10, suite.testSpan),
+			genPolymorphicResolvedEvent(14),
+		}
+		// Only for three events.
+		w, e := suite.createWorker(ctx, uint64(testEventSize*3), true)
+		suite.addEventsToSortEngine(events, e)
+
+		wrapper, _ := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
+		callbackCount := 0
+		task := &sinkTask{
+			span:          suite.testSpan,
+			lowerBound:    genLowerBound(),
+			getUpperBound: genUpperBoundGetter(14),
+			tableSink:     wrapper,
+			callback: func(_ sorter.Position, _ model.Ts) {
+				callbackCount++
+			},
+			isCanceled: func() bool { return false },
+		}
+		go func(delay time.Duration) {
+			time.Sleep(delay)
+			w.sinkMemQuota.Close()
+		}(time.Duration(i) * time.Millisecond)
+		err := w.handleTask(ctx, task)
+		require.ErrorIs(suite.T(), err, context.Canceled)
+		require.Equal(suite.T(), 1, callbackCount)
+		cancel()
+	}
+}
+
+// Test Scenario:
+// The table sink is closed before the task runs, the task should be skipped
+// without fetching any events.
+func (suite *tableSinkWorkerSuite) TestHandleTaskWithClosedTableSink() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := []*model.PolymorphicEvent{
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicResolvedEvent(4),
+	}
+	w, e := suite.createWorker(ctx, testEventSize, true)
+	defer w.sinkMemQuota.Close()
+	suite.addEventsToSortEngine(events, e)
+
+	wrapper, sink := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
+	require.True(suite.T(), wrapper.asyncStop())
+	require.Equal(suite.T(), tablepb.TableStateStopped, wrapper.getState())
+
+	callbackCount := 0
+	var lastWritePos sorter.Position
+	var results []sinkTaskResult
+	lowerBound := sorter.Position{StartTs: 1, CommitTs: 2}
+	task := &sinkTask{
+		span:          suite.testSpan,
+		lowerBound:    lowerBound,
+		getUpperBound: genUpperBoundGetter(4),
+		tableSink:     wrapper,
+		callback: func(pos sorter.Position, _ model.Ts) {
+			lastWritePos = pos
+			callbackCount++
+		},
+		isCanceled:     func() bool { return false },
+		resultCallback: func(result sinkTaskResult) { results = append(results, result) },
+	}
+	require.NoError(suite.T(), w.handleTask(ctx, task))
+	require.Equal(suite.T(), 1, callbackCount)
+	require.Equal(suite.T(), lowerBound, lastWritePos.Next())
+	require.Len(suite.T(), sink.GetEvents(), 0)
+	// The memory acquired for the task is refunded.
+	require.Equal(suite.T(), uint64(0), w.sinkMemQuota.GetUsedBytes())
+	// The skipped task still reports an empty result.
+	require.Len(suite.T(), results, 1)
+	require.Equal(suite.T(), suite.testSpan, results[0].span)
+	require.Equal(suite.T(), lastWritePos, results[0].lastPos)
+	require.Equal(suite.T(), 0, results[0].rows)
+	require.NoError(suite.T(), results[0].err)
+}
+
+// Test Scenario:
+// The first task of a table emits a baseline resolved ts before any events,
+// and the following tasks don't emit it again.
+func (suite *tableSinkWorkerSuite) TestHandleTaskWithBaselineResolvedTs() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := []*model.PolymorphicEvent{
+		genPolymorphicEvent(2, 3, suite.testSpan),
+		genPolymorphicEvent(2, 3, suite.testSpan),
+		genPolymorphicResolvedEvent(4),
+	}
+	w, e := suite.createWorker(ctx, testEventSize*10, true)
+	defer w.sinkMemQuota.Close()
+	// It's enabled by default.
+	require.True(suite.T(), w.emitBaselineResolvedTs)
+	suite.addEventsToSortEngine(events, e)
+
+	wrapper, sink := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
+	getResolvedTs := func() model.ResolvedTs {
+		wrapper.tableSink.innerMu.Lock()
+		defer wrapper.tableSink.innerMu.Unlock()
+		return wrapper.tableSink.resolvedTs
+	}
+
+	// The task checks whether it's canceled before fetching any events.
+	var resolvedBeforeEvents []model.ResolvedTs
+	task := &sinkTask{
+		span:          suite.testSpan,
+		lowerBound:    sorter.Position{StartTs: 0, CommitTs: 3},
+		getUpperBound: genUpperBoundGetter(4),
+		tableSink:     wrapper,
+		callback:      func(_ sorter.Position, _ model.Ts) {},
+		isCanceled: func() bool {
+			if len(sink.GetEvents()) == 0 {
+				resolvedBeforeEvents = append(resolvedBeforeEvents, getResolvedTs())
+			}
+			return false
+		},
+	}
+	require.NoError(suite.T(), w.handleTask(ctx, task))
+	require.NotEmpty(suite.T(), resolvedBeforeEvents)
+	require.Equal(suite.T(), model.NewResolvedTs(2), resolvedBeforeEvents[0])
+	require.Len(suite.T(), sink.GetEvents(), 2)
+	require.Equal(suite.T(), model.NewResolvedTs(4), getResolvedTs())
+
+	// The baseline is only emitted by the first task, and never moves the
+	// resolved ts backward.
+	require.True(suite.T(), wrapper.baselineResolvedTsEmitted.Load())
+	wrapper2, _ := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
+	require.NoError(suite.T(), wrapper2.updateResolvedTs(model.NewResolvedTs(10)))
+	task.tableSink = wrapper2
+	task.isCanceled = func() bool { return true }
+	require.NoError(suite.T(), w.handleTask(ctx, task))
+	wrapper2.tableSink.innerMu.Lock()
+	require.Equal(suite.T(), model.NewResolvedTs(10), wrapper2.tableSink.resolvedTs)
+	wrapper2.tableSink.innerMu.Unlock()
+}
+
+// Test Scenario:
+// A large transaction is split and spans multiple tasks, the events emitted
+// by the interrupted task should not be emitted again by the next task.
+func (suite *tableSinkWorkerSuite) TestHandleTaskWithSplitTxnAndResumeInTheMiddle() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var events []*model.PolymorphicEvent
+	for i := 0; i < 10; i++ {
+		events = append(events, genPolymorphicEvent(1, 10, suite.testSpan))
+	}
+	events = append(events, genPolymorphicResolvedEvent(14))
+	w, e := suite.createWorker(ctx, uint64(testEventSize*100), true)
+	defer w.sinkMemQuota.Close()
+	suite.addEventsToSortEngine(events, e)
+
+	wrapper, sink := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
+	var lastWritePos sorter.Position
+	// Interrupt the first task after fetching 4 events.
+	rounds := 0
+	task := &sinkTask{
+		span:          suite.testSpan,
+		lowerBound:    genLowerBound(),
+		getUpperBound: genUpperBoundGetter(14),
+		tableSink:     wrapper,
+		callback: func(pos sorter.Position, _ model.Ts) {
+			lastWritePos = pos
+		},
+		isCanceled: func() bool {
+			rounds++
+			return rounds > 4
+		},
+	}
+	require.NoError(suite.T(), w.handleTask(ctx, task))
+	require.Len(suite.T(), sink.GetEvents(), 4)
+	require.Equal(suite.T(), uint64(0), lastWritePos.CommitTs, "the transaction is not finished")
+
+	task.lowerBound = lastWritePos.Next()
+	task.isCanceled = func() bool { return false }
+	require.NoError(suite.T(), w.handleTask(ctx, task))
+	require.Len(suite.T(), sink.GetEvents(), 10, "the emitted events should be skipped")
+	require.Equal(suite.T(), uint64(14), lastWritePos.CommitTs)
 }
 
 // Test Scenario:
@@ -352,7 +556,7 @@ func (suite *tableSinkWorkerSuite) TestHandleTaskWithSplitTxnAndOnlyAdvanceWhenR
 	}()
 
 	wrapper, sink := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
-	callback := func(lastWritePos sorter.Position) {
+	callback := func(lastWritePos sorter.Position, _ model.Ts) {
 		require.Equal(suite.T(), sorter.Position{
 			StartTs:  1,
 			CommitTs: 2,
@@ -407,7 +611,7 @@ func (suite *tableSinkWorkerSuite) TestHandleTaskWithoutSplitTxnAndAbortWhenNoMe
 	}()
 
 	wrapper, sink := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
-	callback := func(lastWritePos sorter.Position) {
+	callback := func(lastWritePos sorter.Position, _ model.Ts) {
 		require.Equal(suite.T(), sorter.Position{
 			StartTs:  1,
 			CommitTs: 2,
@@ -460,7 +664,7 @@ func (suite *tableSinkWorkerSuite) TestTaskWithoutSplitTxnOnlyAdvanceWhenReachMa
 	}()
 
 	wrapper, sink := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
-	callback := func(lastWritePos sorter.Position) {
+	callback := func(lastWritePos sorter.Position, _ model.Ts) {
 		require.Equal(suite.T(), sorter.Position{
 			StartTs:  1,
 			CommitTs: 4,
@@ -509,7 +713,7 @@ func (suite *tableSinkWorkerSuite) TestHandleTaskWithSplitTxnAndAdvanceTableWhen
 	}()
 
 	wrapper, sink := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
-	callback := func(lastWritePos sorter.Position) {
+	callback := func(lastWritePos sorter.Position, _ model.Ts) {
 		require.Equal(suite.T(), sorter.Position{
 			StartTs:  3,
 			CommitTs: 4,
@@ -562,7 +766,7 @@ func (suite *tableSinkWorkerSuite) TestHandleTaskWithSplitTxnAndAdvanceTableIfNo
 	}()
 
 	wrapper, _ := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
-	callback := func(lastWritePos sorter.Position) {
+	callback := func(lastWritePos sorter.Position, _ model.Ts) {
 		require.Equal(suite.T(), sorter.Position{
 			StartTs:  3,
 			CommitTs: 4,
@@ -588,6 +792,860 @@ func (suite *tableSinkWorkerSuite) TestHandleTaskWithSplitTxnAndAdvanceTableIfNo
 	wg.Wait()
 }
 
+// Test Scenario:
+// worker should report a warning if the task is interrupted in a large
+// transaction and can't advance the checkpoint.
+func (suite *tableSinkWorkerSuite) TestHandleTaskWithSplitTxnAndInterruptedInLargeTxn() {
+	// For observing the logs
+	zapcore, logs := observer.New(zap.WarnLevel)
+	conf := &log.Config{Level: "warn", File: log.FileLogConfig{}}
+	_, r, _ := log.InitLogger(conf)
+	logger := zap.New(zapcore)
+	restoreFn := log.ReplaceGlobals(logger, r)
+	defer restoreFn()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := []*model.PolymorphicEvent{
+		genPolymorphicEvent(1, 10, suite.testSpan),
+		genPolymorphicEvent(1, 10, suite.testSpan),
+		genPolymorphicEvent(1, 10, suite.testSpan),
+		genPolymorphicEvent(1, 10, suite.testSpan),
+		genPolymorphicResolvedEvent(14),
+	}
+	// Enough memory for all events.
+	eventSize := uint64(testEventSize * 10)
+	w, e := suite.createWorker(ctx, eventSize, true)
+	defer w.sinkMemQuota.Close()
+	suite.addEventsToSortEngine(events, e)
+
+	taskChan := make(chan *sinkTask)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := w.handleTasks(ctx, taskChan)
+		require.ErrorIs(suite.T(), err, context.Canceled)
+	}()
+
+	wrapper, sink := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
+	lowerBound := genLowerBound()
+	callback := func(lastWritePos sorter.Position, _ model.Ts) {
+		require.Equal(suite.T(), lowerBound.Prev(), lastWritePos,
+			"no transaction boundary is met, so the position is not advanced")
+		cancel()
+	}
+	// Interrupt the task after two events are fetched.
+	checkTimes := 0
+	isCanceled := func() bool {
+		checkTimes++
+		return checkTimes > 2
+	}
+	taskChan <- &sinkTask{
+		span:          suite.testSpan,
+		lowerBound:    lowerBound,
+		getUpperBound: genUpperBoundGetter(14),
+		tableSink:     wrapper,
+		callback:      callback,
+		isCanceled:    isCanceled,
+	}
+	wg.Wait()
+	require.Len(suite.T(), sink.GetEvents(), 2)
+	require.Equal(suite.T(), 1,
+		logs.FilterMessage("Sink task is interrupted in a large transaction without any progress").Len())
+}
+
+// Test Scenario:
+// worker with read-ahead enabled should emit the same events as the
+// synchronous one.
+func (suite *tableSinkWorkerSuite) TestHandleTaskWithReadAhead() {
+	run := func(readAhead int) []*model.RowChangedEvent {
+		ctx, cancel := context.WithCancel(context.Background())
+		events := []*model.PolymorphicEvent{
+			genPolymorphicEvent(1, 2, suite.testSpan),
+			genPolymorphicEvent(1, 2, suite.testSpan),
+			genPolymorphicEventWithNilRow(1, 2),
+			genPolymorphicEvent(1, 3, suite.testSpan),
+			genPolymorphicEvent(2, 4, suite.testSpan),
+			genPolymorphicEvent(3, 4, suite.testSpan),
+			genPolymorphicResolvedEvent(4),
+		}
+		w, e := suite.createWorker(ctx, uint64(testEventSize*10), true)
+		defer w.sinkMemQuota.Close()
+		w.readAhead = readAhead
+		suite.addEventsToSortEngine(events, e)
+
+		taskChan := make(chan *sinkTask)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := w.handleTasks(ctx, taskChan)
+			require.Equal(suite.T(), context.Canceled, err)
+		}()
+
+		wrapper, sink := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
+		callback := func(lastWritePos sorter.Position, _ model.Ts) {
+			require.Equal(suite.T(), sorter.Position{
+				StartTs:  3,
+				CommitTs: 4,
+			}, lastWritePos)
+			cancel()
+		}
+		taskChan <- &sinkTask{
+			span:          suite.testSpan,
+			lowerBound:    genLowerBound(),
+			getUpperBound: genUpperBoundGetter(4),
+			tableSink:     wrapper,
+			callback:      callback,
+			isCanceled:    func() bool { return false },
+		}
+		wg.Wait()
+
+		rows := make([]*model.RowChangedEvent, 0, len(events))
+		for _, e := range sink.GetEvents() {
+			rows = append(rows, e.Event)
+		}
+		return rows
+	}
+
+	expected := run(0)
+	require.Len(suite.T(), expected, 5)
+	got := run(2)
+	require.Len(suite.T(), got, len(expected))
+	for i := range expected {
+		require.Equal(suite.T(), expected[i].StartTs, got[i].StartTs)
+		require.Equal(suite.T(), expected[i].CommitTs, got[i].CommitTs)
+	}
+}
+
+// Test Scenario:
+// worker should report the ratio of refunded memory to consumed memory.
+func (suite *tableSinkWorkerSuite) TestHandleTaskReportMemoryRefundRatio() {
+	ctx, cancel := context.WithCancel(context.Background())
+	events := []*model.PolymorphicEvent{
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicResolvedEvent(4),
+	}
+	w, e := suite.createWorker(ctx, uint64(testEventSize*10), true)
+	defer w.sinkMemQuota.Close()
+	suite.addEventsToSortEngine(events, e)
+
+	taskChan := make(chan *sinkTask)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := w.handleTasks(ctx, taskChan)
+		require.Equal(suite.T(), context.Canceled, err)
+	}()
+
+	wrapper, _ := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
+	taskChan <- &sinkTask{
+		span:          suite.testSpan,
+		lowerBound:    genLowerBound(),
+		getUpperBound: genUpperBoundGetter(4),
+		tableSink:     wrapper,
+		callback:      func(_ sorter.Position, _ model.Ts) { cancel() },
+		isCanceled:    func() bool { return false },
+	}
+	wg.Wait()
+
+	// The worker acquires memory for one more event after the first event is
+	// consumed, but there are no more events. So the ratio should be 1.
+	var out dto.Metric
+	require.NoError(suite.T(), w.metricMemoryRefundRatio.Write(&out))
+	require.Equal(suite.T(), float64(1), out.GetGauge().GetValue())
+}
+
+func (suite *tableSinkWorkerSuite) TestHandleTaskReportPeakMemory() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := []*model.PolymorphicEvent{
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicEvent(2, 3, suite.testSpan),
+		genPolymorphicResolvedEvent(4),
+	}
+	w, e := suite.createWorker(ctx, uint64(testEventSize*10), true)
+	defer w.sinkMemQuota.Close()
+	suite.addEventsToSortEngine(events, e)
+
+	wrapper, _ := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
+	task := &sinkTask{
+		span:          suite.testSpan,
+		lowerBound:    genLowerBound(),
+		getUpperBound: genUpperBoundGetter(4),
+		tableSink:     wrapper,
+		callback:      func(_ sorter.Position, _ model.Ts) {},
+		isCanceled:    func() bool { return false },
+	}
+	require.NoError(suite.T(), w.handleTask(ctx, task))
+	defer TableSinkTaskPeakMemory.DeleteLabelValues(
+		suite.testChangefeedID.Namespace, suite.testChangefeedID.ID, suite.testSpan.String())
+
+	// All 3 events are buffered by the task.
+	var out dto.Metric
+	gauge := TableSinkTaskPeakMemory.WithLabelValues(
+		suite.testChangefeedID.Namespace, suite.testChangefeedID.ID, suite.testSpan.String())
+	require.NoError(suite.T(), gauge.Write(&out))
+	require.Equal(suite.T(), float64(testEventSize*3), out.GetGauge().GetValue())
+}
+
+// slowCloseIter is an iterator which is slow to close.
+type slowCloseIter struct {
+	delay time.Duration
+	err   error
+}
+
+func (i *slowCloseIter) Close() error {
+	time.Sleep(i.delay)
+	return i.err
+}
+
+func (suite *tableSinkWorkerSuite) TestCloseIterReportDuration() {
+	zapcore, logs := observer.New(zap.WarnLevel)
+	conf := &log.Config{Level: "warn", File: log.FileLogConfig{}}
+	_, r, _ := log.InitLogger(conf)
+	logger := zap.New(zapcore)
+	restoreFn := log.ReplaceGlobals(logger, r)
+	defer restoreFn()
+
+	changefeedID := model.DefaultChangeFeedID("iter-close-duration")
+	defer TableSinkIteratorCloseDuration.DeleteLabelValues(changefeedID.Namespace, changefeedID.ID)
+	w := newSinkWorker(changefeedID, nil, nil, nil, nil, true)
+	w.slowIterCloseThreshold = 50 * time.Millisecond
+
+	// A fast close is observed without any warning.
+	w.closeIter(&suite.testSpan, &slowCloseIter{})
+	require.Equal(suite.T(), 0, logs.FilterMessage("Sink worker closes iterator too slowly").Len())
+
+	// A slow close is observed and logged.
+	w.closeIter(&suite.testSpan, &slowCloseIter{delay: 100 * time.Millisecond, err: errors.New("close failed")})
+	require.Equal(suite.T(), 1, logs.FilterMessage("Sink worker closes iterator too slowly").Len())
+
+	var out dto.Metric
+	histogram := TableSinkIteratorCloseDuration.WithLabelValues(changefeedID.Namespace, changefeedID.ID)
+	require.NoError(suite.T(), histogram.(prometheus.Histogram).Write(&out))
+	require.Equal(suite.T(), uint64(2), out.GetHistogram().GetSampleCount())
+	require.GreaterOrEqual(suite.T(), out.GetHistogram().GetSampleSum(), 0.1)
+}
+
+// estimatingSortEngine is a sort engine which can estimate event counts.
+type estimatingSortEngine struct {
+	sorter.SortEngine
+	count      uint64
+	lowerBound sorter.Position
+	upperBound sorter.Position
+}
+
+func (e *estimatingSortEngine) EstimateCountByTable(
+	_ tablepb.Span, lowerBound, upperBound sorter.Position,
+) (uint64, bool) {
+	e.lowerBound, e.upperBound = lowerBound, upperBound
+	return e.count, true
+}
+
+func (suite *tableSinkWorkerSuite) TestHandleTaskReportBacklog() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := []*model.PolymorphicEvent{
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicResolvedEvent(4),
+	}
+	quota := memquota.NewMemQuota(suite.testChangefeedID, testEventSize*10, "sink")
+	defer quota.Close()
+	quota.ForceAcquire(testEventSize)
+	quota.AddTable(suite.testSpan)
+	sortEngine := &estimatingSortEngine{SortEngine: memory.New(context.Background()), count: 42}
+	sm := sourcemanager.NewForTest(suite.testChangefeedID, upstream.NewUpstream4Test(&MockPD{}),
+		&entry.MockMountGroup{}, sortEngine, false)
+	go func() { sm.Run(ctx) }()
+	w := newSinkWorker(suite.testChangefeedID, sm, newFairMemQuota(quota, 0), nil, nil, true)
+	suite.addEventsToSortEngine(events, sortEngine)
+
+	wrapper, _ := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
+	task := &sinkTask{
+		span:          suite.testSpan,
+		lowerBound:    genLowerBound(),
+		getUpperBound: genUpperBoundGetter(4),
+		tableSink:     wrapper,
+		callback:      func(_ sorter.Position, _ model.Ts) {},
+		isCanceled:    func() bool { return false },
+	}
+	require.NoError(suite.T(), w.handleTask(ctx, task))
+	require.Equal(suite.T(), genLowerBound(), sortEngine.lowerBound)
+	require.Equal(suite.T(), genUpperBoundGetter(4)(0), sortEngine.upperBound)
+
+	var out dto.Metric
+	gauge := TableSinkBacklogEventCount.WithLabelValues(
+		suite.testChangefeedID.Namespace, suite.testChangefeedID.ID, suite.testSpan.String())
+	require.NoError(suite.T(), gauge.Write(&out))
+	require.Equal(suite.T(), float64(42), out.GetGauge().GetValue())
+
+	// Nothing is reported if the sort engine can't estimate.
+	TableSinkBacklogEventCount.DeleteLabelValues(
+		suite.testChangefeedID.Namespace, suite.testChangefeedID.ID, suite.testSpan.String())
+	w.sourceManager = sourcemanager.NewForTest(suite.testChangefeedID, upstream.NewUpstream4Test(&MockPD{}),
+		&entry.MockMountGroup{}, sortEngine.SortEngine, false)
+	task.lowerBound = sorter.Position{StartTs: 3, CommitTs: 4}
+	require.NoError(suite.T(), w.handleTask(ctx, task))
+	require.False(suite.T(), TableSinkBacklogEventCount.DeleteLabelValues(
+		suite.testChangefeedID.Namespace, suite.testChangefeedID.ID, suite.testSpan.String()))
+}
+
+func (suite *tableSinkWorkerSuite) TestHandleTaskReportLastCommitTs() {
+	ctx, cancel := context.WithCancel(context.Background())
+	events := []*model.PolymorphicEvent{
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicEvent(2, 3, suite.testSpan),
+		genPolymorphicResolvedEvent(4),
+	}
+	w, e := suite.createWorker(ctx, uint64(testEventSize*10), true)
+	defer w.sinkMemQuota.Close()
+	suite.addEventsToSortEngine(events, e)
+
+	taskChan := make(chan *sinkTask)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := w.handleTasks(ctx, taskChan)
+		require.Equal(suite.T(), context.Canceled, err)
+	}()
+
+	wrapper, sink := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
+	callback := func(lastWritePos sorter.Position, lastCommitTs model.Ts) {
+		require.Equal(suite.T(), sorter.Position{StartTs: 3, CommitTs: 4}, lastWritePos)
+		require.Equal(suite.T(), uint64(3), lastCommitTs)
+		cancel()
+	}
+	taskChan <- &sinkTask{
+		span:          suite.testSpan,
+		lowerBound:    genLowerBound(),
+		getUpperBound: genUpperBoundGetter(4),
+		tableSink:     wrapper,
+		callback:      callback,
+		isCanceled:    func() bool { return false },
+	}
+	wg.Wait()
+	emitted := sink.GetEvents()
+	require.Len(suite.T(), emitted, 3)
+	require.Equal(suite.T(), uint64(3), emitted[len(emitted)-1].Event.CommitTs)
+}
+
+func (suite *tableSinkWorkerSuite) TestHandleTaskReportResult() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := []*model.PolymorphicEvent{
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicEvent(2, 3, suite.testSpan),
+		genPolymorphicResolvedEvent(4),
+	}
+	w, e := suite.createWorker(ctx, uint64(testEventSize*10), true)
+	defer w.sinkMemQuota.Close()
+	suite.addEventsToSortEngine(events, e)
+
+	wrapper, _ := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
+	var results []sinkTaskResult
+	task := &sinkTask{
+		span:           suite.testSpan,
+		lowerBound:     genLowerBound(),
+		getUpperBound:  genUpperBoundGetter(4),
+		tableSink:      wrapper,
+		callback:       func(_ sorter.Position, _ model.Ts) {},
+		isCanceled:     func() bool { return false },
+		resultCallback: func(result sinkTaskResult) { results = append(results, result) },
+	}
+	require.NoError(suite.T(), w.handleTask(ctx, task))
+	require.Len(suite.T(), results, 1)
+	result := results[0]
+	require.Equal(suite.T(), suite.testSpan, result.span)
+	require.Equal(suite.T(), sorter.Position{StartTs: 3, CommitTs: 4}, result.lastPos)
+	require.Equal(suite.T(), uint64(3), result.lastCommitTs)
+	require.Equal(suite.T(), 3, result.rows)
+	require.Equal(suite.T(), uint64(testEventSize*3), result.bytes)
+	require.Greater(suite.T(), result.duration, time.Duration(0))
+	require.NoError(suite.T(), result.err)
+
+	// The result is reported even if the task fails.
+	canceledCtx, cancelTask := context.WithCancel(ctx)
+	cancelTask()
+	task.lowerBound = genLowerBound()
+	err := w.handleTask(canceledCtx, task)
+	require.ErrorIs(suite.T(), err, context.Canceled)
+	require.Len(suite.T(), results, 2)
+	require.Equal(suite.T(), err, results[1].err)
+	require.Equal(suite.T(), 0, results[1].rows)
+}
+
+func (suite *tableSinkWorkerSuite) TestHandleTaskReportRowSize() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := []*model.PolymorphicEvent{
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicEventWithNilRow(1, 2),
+		genPolymorphicEvent(2, 3, suite.testSpan),
+		genPolymorphicResolvedEvent(4),
+	}
+	events[1].Row.Columns[0].ApproximateBytes = 100
+	events[3].Row.Columns[0].ApproximateBytes = 300
+	w, e := suite.createWorker(ctx, uint64(testEventSize*10), true)
+	defer w.sinkMemQuota.Close()
+	suite.addEventsToSortEngine(events, e)
+
+	wrapper, _ := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
+	var results []sinkTaskResult
+	task := &sinkTask{
+		span:           suite.testSpan,
+		lowerBound:     genLowerBound(),
+		getUpperBound:  genUpperBoundGetter(4),
+		tableSink:      wrapper,
+		callback:       func(_ sorter.Position, _ model.Ts) {},
+		isCanceled:     func() bool { return false },
+		resultCallback: func(result sinkTaskResult) { results = append(results, result) },
+	}
+	require.NoError(suite.T(), w.handleTask(ctx, task))
+	require.Len(suite.T(), results, 1)
+	result := results[0]
+	// The filtered event is received but has no row.
+	require.Equal(suite.T(), 4, result.rows)
+	require.Equal(suite.T(), uint64(testEventSize*3+400), result.bytes)
+	require.Equal(suite.T(), uint64(testEventSize*3+400)/3, result.avgRowBytes)
+	require.Equal(suite.T(), uint64(testEventSize+300), result.maxRowBytes)
+
+	// Nothing is received.
+	task.lowerBound = sorter.Position{StartTs: 3, CommitTs: 4}
+	require.NoError(suite.T(), w.handleTask(ctx, task))
+	require.Len(suite.T(), results, 2)
+	require.Equal(suite.T(), uint64(0), results[1].avgRowBytes)
+	require.Equal(suite.T(), uint64(0), results[1].maxRowBytes)
+}
+
+func (suite *tableSinkWorkerSuite) TestHandleTaskReportProgress() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := []*model.PolymorphicEvent{
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicEvent(2, 3, suite.testSpan),
+		genPolymorphicResolvedEvent(4),
+	}
+	w, e := suite.createWorker(ctx, uint64(testEventSize*10), true)
+	defer w.sinkMemQuota.Close()
+	suite.addEventsToSortEngine(events, e)
+
+	// Report the progress after every event.
+	origInterval := progressReportInterval
+	progressReportInterval = 0
+	defer func() { progressReportInterval = origInterval }()
+	progressCh := make(chan TableProgress, 16)
+	w.progressCh = progressCh
+
+	wrapper, _ := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
+	task := &sinkTask{
+		span:          suite.testSpan,
+		lowerBound:    genLowerBound(),
+		getUpperBound: genUpperBoundGetter(4),
+		tableSink:     wrapper,
+		callback:      func(_ sorter.Position, _ model.Ts) {},
+		isCanceled:    func() bool { return false },
+	}
+	require.NoError(suite.T(), w.handleTask(ctx, task))
+	close(progressCh)
+	var progresses []TableProgress
+	for p := range progressCh {
+		progresses = append(progresses, p)
+	}
+	// One report for each event, and the last one when the task finishes.
+	require.Len(suite.T(), progresses, 4)
+	for i, p := range progresses[:3] {
+		require.Equal(suite.T(), suite.testSpan, p.Span)
+		require.Equal(suite.T(), events[i].CRTs, p.CRTs)
+		require.Equal(suite.T(), i+1, p.Rows)
+		require.Equal(suite.T(), uint64(testEventSize*(i+1)), p.Bytes)
+	}
+	require.Equal(suite.T(), progresses[2], progresses[3])
+
+	// The task is never blocked by a full channel.
+	w.progressCh = make(chan TableProgress)
+	task.lowerBound = genLowerBound()
+	require.NoError(suite.T(), w.handleTask(ctx, task))
+}
+
+// Test Scenario:
+// A task running longer than maxTaskDuration should yield at the next
+// transaction boundary, and report its position so the table can be
+// dispatched again.
+func (suite *tableSinkWorkerSuite) TestHandleTaskYieldWhenRunTooLong() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := []*model.PolymorphicEvent{
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicEvent(2, 3, suite.testSpan),
+		genPolymorphicResolvedEvent(4),
+	}
+	w, e := suite.createWorker(ctx, uint64(testEventSize*10), true)
+	defer w.sinkMemQuota.Close()
+	defer TableSinkTaskYieldCount.DeleteLabelValues(
+		suite.testChangefeedID.Namespace, suite.testChangefeedID.ID)
+	w.maxTaskDuration = time.Nanosecond
+	suite.addEventsToSortEngine(events, e)
+
+	wrapper, sink := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
+	var lastWritePos sorter.Position
+	task := &sinkTask{
+		span:          suite.testSpan,
+		lowerBound:    genLowerBound(),
+		getUpperBound: genUpperBoundGetter(4),
+		tableSink:     wrapper,
+		callback: func(pos sorter.Position, _ model.Ts) {
+			lastWritePos = pos
+		},
+		isCanceled: func() bool { return false },
+	}
+	require.NoError(suite.T(), w.handleTask(ctx, task))
+	require.Equal(suite.T(), sorter.Position{StartTs: 1, CommitTs: 2}, lastWritePos)
+	require.Len(suite.T(), sink.GetEvents(), 2)
+
+	// The next task continues from the reported position.
+	w.sinkMemQuota.ForceAcquire(testEventSize)
+	task.lowerBound = lastWritePos.Next()
+	require.NoError(suite.T(), w.handleTask(ctx, task))
+	require.Equal(suite.T(), sorter.Position{StartTs: 2, CommitTs: 3}, lastWritePos)
+	require.Len(suite.T(), sink.GetEvents(), 3)
+
+	var out dto.Metric
+	require.NoError(suite.T(), w.metricTaskYieldCount.Write(&out))
+	require.Equal(suite.T(), float64(2), out.GetCounter().GetValue())
+}
+
+func (suite *tableSinkWorkerSuite) TestHandleTaskWithFakeMemQuota() {
+	ctx, cancel := context.WithCancel(context.Background())
+	events := []*model.PolymorphicEvent{
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicEvent(2, 3, suite.testSpan),
+		genPolymorphicEvent(3, 4, suite.testSpan),
+		genPolymorphicResolvedEvent(4),
+	}
+	// Only for two events.
+	quota := newFakeMemQuota(testEventSize * 2)
+	// NOTICE: Do not forget the initial memory quota in the worker first time running.
+	quota.ForceAcquire(testEventSize)
+	w, e := suite.createWorkerWithMemQuota(ctx, quota, true)
+	defer w.sinkMemQuota.Close()
+	suite.addEventsToSortEngine(events, e)
+
+	taskChan := make(chan *sinkTask)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := w.handleTasks(ctx, taskChan)
+		require.Equal(suite.T(), context.Canceled, err)
+	}()
+
+	wrapper, sink := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
+	taskChan <- &sinkTask{
+		span:          suite.testSpan,
+		lowerBound:    genLowerBound(),
+		getUpperBound: genUpperBoundGetter(4),
+		tableSink:     wrapper,
+		callback:      func(_ sorter.Position, _ model.Ts) { cancel() },
+		isCanceled:    func() bool { return false },
+	}
+	wg.Wait()
+	// The quota is exhausted after the first transaction.
+	require.Len(suite.T(), sink.GetEvents(), 2)
+
+	// All acquired memory should be either refunded or recorded.
+	acquired, refunded, recorded := quota.stats()
+	require.Greater(suite.T(), acquired, uint64(testEventSize))
+	require.Equal(suite.T(), acquired, refunded+recorded)
+}
+
+// throttledTableSink is a table sink which always suggests workers to slow down.
+type throttledTableSink struct {
+	tablesink.TableSink
+	delay time.Duration
+}
+
+func (s *throttledTableSink) SuggestedDelay() time.Duration {
+	return s.delay
+}
+
+// Test Scenario:
+// When the table sink suggests a delay, the worker should pace itself, and
+// the delay should be bounded by maxSuggestedDelay.
+func (suite *tableSinkWorkerSuite) TestHandleTaskWithSuggestedDelay() {
+	maxSuggestedDelay = 20 * time.Millisecond
+	defer func() { maxSuggestedDelay = time.Second }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := []*model.PolymorphicEvent{
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicEvent(2, 3, suite.testSpan),
+		genPolymorphicEvent(2, 3, suite.testSpan),
+		genPolymorphicResolvedEvent(4),
+	}
+	w, e := suite.createWorker(ctx, uint64(testEventSize*10), true)
+	defer w.sinkMemQuota.Close()
+	suite.addEventsToSortEngine(events, e)
+
+	taskChan := make(chan *sinkTask)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := w.handleTasks(ctx, taskChan)
+		require.Equal(suite.T(), context.Canceled, err)
+	}()
+
+	wrapper, sink := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
+	wrapper.tableSink.s = &throttledTableSink{
+		TableSink: wrapper.tableSink.s,
+		delay:     time.Hour,
+	}
+	start := time.Now()
+	taskChan <- &sinkTask{
+		span:          suite.testSpan,
+		lowerBound:    genLowerBound(),
+		getUpperBound: genUpperBoundGetter(4),
+		tableSink:     wrapper,
+		callback:      func(_ sorter.Position, _ model.Ts) { cancel() },
+		isCanceled:    func() bool { return false },
+	}
+	wg.Wait()
+	require.Len(suite.T(), sink.GetEvents(), 4)
+	// Table sink is advanced per 2 events, so the worker sleeps at least twice.
+	elapsed := time.Since(start)
+	require.GreaterOrEqual(suite.T(), elapsed, 2*maxSuggestedDelay)
+	require.Less(suite.T(), elapsed, time.Minute)
+}
+
+// Test Scenario:
+// When the deadline of a task is exceeded, the worker should stop scanning
+// events, perform the callback and return a deadline exceeded error.
+func (suite *tableSinkWorkerSuite) TestHandleTaskWithDeadline() {
+	maxSuggestedDelay = 20 * time.Millisecond
+	defer func() { maxSuggestedDelay = time.Second }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var events []*model.PolymorphicEvent
+	for commitTs := uint64(2); commitTs < 102; commitTs++ {
+		events = append(events,
+			genPolymorphicEvent(commitTs-1, commitTs, suite.testSpan),
+			genPolymorphicEvent(commitTs-1, commitTs, suite.testSpan))
+	}
+	events = append(events, genPolymorphicResolvedEvent(102))
+	w, e := suite.createWorker(ctx, uint64(testEventSize*1000), true)
+	defer w.sinkMemQuota.Close()
+	suite.addEventsToSortEngine(events, e)
+
+	wrapper, sink := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
+	// Each advance of the table sink takes at least maxSuggestedDelay,
+	// so scanning all events takes much longer than the deadline.
+	wrapper.tableSink.s = &throttledTableSink{
+		TableSink: wrapper.tableSink.s,
+		delay:     time.Hour,
+	}
+	var lastWritePos sorter.Position
+	callbackCalled := false
+	task := &sinkTask{
+		span:          suite.testSpan,
+		lowerBound:    genLowerBound(),
+		getUpperBound: genUpperBoundGetter(102),
+		tableSink:     wrapper,
+		callback: func(pos sorter.Position, _ model.Ts) {
+			lastWritePos = pos
+			callbackCalled = true
+		},
+		isCanceled: func() bool { return false },
+		deadline:   time.Now().Add(50 * time.Millisecond),
+	}
+	start := time.Now()
+	err := w.handleTask(ctx, task)
+	require.IsType(suite.T(), taskDeadlineExceededError{}, errors.Cause(err))
+	require.Less(suite.T(), time.Since(start), time.Minute)
+	require.True(suite.T(), callbackCalled)
+	emitted := len(sink.GetEvents())
+	require.Greater(suite.T(), emitted, 0)
+	require.Less(suite.T(), emitted, 200)
+	require.True(suite.T(), lastWritePos.Valid())
+	require.Less(suite.T(), lastWritePos.CommitTs, uint64(102))
+
+	// A task without a deadline can scan all events.
+	callbackCalled = false
+	task.lowerBound = lastWritePos.Next()
+	task.deadline = time.Time{}
+	maxSuggestedDelay = 0
+	require.NoError(suite.T(), w.handleTask(ctx, task))
+	require.True(suite.T(), callbackCalled)
+	require.Len(suite.T(), sink.GetEvents(), 200)
+}
+
+// Test Scenario:
+// When the worker is canceled in the middle of a scan, it should flush a
+// final resolved ts for the table and perform the callback before returning.
+func (suite *tableSinkWorkerSuite) TestHandleTaskWithContextCanceled() {
+	maxSuggestedDelay = 20 * time.Millisecond
+	defer func() { maxSuggestedDelay = time.Second }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var events []*model.PolymorphicEvent
+	for commitTs := uint64(2); commitTs < 102; commitTs++ {
+		events = append(events,
+			genPolymorphicEvent(commitTs-1, commitTs, suite.testSpan),
+			genPolymorphicEvent(commitTs-1, commitTs, suite.testSpan))
+	}
+	events = append(events, genPolymorphicResolvedEvent(102))
+	w, e := suite.createWorker(ctx, uint64(testEventSize*1000), true)
+	defer w.sinkMemQuota.Close()
+	suite.addEventsToSortEngine(events, e)
+
+	wrapper, sink := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
+	// Slow down the scan so that it can be canceled in the middle.
+	wrapper.tableSink.s = &throttledTableSink{
+		TableSink: wrapper.tableSink.s,
+		delay:     time.Hour,
+	}
+	var lastWritePos sorter.Position
+	callbackCalled := false
+	task := &sinkTask{
+		span:          suite.testSpan,
+		lowerBound:    genLowerBound(),
+		getUpperBound: genUpperBoundGetter(102),
+		tableSink:     wrapper,
+		callback: func(pos sorter.Position, _ model.Ts) {
+			lastWritePos = pos
+			callbackCalled = true
+		},
+		isCanceled: func() bool { return false },
+	}
+	time.AfterFunc(50*time.Millisecond, cancel)
+	err := w.handleTask(ctx, task)
+	require.ErrorIs(suite.T(), err, context.Canceled)
+	require.True(suite.T(), callbackCalled)
+	require.True(suite.T(), lastWritePos.Valid())
+	require.Less(suite.T(), lastWritePos.CommitTs, uint64(102))
+	emitted := len(sink.GetEvents())
+	require.Greater(suite.T(), emitted, 0)
+	require.Less(suite.T(), emitted, 200)
+
+	// The final resolved ts makes the checkpoint reach the last position.
+	sink.AckAllEvents()
+	require.Eventually(suite.T(), func() bool {
+		return wrapper.getCheckpointTs().Ts == lastWritePos.CommitTs
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// Test Scenario:
+// Tasks should be queued up while the worker is paused, and be handled
+// after the worker is resumed.
+func (suite *tableSinkWorkerSuite) TestHandleTasksWithPauseAndResume() {
+	ctx, cancel := context.WithCancel(context.Background())
+	events := []*model.PolymorphicEvent{
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicResolvedEvent(4),
+	}
+	w, e := suite.createWorker(ctx, uint64(testEventSize*10), true)
+	defer w.sinkMemQuota.Close()
+	suite.addEventsToSortEngine(events, e)
+
+	w.pause()
+	taskChan := make(chan *sinkTask, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := w.handleTasks(ctx, taskChan)
+		require.Equal(suite.T(), context.Canceled, err)
+	}()
+
+	wrapper, sink := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
+	taskChan <- &sinkTask{
+		span:          suite.testSpan,
+		lowerBound:    genLowerBound(),
+		getUpperBound: genUpperBoundGetter(4),
+		tableSink:     wrapper,
+		callback:      func(_ sorter.Position, _ model.Ts) { cancel() },
+		isCanceled:    func() bool { return false },
+	}
+	// Pausing a paused worker is a no-op.
+	w.pause()
+	time.Sleep(100 * time.Millisecond)
+	require.Len(suite.T(), taskChan, 1)
+	require.Len(suite.T(), sink.GetEvents(), 0)
+
+	w.resume()
+	wg.Wait()
+	require.Len(suite.T(), taskChan, 0)
+	require.Len(suite.T(), sink.GetEvents(), 2)
+}
+
+// Test Scenario:
+// A paused worker should still exit once the context is canceled.
+func (suite *tableSinkWorkerSuite) TestHandleTasksExitWhenPaused() {
+	ctx, cancel := context.WithCancel(context.Background())
+	w, _ := suite.createWorker(ctx, uint64(testEventSize*10), true)
+	defer w.sinkMemQuota.Close()
+	w.pause()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- w.handleTasks(ctx, make(chan *sinkTask)) }()
+	cancel()
+	require.Equal(suite.T(), context.Canceled, <-errCh)
+}
+
+// Test Scenario:
+// When the table is removed, the worker should emit a terminal resolved ts
+// after all events are emitted, and close the table sink.
+func (suite *tableSinkWorkerSuite) TestHandleTaskWithTableRemoved() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := []*model.PolymorphicEvent{
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicEvent(2, 3, suite.testSpan),
+		genPolymorphicResolvedEvent(4),
+	}
+	w, e := suite.createWorker(ctx, uint64(testEventSize*10), true)
+	defer w.sinkMemQuota.Close()
+	suite.addEventsToSortEngine(events, e)
+
+	wrapper, sink := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
+	var lastWritePos sorter.Position
+	task := &sinkTask{
+		span:          suite.testSpan,
+		lowerBound:    genLowerBound(),
+		getUpperBound: genUpperBoundGetter(4),
+		tableSink:     wrapper,
+		callback:      func(pos sorter.Position, _ model.Ts) { lastWritePos = pos },
+		isCanceled:    func() bool { return false },
+		tableRemoved:  true,
+	}
+	require.NoError(suite.T(), w.handleTask(ctx, task))
+	require.Equal(suite.T(), genUpperBoundGetter(4)(0), lastWritePos)
+	require.Len(suite.T(), sink.GetEvents(), 3)
+	// The table sink can't be closed until all emitted events are flushed.
+	require.Equal(suite.T(), tablepb.TableStateStopping, wrapper.getState())
+
+	for _, event := range sink.GetEvents() {
+		event.Callback()
+	}
+	require.True(suite.T(), wrapper.asyncStop())
+	require.Equal(suite.T(), tablepb.TableStateStopped, wrapper.getState())
+	require.Equal(suite.T(), model.NewResolvedTs(4), wrapper.getCheckpointTs())
+}
+
 func (suite *tableSinkWorkerSuite) TestHandleTaskUseDifferentBatchIDEveryTime() {
 	ctx, cancel := context.WithCancel(context.Background())
 	events := []*model.PolymorphicEvent{
@@ -612,7 +1670,7 @@ func (suite *tableSinkWorkerSuite) TestHandleTaskUseDifferentBatchIDEveryTime()
 	}()
 
 	wrapper, sink := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
-	callback := func(lastWritePos sorter.Position) {
+	callback := func(lastWritePos sorter.Position, _ model.Ts) {
 		require.Equal(suite.T(), sorter.Position{
 			StartTs:  1,
 			CommitTs: 3,
@@ -649,7 +1707,7 @@ func (suite *tableSinkWorkerSuite) TestHandleTaskUseDifferentBatchIDEveryTime()
 	}
 	e.Add(suite.testSpan, events...)
 	// Send another task to make sure the batchID is started from 2.
-	callback = func(_ sorter.Position) {
+	callback = func(_ sorter.Position, _ model.Ts) {
 		cancel()
 	}
 	taskChan <- &sinkTask{
@@ -701,7 +1759,7 @@ func (suite *tableSinkWorkerSuite) TestFetchFromCacheWithFailure() {
 	defer sink.Close()
 
 	chShouldBeClosed := make(chan struct{}, 1)
-	callback := func(lastWritePos sorter.Position) {
+	callback := func(lastWritePos sorter.Position, _ model.Ts) {
 		close(chShouldBeClosed)
 	}
 	taskChan <- &sinkTask{
@@ -744,7 +1802,7 @@ func (suite *tableSinkWorkerSuite) TestHandleTaskWithoutMemory() {
 	defer sink.Close()
 
 	chShouldBeClosed := make(chan struct{}, 1)
-	callback := func(lastWritePos sorter.Position) {
+	callback := func(lastWritePos sorter.Position, _ model.Ts) {
 		require.Equal(suite.T(), genLowerBound().Prev(), lastWritePos)
 		close(chShouldBeClosed)
 	}
@@ -792,7 +1850,7 @@ func (suite *tableSinkWorkerSuite) TestHandleTaskWithCache() {
 	defer sink.Close()
 
 	chShouldBeClosed := make(chan struct{}, 1)
-	callback := func(lastWrittenPos sorter.Position) {
+	callback := func(lastWrittenPos sorter.Position, _ model.Ts) {
 		require.Equal(suite.T(), sorter.Position{StartTs: 2, CommitTs: 4}, lastWrittenPos)
 		close(chShouldBeClosed)
 	}
@@ -809,3 +1867,98 @@ func (suite *tableSinkWorkerSuite) TestHandleTaskWithCache() {
 	cancel()
 	wg.Wait()
 }
+
+// fakeEventEmitter records events and resolved ts emitted by workers, instead
+// of emitting them to a real table sink.
+type fakeEventEmitter struct {
+	mu         sync.Mutex
+	events     []*model.RowChangedEvent
+	resolvedTs []model.ResolvedTs
+	// appendErr is returned by appendRowChangedEvents if it's not nil.
+	appendErr error
+}
+
+func (e *fakeEventEmitter) appendRowChangedEvents(events ...*model.RowChangedEvent) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.appendErr != nil {
+		return e.appendErr
+	}
+	e.events = append(e.events, events...)
+	return nil
+}
+
+func (e *fakeEventEmitter) getSuggestedDelay() time.Duration {
+	return 0
+}
+
+func (e *fakeEventEmitter) updateResolvedTs(ts model.ResolvedTs) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.resolvedTs = append(e.resolvedTs, ts)
+	return nil
+}
+
+// Test Scenario:
+// When the worker has an event emitter, events and resolved ts are emitted by
+// it instead of the table sink of the task.
+func (suite *tableSinkWorkerSuite) TestHandleTaskWithFakeEventEmitter() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := []*model.PolymorphicEvent{
+		genPolymorphicEvent(0, 1, suite.testSpan),
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicResolvedEvent(4),
+	}
+	w, e := suite.createWorker(ctx, uint64(testEventSize*3), true)
+	defer w.sinkMemQuota.Close()
+	suite.addEventsToSortEngine(events, e)
+	emitter := &fakeEventEmitter{}
+	w.emitter = emitter
+
+	wrapper, sink := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
+	var lastWritePos sorter.Position
+	require.NoError(suite.T(), w.handleTask(ctx, &sinkTask{
+		span:          suite.testSpan,
+		lowerBound:    genLowerBound(),
+		getUpperBound: genUpperBoundGetter(4),
+		tableSink:     wrapper,
+		callback: func(pos sorter.Position, _ model.Ts) {
+			lastWritePos = pos
+		},
+		isCanceled: func() bool { return false },
+	}))
+	require.Equal(suite.T(), sorter.Position{StartTs: 3, CommitTs: 4}, lastWritePos)
+	require.Len(suite.T(), emitter.events, 2)
+	require.NotEmpty(suite.T(), emitter.resolvedTs)
+	require.Equal(suite.T(), uint64(4), emitter.resolvedTs[len(emitter.resolvedTs)-1].ResolvedMark())
+	require.Len(suite.T(), sink.GetEvents(), 0)
+}
+
+// Test Scenario:
+// Errors of the event emitter are returned by the worker.
+func (suite *tableSinkWorkerSuite) TestHandleTaskWithFailedEventEmitter() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := []*model.PolymorphicEvent{
+		genPolymorphicEvent(1, 2, suite.testSpan),
+		genPolymorphicResolvedEvent(4),
+	}
+	w, e := suite.createWorker(ctx, uint64(testEventSize*3), true)
+	defer w.sinkMemQuota.Close()
+	suite.addEventsToSortEngine(events, e)
+	appendErr := errors.New("append failed")
+	w.emitter = &fakeEventEmitter{appendErr: appendErr}
+
+	wrapper, sink := createTableSinkWrapper(suite.testChangefeedID, suite.testSpan)
+	err := w.handleTask(ctx, &sinkTask{
+		span:          suite.testSpan,
+		lowerBound:    genLowerBound(),
+		getUpperBound: genUpperBoundGetter(4),
+		tableSink:     wrapper,
+		callback:      func(_ sorter.Position, _ model.Ts) {},
+		isCanceled:    func() bool { return false },
+	})
+	require.Equal(suite.T(), appendErr, errors.Cause(err))
+	require.Len(suite.T(), sink.GetEvents(), 0)
+}
@@ -73,6 +73,14 @@ type tableSinkWrapper struct {
 	// receivedSorterResolvedTs is the resolved ts received from the sorter.
 	// We use this to advance the redo log.
 	receivedSorterResolvedTs atomic.Uint64
+	// lastEmittedCommitTs is the commit ts of the last event emitted to the table sink.
+	lastEmittedCommitTs atomic.Uint64
+	// baselineResolvedTsEmitted indicates whether the first sink task of the
+	// table has emitted a baseline resolved ts.
+	baselineResolvedTsEmitted atomic.Bool
+	// dropped indicates the table has been dropped in upstream, so its sink
+	// tasks close the table sink after all events are emitted.
+	dropped atomic.Bool
 
 	// replicateTs is the ts that the table sink has started to replicate.
 	replicateTs    model.Ts
@@ -86,6 +94,28 @@ type tableSinkWrapper struct {
 	// events in the range (rangeEventCounts[i-1].lastPos, rangeEventCounts[i].lastPos].
 	rangeEventCounts   []rangeEventCount
 	rangeEventCountsMu sync.Mutex
+
+	// splitTxnProgress records the events of a split transaction which have
+	// been emitted by the last sink task, so the next task can skip them.
+	splitTxnProgress   splitTxnProgress
+	splitTxnProgressMu sync.Mutex
+}
+
+// splitTxnProgress is the progress of a transaction which is split into
+// batches, and is interrupted after some batches are emitted.
+type splitTxnProgress struct {
+	// lowerBound is the lower bound of the next task, where the transaction starts.
+	lowerBound sorter.Position
+	// events is how many events of the transaction have been emitted.
+	events int
+	// version is the version of the table sink which the events are emitted to.
+	version uint64
+}
+
+// delaySuggester can be implemented by table sinks to suggest workers to slow
+// down reading events when the downstream has a high latency.
+type delaySuggester interface {
+	SuggestedDelay() time.Duration
 }
 
 type rangeEventCount struct {
@@ -186,6 +216,17 @@ func (t *tableSinkWrapper) appendRowChangedEvents(events ...*model.RowChangedEve
 	return nil
 }
 
+// getSuggestedDelay returns the delay suggested by the underlying table sink,
+// it returns 0 if the table sink doesn't suggest any delay.
+func (t *tableSinkWrapper) getSuggestedDelay() time.Duration {
+	t.tableSink.RLock()
+	defer t.tableSink.RUnlock()
+	if s, ok := t.tableSink.s.(delaySuggester); ok {
+		return s.SuggestedDelay()
+	}
+	return 0
+}
+
 func (t *tableSinkWrapper) updateBarrierTs(ts model.Ts) {
 	for {
 		old := t.barrierTs.Load()
@@ -195,6 +236,15 @@ func (t *tableSinkWrapper) updateBarrierTs(ts model.Ts) {
 	}
 }
 
+func (t *tableSinkWrapper) updateLastEmittedCommitTs(ts model.Ts) {
+	for {
+		old := t.lastEmittedCommitTs.Load()
+		if ts <= old || t.lastEmittedCommitTs.CompareAndSwap(old, ts) {
+			break
+		}
+	}
+}
+
 func (t *tableSinkWrapper) updateReceivedSorterResolvedTs(ts model.Ts) {
 	for {
 		old := t.receivedSorterResolvedTs.Load()
@@ -219,8 +269,16 @@ func (t *tableSinkWrapper) updateResolvedTs(ts model.ResolvedTs) error {
 	}
 	t.tableSink.innerMu.Lock()
 	defer t.tableSink.innerMu.Unlock()
+	// The same resolved ts can be updated again when a task is retried,
+	// skip it to avoid useless work in the table sink.
+	if !ts.Greater(t.tableSink.resolvedTs) {
+		return nil
+	}
+	if err := t.tableSink.s.UpdateResolvedTs(ts); err != nil {
+		return err
+	}
 	t.tableSink.resolvedTs = ts
-	return t.tableSink.s.UpdateResolvedTs(ts)
+	return nil
 }
 
 func (t *tableSinkWrapper) getCheckpointTs() model.ResolvedTs {
@@ -392,6 +450,38 @@ func (t *tableSinkWrapper) restart(ctx context.Context) (err error) {
 	return nil
 }
 
+// recordSplitTxnProgress records that the first events of the transaction
+// starting at lowerBound have been emitted to the table sink with a batch
+// resolved ts.
+func (t *tableSinkWrapper) recordSplitTxnProgress(lowerBound sorter.Position, events int) {
+	t.tableSink.RLock()
+	version := t.tableSink.version
+	t.tableSink.RUnlock()
+
+	t.splitTxnProgressMu.Lock()
+	defer t.splitTxnProgressMu.Unlock()
+	t.splitTxnProgress = splitTxnProgress{lowerBound: lowerBound, events: events, version: version}
+}
+
+// takeSplitTxnProgress returns how many events from lowerBound have been emitted
+// and clears the progress. Zero is returned if the progress doesn't start at
+// lowerBound, or the table sink has been restarted after the events are emitted,
+// in which case the events may be lost and should be emitted again.
+func (t *tableSinkWrapper) takeSplitTxnProgress(lowerBound sorter.Position) int {
+	t.tableSink.RLock()
+	version := t.tableSink.version
+	t.tableSink.RUnlock()
+
+	t.splitTxnProgressMu.Lock()
+	defer t.splitTxnProgressMu.Unlock()
+	progress := t.splitTxnProgress
+	t.splitTxnProgress = splitTxnProgress{}
+	if progress.events == 0 || progress.version != version || progress.lowerBound.Compare(lowerBound) != 0 {
+		return 0
+	}
+	return progress.events
+}
+
 func (t *tableSinkWrapper) updateRangeEventCounts(eventCount rangeEventCount) {
 	t.rangeEventCountsMu.Lock()
 	defer t.rangeEventCountsMu.Unlock()
@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/processor/sourcemanager/sorter"
 	"github.com/pingcap/tiflow/cdc/processor/tablepb"
 	"github.com/pingcap/tiflow/cdc/sink/dmlsink"
 	"github.com/pingcap/tiflow/cdc/sink/tablesink"
@@ -152,6 +153,46 @@ func TestUpdateReceivedSorterResolvedTs(t *testing.T) {
 	require.Equal(t, tablepb.TableStatePrepared, wrapper.getState())
 }
 
+// countingTableSink is a table sink which counts how many times its resolved
+// ts is updated.
+type countingTableSink struct {
+	tablesink.TableSink
+	updates int
+}
+
+func (c *countingTableSink) UpdateResolvedTs(ts model.ResolvedTs) error {
+	c.updates++
+	return c.TableSink.UpdateResolvedTs(ts)
+}
+
+func TestUpdateResolvedTsSkipNonAdvancingTs(t *testing.T) {
+	t.Parallel()
+
+	wrapper, _ := createTableSinkWrapper(
+		model.DefaultChangeFeedID("1"), spanz.TableIDToComparableSpan(1))
+	inner := &countingTableSink{TableSink: wrapper.tableSink.s}
+	wrapper.tableSink.s = inner
+
+	batchTs := model.NewResolvedTs(10)
+	batchTs.Mode = model.BatchResolvedMode
+	batchTs.BatchID = 1
+	require.NoError(t, wrapper.updateResolvedTs(batchTs))
+	require.Equal(t, 1, inner.updates)
+	// The same batch is updated again.
+	require.NoError(t, wrapper.updateResolvedTs(batchTs))
+	require.Equal(t, 1, inner.updates)
+
+	require.NoError(t, wrapper.updateResolvedTs(model.NewResolvedTs(10)))
+	require.Equal(t, 2, inner.updates)
+	require.NoError(t, wrapper.updateResolvedTs(model.NewResolvedTs(10)))
+	require.NoError(t, wrapper.updateResolvedTs(batchTs))
+	require.NoError(t, wrapper.updateResolvedTs(model.NewResolvedTs(9)))
+	require.Equal(t, 2, inner.updates)
+
+	require.NoError(t, wrapper.updateResolvedTs(model.NewResolvedTs(11)))
+	require.Equal(t, 3, inner.updates)
+}
+
 func TestHandleNilRowChangedEvents(t *testing.T) {
 	t.Parallel()
 
@@ -236,6 +277,32 @@ func TestHandleRowChangedEventNormalEvent(t *testing.T) {
 	require.Equal(t, uint64(224), size)
 }
 
+func TestHandleRowChangedEventsWithZeroSizeColumns(t *testing.T) {
+	t.Parallel()
+
+	// The approximate size of all columns is 0, but every event should still
+	// cost memory, otherwise the memory accounting of workers drifts.
+	genEvent := func(commitTs uint64) *model.PolymorphicEvent {
+		return &model.PolymorphicEvent{
+			CRTs:  commitTs,
+			RawKV: &model.RawKVEntry{OpType: model.OpTypePut},
+			Row: &model.RowChangedEvent{
+				CommitTs: commitTs,
+				Columns:  []*model.Column{{Name: "col1", ApproximateBytes: 0}},
+				Table:    &model.TableName{},
+			},
+		}
+	}
+	changefeedID := model.DefaultChangeFeedID("1")
+	span := spanz.TableIDToComparableSpan(1)
+
+	_, size := handleRowChangedEvents(changefeedID, span, genEvent(1))
+	require.Greater(t, size, uint64(0))
+	result, totalSize := handleRowChangedEvents(changefeedID, span, genEvent(1), genEvent(2), genEvent(3))
+	require.Len(t, result, 3)
+	require.Equal(t, 3*size, totalSize)
+}
+
 func TestGetUpperBoundTs(t *testing.T) {
 	t.Parallel()
 	wrapper, _ := createTableSinkWrapper(
@@ -370,3 +437,26 @@ func TestTableSinkWrapperSinkInner(t *testing.T) {
 	isStuck, _ = wrapper.sinkMaybeStuck(100 * time.Millisecond)
 	require.True(t, isStuck)
 }
+
+func TestTableSinkWrapperSplitTxnProgress(t *testing.T) {
+	t.Parallel()
+
+	wrapper, _ := createTableSinkWrapper(
+		model.DefaultChangeFeedID("1"), spanz.TableIDToComparableSpan(1))
+	lowerBound := sorter.Position{StartTs: 1, CommitTs: 10}
+
+	wrapper.recordSplitTxnProgress(lowerBound, 4)
+	require.Equal(t, 4, wrapper.takeSplitTxnProgress(lowerBound))
+	// The progress is cleared after it's taken.
+	require.Equal(t, 0, wrapper.takeSplitTxnProgress(lowerBound))
+
+	// The progress doesn't start at the lower bound.
+	wrapper.recordSplitTxnProgress(lowerBound, 4)
+	require.Equal(t, 0, wrapper.takeSplitTxnProgress(lowerBound.Next()))
+	require.Equal(t, 0, wrapper.takeSplitTxnProgress(lowerBound))
+
+	// The table sink is restarted, the emitted events may be lost.
+	wrapper.recordSplitTxnProgress(lowerBound, 4)
+	wrapper.tableSink.version++
+	require.Equal(t, 0, wrapper.takeSplitTxnProgress(lowerBound))
+}
@@ -14,6 +14,7 @@
 package sinkmanager
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/pingcap/log"
@@ -41,10 +42,58 @@ var (
 	// Sink manager schedules table tasks based on lag. Limit the max task range
 	// can be helpful to reduce changefeed latency for large initial data.
 	maxTaskTimeRange = 30 * time.Minute
+
+	// maxSuggestedDelay is the upper bound of the delay suggested by table sinks.
+	maxSuggestedDelay = time.Second
+
+	// tableMemSoftCapRatio is the ratio of the sink memory quota which a table
+	// can hold before it waits for its memory to be released if other tables
+	// need memory too, so that a greedy table can't starve others.
+	tableMemSoftCapRatio = 0.5
+
+	// emitRetryBackoff is the backoff before the first retry of emitting events
+	// to a table sink, it's doubled for each following retry.
+	emitRetryBackoff = 10 * time.Millisecond
 )
 
 // Used to record the progress of the table.
-type writeSuccessCallback func(lastWrittenPos sorter.Position)
+// lastCommitTs is the commit ts of the last event emitted by the task,
+// it is 0 if no event is emitted.
+type writeSuccessCallback func(lastWrittenPos sorter.Position, lastCommitTs model.Ts)
+
+// sinkTaskResult is the structured result of a finished sink task, which is
+// useful for observability.
+type sinkTaskResult struct {
+	span tablepb.Span
+	// lastPos is the last position written by the task. It's reported to
+	// writeSuccessCallback only if the task makes progress safely.
+	lastPos sorter.Position
+	// lastCommitTs is the commit ts of the last event emitted by the task.
+	lastCommitTs model.Ts
+	// rows and bytes are the count and size of events received by the task.
+	rows  int
+	bytes uint64
+	// avgRowBytes and maxRowBytes are the average and max approximate size of
+	// rows received by the task, which can be used to seed the next task.
+	avgRowBytes uint64
+	maxRowBytes uint64
+	duration    time.Duration
+	err         error
+}
+
+// TableProgress is the progress of a table reported by sink tasks periodically.
+type TableProgress struct {
+	Span tablepb.Span
+	// CRTs is the commit ts of the last event received by the task.
+	CRTs model.Ts
+	// Rows and Bytes are the count and size of events received by the task.
+	Rows  int
+	Bytes uint64
+}
+
+// Used to report the result of a finished task. Unlike writeSuccessCallback,
+// it's called even if the task fails.
+type taskResultCallback func(result sinkTaskResult)
 
 // Used to get an upper bound.
 type upperBoundGetter func(tableSinkUpperBoundTs model.Ts) sorter.Position
@@ -66,6 +115,32 @@ type sinkTask struct {
 	tableSink     *tableSinkWrapper
 	callback      writeSuccessCallback
 	isCanceled    isCanceled
+	// resultCallback is optional. If it's not nil, it's called with the
+	// result once the task is finished.
+	resultCallback taskResultCallback
+	// deadline is optional. If it's not zero, the task stops scanning events
+	// once the deadline is exceeded, to bound the time of one turn of a table.
+	deadline time.Time
+	// tableRemoved indicates the table has been removed, for example dropped
+	// in upstream. After all events in the range are emitted, the task emits
+	// a terminal resolved ts and closes the table sink.
+	tableRemoved bool
+}
+
+// deadlineExceeded returns whether the task has a deadline and it's exceeded.
+func (t *sinkTask) deadlineExceeded() bool {
+	return !t.deadline.IsZero() && time.Now().After(t.deadline)
+}
+
+// taskDeadlineExceededError is returned by sink workers if a task is stopped
+// because its deadline is exceeded. It isn't a fatal error for workers.
+type taskDeadlineExceededError struct {
+	deadline time.Time
+}
+
+// Error implements builtin `error` interface.
+func (e taskDeadlineExceededError) Error() string {
+	return fmt.Sprintf("sink task deadline %s exceeded", e.deadline.Format(time.RFC3339Nano))
 }
 
 // redoTask is a task for the redo log.
@@ -22,7 +22,6 @@ import (
 	"github.com/pingcap/tiflow/cdc/kv"
 	"github.com/pingcap/tiflow/cdc/kv/sharedconn"
 	"github.com/pingcap/tiflow/cdc/model"
-	"github.com/pingcap/tiflow/cdc/processor/memquota"
 	pullerwrapper "github.com/pingcap/tiflow/cdc/processor/sourcemanager/puller"
 	"github.com/pingcap/tiflow/cdc/processor/sourcemanager/sorter"
 	"github.com/pingcap/tiflow/cdc/processor/tablepb"
@@ -164,12 +163,24 @@ func (m *SourceManager) OnResolve(action func(tablepb.Span, model.Ts)) {
 // FetchByTable just wrap the engine's FetchByTable method.
 func (m *SourceManager) FetchByTable(
 	span tablepb.Span, lowerBound, upperBound sorter.Position,
-	quota *memquota.MemQuota,
+	quota sorter.MemQuota,
 ) *sorter.MountedEventIter {
 	iter := m.engine.FetchByTable(span, lowerBound, upperBound)
 	return sorter.NewMountedEventIter(m.changefeedID, iter, m.mg, defaultMaxBatchSize, quota)
 }
 
+// EstimateEventCountByTable estimates how many events of the table are in
+// [lowerBound, upperBound]. ok is false if the engine can't estimate it.
+func (m *SourceManager) EstimateEventCountByTable(
+	span tablepb.Span, lowerBound, upperBound sorter.Position,
+) (count uint64, ok bool) {
+	estimator, ok := m.engine.(sorter.EventCountEstimator)
+	if !ok {
+		return 0, false
+	}
+	return estimator.EstimateCountByTable(span, lowerBound, upperBound)
+}
+
 // CleanByTable just wrap the engine's CleanByTable method.
 func (m *SourceManager) CleanByTable(span tablepb.Span, upperBound sorter.Position) error {
 	return m.engine.CleanByTable(span, upperBound)
@@ -81,6 +81,14 @@ type SortEngine interface {
 	SlotsAndHasher() (slotCount int, hasher func(tablepb.Span, int) int)
 }
 
+// EventCountEstimator is an optional interface of SortEngine, which is
+// implemented by engines that can estimate event counts cheaply.
+type EventCountEstimator interface {
+	// EstimateCountByTable estimates how many events of the given table are in
+	// [lowerBound, upperBound]. ok is false if the count can't be estimated.
+	EstimateCountByTable(span tablepb.Span, lowerBound, upperBound Position) (count uint64, ok bool)
+}
+
 // EventIterator is an iterator to fetch events from SortEngine.
 // It's unnecessary to be thread-safe.
 type EventIterator interface {
@@ -128,8 +136,12 @@ func (p Position) Next() Position {
 }
 
 // Prev can only be called on a valid Position.
+// It returns an invalid Position if there is no previous valid one.
 func (p Position) Prev() Position {
 	if p.StartTs == 0 {
+		if p.CommitTs <= 1 {
+			return Position{}
+		}
 		return Position{
 			StartTs:  p.CommitTs - 2,
 			CommitTs: p.CommitTs - 1,
@@ -165,6 +177,15 @@ func (p Position) IsCommitFence() bool {
 	return p.CommitTs > 0 && p.StartTs+1 >= p.CommitTs
 }
 
+// ResolvedTs returns the max CommitTs that all transactions with the CommitTs
+// are less than or equal to the position.
+func (p Position) ResolvedTs() model.Ts {
+	if p.IsCommitFence() || p.CommitTs == 0 {
+		return p.CommitTs
+	}
+	return p.CommitTs - 1
+}
+
 // TableStats of a sort engine.
 type TableStats struct {
 	ReceivedMaxCommitTs   model.Ts
@@ -0,0 +1,60 @@
+// Copyright 2023 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sorter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPositionPrev(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		pos  Position
+		prev Position
+	}{
+		{Position{StartTs: 5, CommitTs: 10}, Position{StartTs: 4, CommitTs: 10}},
+		{Position{StartTs: 0, CommitTs: 10}, Position{StartTs: 8, CommitTs: 9}},
+		{Position{StartTs: 0, CommitTs: 2}, Position{StartTs: 0, CommitTs: 1}},
+		// No previous valid position, should not underflow.
+		{Position{StartTs: 0, CommitTs: 1}, Position{}},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.prev, c.pos.Prev(), "pos: %v", c.pos)
+	}
+
+	// Prev and Next are reversible.
+	pos := Position{StartTs: 5, CommitTs: 10}
+	require.Equal(t, pos, pos.Prev().Next())
+	require.Equal(t, pos, pos.Next().Prev())
+}
+
+func TestPositionResolvedTs(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		pos        Position
+		resolvedTs uint64
+	}{
+		{GenCommitFence(10), 10},
+		{Position{StartTs: 5, CommitTs: 10}, 9},
+		{Position{StartTs: 0, CommitTs: 1}, 1},
+		{Position{}, 0},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.resolvedTs, c.pos.ResolvedTs(), "pos: %v", c.pos)
+	}
+}
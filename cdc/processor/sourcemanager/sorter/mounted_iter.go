@@ -19,15 +19,22 @@ import (
 
 	"github.com/pingcap/tiflow/cdc/entry"
 	"github.com/pingcap/tiflow/cdc/model"
-	"github.com/pingcap/tiflow/cdc/processor/memquota"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// MemQuota is used by MountedEventIter to limit the memory usage of events
+// which are fetched but not consumed. *memquota.MemQuota implements it.
+type MemQuota interface {
+	TryAcquire(nBytes uint64) bool
+	ForceAcquire(nBytes uint64)
+	Refund(nBytes uint64)
+}
+
 // MountedEventIter is just like EventIterator, but returns mounted events.
 type MountedEventIter struct {
 	iter  EventIterator
 	mg    entry.MounterGroup
-	quota *memquota.MemQuota
+	quota MemQuota
 
 	rawEvents      []rawEvent
 	rawEventBuffer rawEvent
@@ -43,7 +50,7 @@ func NewMountedEventIter(
 	iter EventIterator,
 	mg entry.MounterGroup,
 	maxBatchSize int,
-	quota *memquota.MemQuota,
+	quota MemQuota,
 ) *MountedEventIter {
 	return &MountedEventIter{
 		iter:      iter,
@@ -51,6 +51,11 @@ func (e SinkInternalError) Error() string {
 	return e.err.Error()
 }
 
+// Unwrap returns the underlying error.
+func (e SinkInternalError) Unwrap() error {
+	return e.err
+}
+
 // NewSinkInternalError creates a SinkInternalError.
 func NewSinkInternalError(err error) SinkInternalError {
 	return SinkInternalError{err}
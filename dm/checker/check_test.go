@@ -54,6 +54,7 @@ func ignoreExcept(itemMap map[string]struct{}) []string {
 		config.MetaPositionChecking,
 		config.ConnNumberChecking,
 		config.TargetDBPrivilegeChecking,
+		config.TimestampDefaultsChecking,
 		config.LightningEmptyRegionChecking,
 		config.LightningRegionDistributionChecking,
 		config.LightningDownstreamVersionChecking,
@@ -371,6 +371,9 @@ func (c *Checker) Init(ctx context.Context) (err error) {
 			if _, ok := c.checkingItems[config.BinlogDBChecking]; ok {
 				c.checkList = append(c.checkList, checker.NewBinlogDBChecker(instance.sourceDB, instance.sourceDBinfo, info.sourceID2InterestedDB[i], instance.cfg.CaseSensitive))
 			}
+			if _, ok := c.checkingItems[config.TimestampDefaultsChecking]; ok {
+				c.checkList = append(c.checkList, checker.NewTimestampDefaultsChecker(instance.sourceDB, instance.sourceDBinfo, instance.targetDB))
+			}
 		}
 	}
 
@@ -38,6 +38,7 @@ const (
 	MetaPositionChecking         = "meta_position"
 	ConnNumberChecking           = "conn_number"
 	TargetDBPrivilegeChecking    = "target_privilege"
+	TimestampDefaultsChecking    = "explicit_defaults_for_timestamp"
 	// lighting prechecks.
 	LightningEmptyRegionChecking        = "empty_region"
 	LightningRegionDistributionChecking = "region_distribution"
@@ -64,6 +65,7 @@ var AllCheckingItems = map[string]string{
 	MetaPositionChecking:         "meta position valid checking item",
 	ConnNumberChecking:           "connection number checking item",
 	TargetDBPrivilegeChecking:    "privileges of target DB checking item",
+	TimestampDefaultsChecking:    "consistent explicit_defaults_for_timestamp of source and target DB checking item",
 	// lightning prechecks
 	LightningEmptyRegionChecking:        "physical import mode empty region checking item",
 	LightningRegionDistributionChecking: "physical import mode region distribution checking item",
@@ -32,7 +32,7 @@ func TestCheckingItems(t *testing.T) {
 	}
 	// remember to update the number when add new checking items.
 	require.Equal(t, 5, lightningCheck)
-	require.Equal(t, 16, normalCheck)
+	require.Equal(t, 17, normalCheck)
 	// all LightningPrechecks can be found by iterating AllCheckingItems
 	require.Len(t, LightningPrechecks, lightningCheck)
 	require.Error(t, ValidateCheckingItem("xxx"))
@@ -21,6 +21,8 @@ import (
 	toolsutils "github.com/pingcap/tidb-tools/pkg/utils"
 	"github.com/pingcap/tidb/util/dbutil"
 	"github.com/pingcap/tiflow/dm/pkg/conn"
+	tcontext "github.com/pingcap/tiflow/dm/pkg/context"
+	"github.com/pingcap/tiflow/dm/pkg/log"
 )
 
 // MySQLVersionChecker checks mysql/mariadb/rds,... version.
@@ -157,3 +159,61 @@ func (pc *MySQLServerIDChecker) Check(ctx context.Context) *Result {
 func (pc *MySQLServerIDChecker) Name() string {
 	return "mysql_server_id"
 }
+
+/*****************************************************/
+
+// TimestampDefaultsChecker checks whether `explicit_defaults_for_timestamp` of
+// source and target DB are the same. Otherwise TIMESTAMP columns created by
+// the migrated DDLs may have different default values and nullability.
+type TimestampDefaultsChecker struct {
+	sourceDB *conn.BaseDB
+	dbinfo   *dbutil.DBConfig
+	targetDB *conn.BaseDB
+}
+
+// NewTimestampDefaultsChecker returns a RealChecker.
+func NewTimestampDefaultsChecker(sourceDB *conn.BaseDB, dbinfo *dbutil.DBConfig, targetDB *conn.BaseDB) RealChecker {
+	return &TimestampDefaultsChecker{sourceDB: sourceDB, dbinfo: dbinfo, targetDB: targetDB}
+}
+
+// Check implements the RealChecker interface.
+func (pc *TimestampDefaultsChecker) Check(ctx context.Context) *Result {
+	result := &Result{
+		Name:  pc.Name(),
+		Desc:  "check whether explicit_defaults_for_timestamp of source and target DB are the same",
+		State: StateWarning,
+		Extra: fmt.Sprintf("address of db instance - %s:%d", pc.dbinfo.Host, pc.dbinfo.Port),
+	}
+
+	tctx := tcontext.NewContext(ctx, log.L())
+	source, err := conn.GetExplicitDefaultsForTimestamp(tctx, pc.sourceDB)
+	if err != nil {
+		markCheckError(result, err)
+		return result
+	}
+	target, err := conn.GetExplicitDefaultsForTimestamp(tctx, pc.targetDB)
+	if err != nil {
+		markCheckError(result, err)
+		return result
+	}
+	if source != target {
+		result.Errors = append(result.Errors, NewWarn("explicit_defaults_for_timestamp is %s in source DB but %s in target DB",
+			onOff(source), onOff(target)))
+		result.Instruction = "Set explicit_defaults_for_timestamp to the same value, or TIMESTAMP columns created by DDLs may have different default values and nullability"
+		return result
+	}
+	result.State = StateSuccess
+	return result
+}
+
+// Name implements the RealChecker interface.
+func (pc *TimestampDefaultsChecker) Name() string {
+	return "explicit_defaults_for_timestamp"
+}
+
+func onOff(on bool) string {
+	if on {
+		return "ON"
+	}
+	return "OFF"
+}
@@ -19,6 +19,7 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/pingcap/tidb/util/dbutil"
+	"github.com/pingcap/tiflow/dm/pkg/conn"
 	"github.com/stretchr/testify/require"
 )
 
@@ -62,3 +63,37 @@ func TestVersionInstruction(t *testing.T) {
 	require.Equal(t, result.State, StateWarning)
 	require.Equal(t, result.Instruction, "It is recommended that you select a database version that meets the requirements before performing data migration. Otherwise data inconsistency or task exceptions might occur.")
 }
+
+func TestTimestampDefaultsChecker(t *testing.T) {
+	sourceDB, sourceMock, err := sqlmock.New()
+	require.NoError(t, err)
+	targetDB, targetMock, err := sqlmock.New()
+	require.NoError(t, err)
+	checker := NewTimestampDefaultsChecker(conn.NewBaseDBForTest(sourceDB), &dbutil.DBConfig{}, conn.NewBaseDBForTest(targetDB))
+	ctx := context.Background()
+
+	cases := []struct {
+		source string
+		target string
+		state  State
+	}{
+		{"ON", "ON", StateSuccess},
+		{"OFF", "0", StateSuccess},
+		{"OFF", "ON", StateWarning},
+		{"1", "OFF", StateWarning},
+	}
+	for _, cs := range cases {
+		sourceMock.ExpectQuery("SHOW GLOBAL VARIABLES LIKE 'explicit_defaults_for_timestamp'").WillReturnRows(
+			sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("explicit_defaults_for_timestamp", cs.source))
+		targetMock.ExpectQuery("SHOW GLOBAL VARIABLES LIKE 'explicit_defaults_for_timestamp'").WillReturnRows(
+			sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("explicit_defaults_for_timestamp", cs.target))
+		result := checker.Check(ctx)
+		require.Equal(t, cs.state, result.State, "source: %s, target: %s", cs.source, cs.target)
+		if cs.state == StateWarning {
+			require.Len(t, result.Errors, 1)
+			require.Contains(t, result.Errors[0].ShortErr, "explicit_defaults_for_timestamp is")
+		}
+	}
+	require.NoError(t, sourceMock.ExpectationsWereMet())
+	require.NoError(t, targetMock.ExpectationsWereMet())
+}
@@ -75,6 +75,10 @@ type BaseConn struct {
 	DBConn        *sql.Conn
 	Scope         terror.ErrScope
 	RetryStrategy retry.Strategy
+
+	// collationConnection caches session variable `collation_connection`,
+	// see GetCollationConnection.
+	collationConnection string
 }
 
 // NewBaseConn builds BaseConn to connect real DB.
@@ -45,7 +45,7 @@ func TestBaseConn(t *testing.T) {
 	dbConn, err := db.Conn(tctx.Context())
 	require.NoError(t, err)
 
-	baseConn = &BaseConn{dbConn, terror.ScopeNotSet, nil}
+	baseConn = &BaseConn{DBConn: dbConn, Scope: terror.ScopeNotSet}
 
 	err = baseConn.SetRetryStrategy(&retry.FiniteRetryStrategy{})
 	require.NoError(t, err)
@@ -111,7 +111,7 @@ func TestAutoSplit4TxnTooLarge(t *testing.T) {
 	dbConn, err := db.Conn(tctx.Context())
 	require.NoError(t, err)
 
-	baseConn := &BaseConn{dbConn, terror.ScopeNotSet, nil}
+	baseConn := &BaseConn{DBConn: dbConn, Scope: terror.ScopeNotSet}
 
 	errTxnTooLarge := &mysql.MySQLError{
 		Number:  errno.ErrTxnTooLarge,
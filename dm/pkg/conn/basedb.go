@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"net"
 	"net/url"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -172,6 +173,56 @@ func (d *DefaultDBProviderImpl) Apply(config ScopedDBConfig) (*BaseDB, error) {
 	return NewBaseDB(db, config.Scope, doFuncInClose), nil
 }
 
+// normalizeDSNKey canonicalizes the server address part of a DSN, so that DSNs
+// connecting to the same server generate the same key, which can be used as a
+// cache key of per-server information like flavor. User, password, database and
+// parameters are not included. If the DSN can't be parsed, it's returned as is.
+func normalizeDSNKey(dsn string) string {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return dsn
+	}
+	netType := strings.ToLower(cfg.Net)
+	addr := cfg.Addr
+	switch netType {
+	case "tcp", "tcp4", "tcp6":
+		netType = "tcp"
+		host, port, err2 := net.SplitHostPort(addr)
+		if err2 != nil {
+			// the driver only adds the default port for "tcp".
+			host, port = strings.Trim(addr, "[]"), "3306"
+		}
+		host = strings.ToLower(host)
+		// use the canonical form of IP, e.g. "0:0:0:0:0:0:0:1" => "::1".
+		if ip := net.ParseIP(host); ip != nil {
+			host = ip.String()
+		}
+		addr = net.JoinHostPort(host, port)
+	case "unix":
+		addr = filepath.Clean(addr)
+	}
+	return netType + "(" + addr + ")"
+}
+
+// RedactDSN returns the canonical form of a DSN with the password masked, which
+// is safe to be logged. Server address, user, database and parameters are kept.
+// If the DSN can't be parsed, the part before the last "@" is masked.
+func RedactDSN(dsn string) string {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		if i := strings.LastIndex(dsn, "@"); i >= 0 {
+			return redactedPassword + dsn[i:]
+		}
+		return dsn
+	}
+	if cfg.Passwd != "" {
+		cfg.Passwd = redactedPassword
+	}
+	return cfg.FormatDSN()
+}
+
+const redactedPassword = "******"
+
 // BaseDB wraps *sql.DB, control the BaseConn.
 type BaseDB struct {
 	DB *sql.DB
@@ -188,6 +239,10 @@ type BaseDB struct {
 
 	// only use in unit test
 	doNotClose bool
+
+	flavorMu sync.Mutex // protects isTiDB
+	// isTiDB caches whether the server is TiDB, nil means not detected yet.
+	isTiDB *bool
 }
 
 // NewBaseDB returns *BaseDB object for test.
@@ -104,3 +104,88 @@ func TestGetBaseConnWontBlock(t *testing.T) {
 	_, err = baseDB.GetBaseConn(ctx)
 	require.Error(t, err)
 }
+
+func TestRedactDSN(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		dsn      string
+		redacted string
+	}{
+		{"root:123456@tcp(127.0.0.1:3306)/db?charset=utf8mb4", "root:******@tcp(127.0.0.1:3306)/db?charset=utf8mb4"},
+		{"root@tcp(localhost:4000)/", "root@tcp(localhost:4000)/"},
+		{"root:@tcp(localhost:4000)/", "root@tcp(localhost:4000)/"},
+		{"root:p@ss:w0rd@tcp([::1]:3306)/test", "root:******@tcp([::1]:3306)/test"},
+		{"root:123@tcp(127.0.0.1)/", "root:******@tcp(127.0.0.1:3306)/"},
+		{"u:pw@unix(/tmp/mysql.sock)/db", "u:******@unix(/tmp/mysql.sock)/db"},
+		{"/", "tcp(127.0.0.1:3306)/"},
+		// can't be parsed.
+		{"root:123@tcp(127.0.0.1:3306)", "******@tcp(127.0.0.1:3306)"},
+		{"not a dsn", "not a dsn"},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.redacted, RedactDSN(c.dsn), c.dsn)
+		require.NotContains(t, RedactDSN(c.dsn), "123")
+	}
+}
+
+func TestNormalizeDSNKey(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		dsns []string
+		key  string
+	}{
+		{
+			[]string{
+				"root:123@tcp(127.0.0.1:3306)/?charset=utf8mb4",
+				"test@tcp(127.0.0.1)/db",
+				"root@tcp4(127.0.0.1:3306)/",
+			},
+			"tcp(127.0.0.1:3306)",
+		},
+		{
+			[]string{
+				"root@tcp(LocalHost:4000)/",
+				"root@tcp(localhost:4000)/?foreign_key_checks=0",
+			},
+			"tcp(localhost:4000)",
+		},
+		{
+			[]string{
+				"root@tcp([::1]:3306)/",
+				"root@tcp([0:0:0:0:0:0:0:1]:3306)/",
+				"root@tcp6([::1])/",
+			},
+			"tcp([::1]:3306)",
+		},
+		{
+			[]string{
+				"root@tcp([FE80::1]:3307)/",
+				"root@tcp([fe80:0::1]:3307)/",
+			},
+			"tcp([fe80::1]:3307)",
+		},
+		{
+			[]string{
+				"root@unix(/tmp/mysql.sock)/",
+				"root:123@unix(/tmp//mysql.sock)/db?charset=utf8mb4",
+				"root@unix(/tmp/./mysql.sock)/",
+			},
+			"unix(/tmp/mysql.sock)",
+		},
+	}
+	for _, c := range cases {
+		for _, dsn := range c.dsns {
+			require.Equal(t, c.key, normalizeDSNKey(dsn), "dsn: %s", dsn)
+		}
+	}
+
+	// keys should not collide between different servers.
+	require.NotEqual(t, normalizeDSNKey("root@tcp(127.0.0.1:3306)/"), normalizeDSNKey("root@tcp(127.0.0.1:3307)/"))
+	require.NotEqual(t, normalizeDSNKey("root@tcp([::1]:3306)/"), normalizeDSNKey("root@tcp(127.0.0.1:3306)/"))
+	require.NotEqual(t, normalizeDSNKey("root@unix(/tmp/a.sock)/"), normalizeDSNKey("root@unix(/tmp/b.sock)/"))
+
+	// invalid DSN is returned as is.
+	require.Equal(t, "invalid dsn", normalizeDSNKey("invalid dsn"))
+}
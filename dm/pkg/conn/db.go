@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -151,6 +152,70 @@ func GetSlaveServerID(ctx *tcontext.Context, db *BaseDB) (map[uint32]struct{}, e
 	return serverIDs, nil
 }
 
+// GetRelayLogSpace gets the total size of all relay log files (`Relay_Log_Space`)
+// of the replica. It returns 0 without error if the server is not a replica.
+func GetRelayLogSpace(ctx *tcontext.Context, db *BaseDB) (uint64, error) {
+	// need REPLICATION CLIENT privilege
+	rows, err := db.QueryContext(ctx, `SHOW SLAVE STATUS`)
+	if err != nil {
+		return 0, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+	defer func() {
+		_ = rows.Close()
+		_ = rows.Err()
+	}()
+
+	rowsResult, err := export.GetSpecifiedColumnValueAndClose(rows, "Relay_Log_Space")
+	if err != nil {
+		return 0, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+	// not a replica.
+	if len(rowsResult) == 0 {
+		return 0, nil
+	}
+	var relayLogSpace uint64
+	// a multi-source replica returns one row for each channel.
+	for _, spaceStr := range rowsResult {
+		space, err := strconv.ParseUint(spaceStr, 10, 64)
+		if err != nil {
+			return 0, terror.ErrDBUnExpect.Delegate(err, fmt.Sprintf("invalid `Relay_Log_Space` value '%s'", spaceStr))
+		}
+		relayLogSpace += space
+	}
+	return relayLogSpace, nil
+}
+
+// GetReplicaLastError gets `Last_IO_Error` and `Last_SQL_Error` of the replica,
+// which explain why the replication is stopped or lagging. Errors of all channels
+// of a multi-source replica are joined by "; ". It returns empty strings without
+// error if the server is not a replica.
+func GetReplicaLastError(ctx *tcontext.Context, db *BaseDB) (ioErr, sqlErr string, err error) {
+	// need REPLICATION CLIENT privilege
+	rows, err := db.QueryContext(ctx, `SHOW SLAVE STATUS`)
+	if err != nil {
+		return "", "", terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+	defer func() {
+		_ = rows.Close()
+		_ = rows.Err()
+	}()
+
+	rowsResult, err := export.GetSpecifiedColumnValuesAndClose(rows, "Last_IO_Error", "Last_SQL_Error")
+	if err != nil {
+		return "", "", terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+	var ioErrs, sqlErrs []string
+	for _, row := range rowsResult {
+		if row[0] != "" {
+			ioErrs = append(ioErrs, row[0])
+		}
+		if row[1] != "" {
+			sqlErrs = append(sqlErrs, row[1])
+		}
+	}
+	return strings.Join(ioErrs, "; "), strings.Join(sqlErrs, "; "), nil
+}
+
 // GetSessionVariable gets connection's session variable.
 func GetSessionVariable(ctx *tcontext.Context, conn *BaseConn, variable string) (value string, err error) {
 	failpoint.Inject("GetSessionVariableFailed", func(val failpoint.Value) {
@@ -172,6 +237,59 @@ func GetSessionVariable(ctx *tcontext.Context, conn *BaseConn, variable string)
 	return getVariable(ctx, conn, variable, false)
 }
 
+// GetSessionVariables gets connection's session variables by one query. The
+// keys of the result are lower case, and variables unknown to the server are
+// missing in it.
+func GetSessionVariables(ctx *tcontext.Context, conn *BaseConn, variables ...string) (map[string]string, error) {
+	if len(variables) == 0 {
+		return map[string]string{}, nil
+	}
+	args := make([]interface{}, 0, len(variables))
+	for _, v := range variables {
+		args = append(args, v)
+	}
+	query := "SHOW VARIABLES WHERE Variable_name IN (" + strings.TrimSuffix(strings.Repeat("?,", len(variables)), ",") + ")"
+	rows, err := conn.QuerySQL(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+		_ = rows.Err()
+	}()
+
+	values := make(map[string]string, len(variables))
+	var name, value string
+	for rows.Next() {
+		if err = rows.Scan(&name, &value); err != nil {
+			return nil, terror.DBErrorAdapt(err, conn.Scope, terror.ErrDBDriverError)
+		}
+		values[strings.ToLower(name)] = value
+	}
+	if err = rows.Err(); err != nil {
+		return nil, terror.DBErrorAdapt(err, conn.Scope, terror.ErrDBDriverError)
+	}
+	return values, nil
+}
+
+// GetCollationConnection gets session variable `collation_connection` for BaseConn,
+// which is used to compare strings during applying DMLs. The value is cached
+// in BaseConn, because DM never changes it after the connection is created.
+func GetCollationConnection(ctx *tcontext.Context, conn *BaseConn) (string, error) {
+	if conn == nil || conn.DBConn == nil {
+		return "", terror.ErrDBUnExpect.Generate("database connection not valid")
+	}
+	if conn.collationConnection != "" {
+		return conn.collationConnection, nil
+	}
+	collation, err := GetSessionVariable(ctx, conn, "collation_connection")
+	if err != nil {
+		return "", err
+	}
+	conn.collationConnection = collation
+	return collation, nil
+}
+
 // GetServerID gets server's `server_id`.
 func GetServerID(ctx *tcontext.Context, db *BaseDB) (uint32, error) {
 	serverIDStr, err := GetGlobalVariable(ctx, db, "server_id")
@@ -226,7 +344,51 @@ func GetMariaDBUUID(ctx *tcontext.Context, db *BaseDB) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("%d%s%d", domainID, domainServerIDSeparator, serverID), nil
+	return mariaDBUUID(domainID, serverID), nil
+}
+
+func mariaDBUUID(domainID, serverID uint32) string {
+	return fmt.Sprintf("%d%s%d", domainID, domainServerIDSeparator, serverID)
+}
+
+// GetMariaDBServerIDBits gets MariaDB server's `server_id_bits`.
+func GetMariaDBServerIDBits(ctx *tcontext.Context, db *BaseDB) (uint32, error) {
+	bitsStr, err := GetGlobalVariable(ctx, db, "server_id_bits")
+	if err != nil {
+		return 0, err
+	}
+
+	bits, err := strconv.ParseUint(bitsStr, 10, 32)
+	if err != nil {
+		return 0, terror.ErrDBUnExpect.Delegate(err, fmt.Sprintf("invalid `server_id_bits` value '%s'", bitsStr))
+	}
+	return uint32(bits), nil
+}
+
+// MariaDBGTIDConfig is the GTID related configuration of a MariaDB server.
+type MariaDBGTIDConfig struct {
+	DomainID uint32
+	ServerID uint32
+	// UUID is the equivalent `server_uuid`, see GetMariaDBUUID.
+	UUID string
+}
+
+// GetMariaDBGTIDConfig gets MariaDB server's `gtid_domain_id`, `server_id`
+// and the equivalent `server_uuid` computed from them.
+func GetMariaDBGTIDConfig(ctx *tcontext.Context, db *BaseDB) (MariaDBGTIDConfig, error) {
+	domainID, err := GetMariaDBGtidDomainID(ctx, db)
+	if err != nil {
+		return MariaDBGTIDConfig{}, err
+	}
+	serverID, err := GetServerID(ctx, db)
+	if err != nil {
+		return MariaDBGTIDConfig{}, err
+	}
+	return MariaDBGTIDConfig{
+		DomainID: domainID,
+		ServerID: serverID,
+		UUID:     mariaDBUUID(domainID, serverID),
+	}, nil
 }
 
 // GetParser gets a parser for sql.DB which is suitable for session variable sql_mode.
@@ -260,12 +422,94 @@ func GetParserFromSQLModeStr(sqlMode string) (*parser.Parser, error) {
 	return parser2, nil
 }
 
-// KillConn kills the DB connection (thread in mysqld).
-func KillConn(ctx *tcontext.Context, db *BaseDB, connID uint32) error {
-	_, err := db.ExecContext(ctx, fmt.Sprintf("KILL %d", connID))
+// KillConn kills the DB connection (thread in mysqld). protectedID is usually the
+// connection ID of the caller's own session got by GetConnectionID, it's refused
+// to be killed because it breaks the session, unless force is true. Zero protectedID
+// protects nothing. `KILL TIDB` is used if the server is TiDB.
+func KillConn(ctx *tcontext.Context, db *BaseDB, connID, protectedID uint32, force bool) error {
+	isTiDB, err := isTiDBServer(ctx, db)
+	if err != nil {
+		return err
+	}
+	return killConn(ctx, db, connID, protectedID, force, isTiDB)
+}
+
+func killConn(ctx *tcontext.Context, db *BaseDB, connID, protectedID uint32, force, isTiDB bool) error {
+	if !force && protectedID != 0 && connID == protectedID {
+		return terror.ErrDBUnExpect.Generate(fmt.Sprintf("refuse to kill the protected connection %d", connID))
+	}
+	killSQL := fmt.Sprintf("KILL %d", connID)
+	if isTiDB {
+		killSQL = fmt.Sprintf("KILL TIDB %d", connID)
+	}
+	_, err := db.ExecContext(ctx, killSQL)
 	return terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
 }
 
+// KillConns kills a batch of DB connections (threads in mysqld), and returns the
+// connection IDs which are killed successfully. Connections which are already gone
+// are skipped. It tries to kill all connections even if some of them fail, and
+// returns the first met error. protectedID is refused to be killed like KillConn.
+func KillConns(
+	ctx *tcontext.Context, db *BaseDB, connIDs []uint32, protectedID uint32,
+) (killed []uint32, err error) {
+	killed = make([]uint32, 0, len(connIDs))
+	if len(connIDs) == 0 {
+		return killed, nil
+	}
+	isTiDB, err := isTiDBServer(ctx, db)
+	if err != nil {
+		return killed, err
+	}
+	for _, connID := range connIDs {
+		err2 := killConn(ctx, db, connID, protectedID, false, isTiDB)
+		if err2 == nil {
+			killed = append(killed, connID)
+			continue
+		}
+		if IsNoSuchThreadError(err2) {
+			ctx.L().Info("connection is already gone", zap.Uint32("connection ID", connID))
+			continue
+		}
+		ctx.L().Warn("fail to kill connection", zap.Uint32("connection ID", connID), zap.Error(err2))
+		if err == nil {
+			err = err2
+		}
+	}
+	return killed, err
+}
+
+// isTiDBServer checks whether the server is TiDB. It's detected only once for
+// each BaseDB, unless the detection fails.
+func isTiDBServer(ctx *tcontext.Context, db *BaseDB) (bool, error) {
+	db.flavorMu.Lock()
+	defer db.flavorMu.Unlock()
+	if db.isTiDB != nil {
+		return *db.isTiDB, nil
+	}
+	version, err := GetGlobalVariable(ctx, db, "version")
+	if err != nil {
+		return false, err
+	}
+	isTiDB := IsTiDB(version)
+	db.isTiDB = &isTiDB
+	return isTiDB, nil
+}
+
+// GetConnectionID gets the connection (thread in mysqld) ID of BaseConn, which can be used by KillConn.
+func GetConnectionID(ctx *tcontext.Context, conn *BaseConn) (uint32, error) {
+	if conn == nil || conn.DBConn == nil {
+		return 0, terror.ErrDBUnExpect.Generate("database connection not valid")
+	}
+	var connID uint32
+	row := conn.DBConn.QueryRowContext(ctx.Context(), "SELECT CONNECTION_ID()")
+	err := row.Scan(&connID)
+	if err != nil {
+		return 0, terror.DBErrorAdapt(err, conn.Scope, terror.ErrDBDriverError)
+	}
+	return connID, nil
+}
+
 // IsMySQLError checks whether err is MySQLError error.
 func IsMySQLError(err error, code uint16) bool {
 	err = errors.Cause(err)
@@ -312,6 +556,92 @@ func ExtractTiDBVersion(version string) (*semver.Version, error) {
 	return semver.NewVersion(rawVersion)
 }
 
+var (
+	tidbInstantDDLVersion    = semver.New("3.0.0")
+	mysqlInstantDDLVersion   = semver.New("8.0.12")
+	mariaDBInstantDDLVersion = semver.New("10.3.2")
+
+	serverVersionRegexp = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)`)
+)
+
+// SupportsInstantDDL tells whether the server of the version supports `ALGORITHM=INSTANT`
+// DDLs, which are TiDB v3.0.0+, MySQL 8.0.12+ and MariaDB 10.3.2+.
+// It returns false if the version can't be parsed.
+func SupportsInstantDDL(version string) bool {
+	minVersion := mysqlInstantDDLVersion
+	if IsTiDB(version) {
+		minVersion = tidbInstantDDLVersion
+	} else if IsMariaDB(version) {
+		minVersion = mariaDBInstantDDLVersion
+	}
+	serverVersion, err := parseServerVersion(version)
+	return err == nil && !serverVersion.LessThan(*minVersion)
+}
+
+// parseServerVersion parses the semantic version from the output of `SELECT VERSION()`.
+func parseServerVersion(version string) (*semver.Version, error) {
+	if IsTiDB(version) {
+		return ExtractTiDBVersion(version)
+	}
+	if IsMariaDB(version) {
+		// MariaDB may add a fake prefix for the replication protocol, like "5.5.5-10.3.2-MariaDB".
+		version = strings.TrimPrefix(version, "5.5.5-")
+	}
+	versionStr := serverVersionRegexp.FindString(version)
+	if versionStr == "" {
+		return nil, errors.Errorf("not a valid server version: %s", version)
+	}
+	return semver.NewVersion(versionStr)
+}
+
+// CompareVersions gets the versions of two servers and compares them. cmp is
+// -1, 0 or 1 if aVer is less than, equal to or greater than bVer. The semantic
+// versions are compared if both can be parsed, otherwise the raw versions are
+// compared as strings.
+func CompareVersions(ctx context.Context, a, b *BaseDB) (aVer, bVer string, cmp int, err error) {
+	aVer, err = dbutil.ShowVersion(ctx, a.DB)
+	if err != nil {
+		return "", "", 0, terror.DBErrorAdapt(err, a.Scope, terror.ErrDBDriverError)
+	}
+	bVer, err = dbutil.ShowVersion(ctx, b.DB)
+	if err != nil {
+		return "", "", 0, terror.DBErrorAdapt(err, b.Scope, terror.ErrDBDriverError)
+	}
+	aSemver, aErr := parseServerVersion(aVer)
+	bSemver, bErr := parseServerVersion(bVer)
+	if aErr != nil || bErr != nil {
+		return aVer, bVer, strings.Compare(aVer, bVer), nil
+	}
+	return aVer, bVer, aSemver.Compare(*bSemver), nil
+}
+
+// RequireMinVersion checks the version of the server isn't lower than minTiDB
+// for TiDB, or minMySQL for other flavors. A nil minimum version means the
+// flavor has no requirement. It returns ErrDBVersionTooLow if the version is
+// lower than the minimum version.
+func RequireMinVersion(ctx context.Context, db *BaseDB, minMySQL, minTiDB *semver.Version) error {
+	version, err := dbutil.ShowVersion(ctx, db.DB)
+	if err != nil {
+		return terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+	flavor, minVersion := "MySQL", minMySQL
+	if IsTiDB(version) {
+		flavor, minVersion = "TiDB", minTiDB
+	}
+	if minVersion == nil {
+		return nil
+	}
+	// parseServerVersion extracts the version of TiDB by ExtractTiDBVersion.
+	serverVersion, err := parseServerVersion(version)
+	if err != nil {
+		return terror.ErrDBUnExpect.Delegate(err, fmt.Sprintf("invalid server version '%s'", version))
+	}
+	if serverVersion.LessThan(*minVersion) {
+		return terror.ErrDBVersionTooLow.Generate(version, flavor, minVersion)
+	}
+	return nil
+}
+
 // AddGSetWithPurged is used to handle this case: https://github.com/pingcap/dm/issues/1418
 // we might get a gtid set from Previous_gtids event in binlog, but that gtid set can't be used to start a gtid sync
 // because it doesn't cover all gtid_purged. The error of using it will be
@@ -322,24 +652,11 @@ func AddGSetWithPurged(ctx context.Context, gset gmysql.GTIDSet, conn *BaseConn)
 		return gset, nil
 	}
 
-	var (
-		gtidStr string
-		row     *sql.Row
-		err     error
-	)
-
-	failpoint.Inject("GetGTIDPurged", func(val failpoint.Value) {
-		str := val.(string)
-		gtidStr = str
-		failpoint.Goto("bypass")
-	})
-	row = conn.DBConn.QueryRowContext(ctx, "select @@GLOBAL.gtid_purged")
-	err = row.Scan(&gtidStr)
+	gtidStr, err := GetGTIDPurgedNormalized(ctx, conn)
 	if err != nil {
-		log.L().Error("can't get @@GLOBAL.gtid_purged when try to add it to gtid set", zap.Error(err))
-		return gset, terror.DBErrorAdapt(err, conn.Scope, terror.ErrDBDriverError)
+		log.L().Error("can't get valid @@GLOBAL.gtid_purged when try to add it to gtid set", zap.Error(err))
+		return nil, err
 	}
-	failpoint.Label("bypass")
 	if gtidStr == "" {
 		return gset, nil
 	}
@@ -352,6 +669,83 @@ func AddGSetWithPurged(ctx context.Context, gset gmysql.GTIDSet, conn *BaseConn)
 	return cloned, nil
 }
 
+// GetGTIDPurged gets upstream's `gtid_purged`.
+func GetGTIDPurged(ctx context.Context, db *BaseDB) (string, error) {
+	c, err := db.GetBaseConn(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer db.CloseConnWithoutErr(c)
+	return GetGTIDPurgedForConn(ctx, c)
+}
+
+// GetGTIDExecuted gets upstream's `gtid_executed`. Some servers deny reading
+// `@@GLOBAL.gtid_executed` but expose performance_schema.global_variables, so
+// it's read from performance_schema if the `@@` read is denied.
+func GetGTIDExecuted(ctx context.Context, db *BaseDB) (string, error) {
+	var gtidStr string
+	row := db.DB.QueryRowContext(ctx, "select @@GLOBAL.gtid_executed")
+	err := row.Scan(&gtidStr)
+	if err == nil {
+		return gtidStr, nil
+	}
+	if !IsMySQLError(err, tmysql.ErrSpecificAccessDenied) && !IsMySQLError(err, tmysql.ErrAccessDenied) {
+		return "", terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+
+	log.L().Warn("read @@GLOBAL.gtid_executed is denied, fall back to performance_schema", zap.Error(err))
+	row = db.DB.QueryRowContext(ctx,
+		"SELECT VARIABLE_VALUE FROM performance_schema.global_variables WHERE VARIABLE_NAME = 'gtid_executed'")
+	if err = row.Scan(&gtidStr); err != nil {
+		return "", terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+	return gtidStr, nil
+}
+
+// GetGTIDPurgedForConn gets upstream's `gtid_purged` for BaseConn.
+func GetGTIDPurgedForConn(ctx context.Context, conn *BaseConn) (string, error) {
+	failpoint.Inject("GetGTIDPurged", func(val failpoint.Value) {
+		failpoint.Return(val.(string), nil)
+	})
+	var gtidStr string
+	row := conn.DBConn.QueryRowContext(ctx, "select @@GLOBAL.gtid_purged")
+	err := row.Scan(&gtidStr)
+	if err != nil {
+		return "", terror.DBErrorAdapt(err, conn.Scope, terror.ErrDBDriverError)
+	}
+	return gtidStr, nil
+}
+
+// GetGTIDPurgedNormalized gets upstream's `gtid_purged` for BaseConn, and normalizes
+// it to be used by `GTIDSet.Update`. The output differs across MySQL versions, for example
+// MySQL 5.6 separates UUID sets by ",\n" and some versions may leave a trailing separator.
+func GetGTIDPurgedNormalized(ctx context.Context, conn *BaseConn) (string, error) {
+	gtidStr, err := GetGTIDPurgedForConn(ctx, conn)
+	if err != nil {
+		return "", err
+	}
+	return normalizeGTIDPurged(gtidStr)
+}
+
+func normalizeGTIDPurged(gtidStr string) (string, error) {
+	parts := strings.Split(gtidStr, ",")
+	uuidSets := make([]string, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			uuidSets = append(uuidSets, part)
+		}
+	}
+	if len(uuidSets) == 0 {
+		return "", nil
+	}
+	normalized := strings.Join(uuidSets, ",")
+	if _, err := gmysql.ParseMysqlGTIDSet(normalized); err != nil {
+		return "", err
+	}
+	return normalized, nil
+}
+
 // AdjustSQLModeCompatible adjust downstream sql mode to compatible.
 // TODO: When upstream's datatime is 2020-00-00, 2020-00-01, 2020-06-00
 // and so on, downstream will be 2019-11-30, 2019-12-01, 2020-05-31,
@@ -425,120 +819,1292 @@ func GetMaxConnectionsForConn(ctx *tcontext.Context, conn *BaseConn) (int, error
 	return int(maxConnections), err
 }
 
-// IsMariaDB tells whether the version is mariadb.
-func IsMariaDB(version string) bool {
-	return strings.Contains(strings.ToUpper(version), "MARIADB")
+// GetMaxBinlogSize gets server's `max_binlog_size`.
+func GetMaxBinlogSize(ctx *tcontext.Context, db *BaseDB) (uint64, error) {
+	sizeStr, err := GetGlobalVariable(ctx, db, "max_binlog_size")
+	if err != nil {
+		return 0, err
+	}
+	size, err := strconv.ParseUint(sizeStr, 10, 64)
+	if err != nil {
+		return 0, terror.ErrDBUnExpect.Delegate(err, fmt.Sprintf("invalid `max_binlog_size` value '%s'", sizeStr))
+	}
+	return size, nil
 }
 
-// CreateTableSQLToOneRow formats the result of SHOW CREATE TABLE to one row.
-func CreateTableSQLToOneRow(sql string) string {
-	sql = strings.ReplaceAll(sql, "\n", "")
-	sql = strings.ReplaceAll(sql, "  ", " ")
-	return sql
+// GetLogErrorVerbosity gets global variable `log_error_verbosity`, which controls
+// which messages are written to the MySQL error log.
+func GetLogErrorVerbosity(ctx *tcontext.Context, db *BaseDB) (int, error) {
+	verbosityStr, err := GetGlobalVariable(ctx, db, "log_error_verbosity")
+	if err != nil {
+		return 0, err
+	}
+	verbosity, err := strconv.Atoi(verbosityStr)
+	if err != nil {
+		return 0, terror.ErrDBUnExpect.Delegate(err, fmt.Sprintf("invalid `log_error_verbosity` value '%s'", verbosityStr))
+	}
+	return verbosity, nil
 }
 
-// FetchAllDoTables returns all need to do tables after filtered (fetches from upstream MySQL).
-func FetchAllDoTables(ctx context.Context, db *BaseDB, bw *filter.Filter) (map[string][]string, error) {
-	schemas, err := dbutil.GetSchemas(ctx, db.DB)
-
-	failpoint.Inject("FetchAllDoTablesFailed", func(val failpoint.Value) {
-		err = tmysql.NewErr(uint16(val.(int)))
-		log.L().Warn("FetchAllDoTables failed", zap.String("failpoint", "FetchAllDoTablesFailed"), zap.Error(err))
-	})
+// DescribeLogErrorVerbosity returns which kinds of messages are written to the
+// MySQL error log under the given `log_error_verbosity`.
+func DescribeLogErrorVerbosity(verbosity int) string {
+	switch verbosity {
+	case 1:
+		return "errors only"
+	case 2:
+		return "errors and warnings"
+	case 3:
+		return "errors, warnings and notes"
+	default:
+		return "unknown"
+	}
+}
 
+// GetSlowQueryLogStatus gets global variables `slow_query_log` and `long_query_time`,
+// which helps to diagnose slow writes to the downstream. longQueryTime is in seconds.
+func GetSlowQueryLogStatus(ctx *tcontext.Context, db *BaseDB) (enabled bool, longQueryTime float64, err error) {
+	value, err := GetGlobalVariable(ctx, db, "slow_query_log")
+	if err != nil {
+		return false, 0, err
+	}
+	enabled, err = parseBoolVariable("slow_query_log", value)
 	if err != nil {
-		return nil, terror.WithScope(err, db.Scope)
+		return false, 0, err
 	}
 
-	ftSchemas := make([]*filter.Table, 0, len(schemas))
-	for _, schema := range schemas {
-		if filter.IsSystemSchema(schema) {
-			continue
-		}
-		ftSchemas = append(ftSchemas, &filter.Table{
-			Schema: schema,
-			Name:   "", // schema level
-		})
+	value, err = GetGlobalVariable(ctx, db, "long_query_time")
+	if err != nil {
+		return false, 0, err
 	}
-	ftSchemas = bw.Apply(ftSchemas)
-	if len(ftSchemas) == 0 {
-		log.L().Warn("no schema need to sync")
-		return nil, nil
+	longQueryTime, err = strconv.ParseFloat(value, 64)
+	if err != nil {
+		return false, 0, terror.ErrDBUnExpect.Delegate(err, fmt.Sprintf("invalid `long_query_time` value '%s'", value))
 	}
+	return enabled, longQueryTime, nil
+}
 
-	schemaToTables := make(map[string][]string)
-	for _, ftSchema := range ftSchemas {
-		schema := ftSchema.Schema
-		// use `GetTables` from tidb-tools, no view included
-		tables, err := dbutil.GetTables(ctx, db.DB, schema)
-		if err != nil {
-			return nil, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
-		}
-		ftTables := make([]*filter.Table, 0, len(tables))
-		for _, table := range tables {
-			ftTables = append(ftTables, &filter.Table{
-				Schema: schema,
-				Name:   table,
-			})
-		}
-		ftTables = bw.Apply(ftTables)
-		if len(ftTables) == 0 {
-			log.L().Info("no tables need to sync", zap.String("schema", schema))
-			continue // NOTE: should we still keep it as an empty elem?
-		}
-		tables = tables[:0]
-		for _, ftTable := range ftTables {
-			tables = append(tables, ftTable.Name)
-		}
-		schemaToTables[schema] = tables
+// GetInnoDBFlushLogAtTrxCommit gets global variable `innodb_flush_log_at_trx_commit`.
+func GetInnoDBFlushLogAtTrxCommit(ctx *tcontext.Context, db *BaseDB) (int, error) {
+	valueStr, err := GetGlobalVariable(ctx, db, "innodb_flush_log_at_trx_commit")
+	if err != nil {
+		return 0, err
 	}
-
-	return schemaToTables, nil
+	value, err := strconv.Atoi(valueStr)
+	if err != nil {
+		return 0, terror.ErrDBUnExpect.Delegate(err, fmt.Sprintf("invalid `innodb_flush_log_at_trx_commit` value '%s'", valueStr))
+	}
+	return value, nil
 }
 
-// FetchTargetDoTables returns all need to do tables after filtered and routed (fetches from upstream MySQL).
-func FetchTargetDoTables(
-	ctx context.Context,
-	source string,
-	db *BaseDB,
-	bw *filter.Filter,
-	router *regexprrouter.RouteTable,
-) (map[filter.Table][]filter.Table, map[filter.Table][]string, error) {
-	// fetch tables from source and filter them
-	sourceTables, err := FetchAllDoTables(ctx, db, bw)
-
-	failpoint.Inject("FetchTargetDoTablesFailed", func(val failpoint.Value) {
-		err = tmysql.NewErr(uint16(val.(int)))
-		log.L().Warn("FetchTargetDoTables failed", zap.String("failpoint", "FetchTargetDoTablesFailed"), zap.Error(err))
-	})
+// IsRelaxedDurability returns whether the `innodb_flush_log_at_trx_commit` value
+// may lose committed transactions when the server crashes. Only 1 flushes the
+// redo log to disk at each commit.
+func IsRelaxedDurability(flushLogAtTrxCommit int) bool {
+	return flushLogAtTrxCommit == 0 || flushLogAtTrxCommit == 2
+}
 
+// GetGroupReplicationStatus gets the role, like PRIMARY or SECONDARY, and the
+// state, like ONLINE or RECOVERING, of the server in its Group Replication
+// group. Both are empty if the server isn't a member of any group.
+func GetGroupReplicationStatus(ctx *tcontext.Context, db *BaseDB) (role string, state string, err error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT MEMBER_ROLE, MEMBER_STATE FROM performance_schema.replication_group_members WHERE MEMBER_ID = @@server_uuid")
 	if err != nil {
-		return nil, nil, err
+		// MariaDB or some old MySQL versions don't support Group Replication.
+		if IsMySQLError(err, tmysql.ErrNoSuchTable) {
+			return "", "", nil
+		}
+		return "", "", terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
 	}
+	defer rows.Close()
 
-	tableMapper := make(map[filter.Table][]filter.Table)
-	extendedColumnPerTable := make(map[filter.Table][]string)
+	if rows.Next() {
+		var nullRole, nullState sql.NullString
+		if err = rows.Scan(&nullRole, &nullState); err != nil {
+			return "", "", terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+		}
+		role, state = nullRole.String, nullState.String
+	}
+	if err = rows.Err(); err != nil {
+		return "", "", terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+	return role, state, nil
+}
+
+// GetSlaveNetTimeout gets global variable `slave_net_timeout` in seconds, which
+// is how long a replica waits for data from its source before reconnecting.
+func GetSlaveNetTimeout(ctx *tcontext.Context, db *BaseDB) (int, error) {
+	timeoutStr, err := GetGlobalVariable(ctx, db, "slave_net_timeout")
+	if err != nil {
+		return 0, err
+	}
+	timeout, err := strconv.Atoi(timeoutStr)
+	if err != nil {
+		return 0, terror.ErrDBUnExpect.Delegate(err, fmt.Sprintf("invalid `slave_net_timeout` value '%s'", timeoutStr))
+	}
+	return timeout, nil
+}
+
+// GetMaxPreparedStmtCount gets global variable `max_prepared_stmt_count`, which
+// limits the total number of prepared statements of all sessions in the server.
+func GetMaxPreparedStmtCount(ctx *tcontext.Context, db *BaseDB) (int, error) {
+	countStr, err := GetGlobalVariable(ctx, db, "max_prepared_stmt_count")
+	if err != nil {
+		return 0, err
+	}
+	count, err := strconv.Atoi(countStr)
+	if err != nil {
+		return 0, terror.ErrDBUnExpect.Delegate(err, fmt.Sprintf("invalid `max_prepared_stmt_count` value '%s'", countStr))
+	}
+	return count, nil
+}
+
+// GetExplicitDefaultsForTimestamp gets global variable `explicit_defaults_for_timestamp`,
+// which changes the default value and nullability of TIMESTAMP columns.
+func GetExplicitDefaultsForTimestamp(ctx *tcontext.Context, db *BaseDB) (bool, error) {
+	value, err := GetGlobalVariable(ctx, db, "explicit_defaults_for_timestamp")
+	if err != nil {
+		return false, err
+	}
+	return parseBoolVariable("explicit_defaults_for_timestamp", value)
+}
+
+// GetTimeZone gets global variable `time_zone`. If it's SYSTEM, the global
+// variable `system_time_zone` is returned instead.
+func GetTimeZone(ctx *tcontext.Context, db *BaseDB) (string, error) {
+	tz, err := GetGlobalVariable(ctx, db, "time_zone")
+	if err != nil {
+		return "", err
+	}
+	if !strings.EqualFold(tz, "SYSTEM") {
+		return tz, nil
+	}
+	return GetGlobalVariable(ctx, db, "system_time_zone")
+}
+
+// CompareTimeZones checks whether the time zones of upstream and downstream
+// are the same, TIMESTAMP values will be shifted if they are different.
+func CompareTimeZones(ctx *tcontext.Context, upstream, downstream *BaseDB) (match bool, up, down string, err error) {
+	up, err = GetTimeZone(ctx, upstream)
+	if err != nil {
+		return false, "", "", err
+	}
+	down, err = GetTimeZone(ctx, downstream)
+	if err != nil {
+		return false, "", "", err
+	}
+	return strings.EqualFold(up, down), up, down, nil
+}
+
+// MinWaitTimeout is the minimum session `wait_timeout` and `interactive_timeout`
+// in seconds DM expects, shorter values may kill idle connections during sync.
+const MinWaitTimeout = 600
+
+// GetWaitTimeouts gets session variables `wait_timeout` and `interactive_timeout`
+// of the connection in seconds.
+func GetWaitTimeouts(ctx *tcontext.Context, conn *BaseConn) (wait, interactive int, err error) {
+	wait, err = getSessionTimeout(ctx, conn, "wait_timeout")
+	if err != nil {
+		return 0, 0, err
+	}
+	interactive, err = getSessionTimeout(ctx, conn, "interactive_timeout")
+	if err != nil {
+		return 0, 0, err
+	}
+	return wait, interactive, nil
+}
+
+func getSessionTimeout(ctx *tcontext.Context, conn *BaseConn, variable string) (int, error) {
+	timeoutStr, err := GetSessionVariable(ctx, conn, variable)
+	if err != nil {
+		return 0, err
+	}
+	timeout, err := strconv.Atoi(timeoutStr)
+	if err != nil {
+		return 0, terror.ErrDBUnExpect.Delegate(err, fmt.Sprintf("invalid `%s` value '%s'", variable, timeoutStr))
+	}
+	return timeout, nil
+}
+
+// AdjustWaitTimeouts warns and raises session `wait_timeout` and `interactive_timeout`
+// of the connection to minTimeout if they are shorter than it.
+func AdjustWaitTimeouts(ctx *tcontext.Context, conn *BaseConn, minTimeout int) error {
+	wait, interactive, err := GetWaitTimeouts(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return raiseSessionTimeouts(ctx, conn, minTimeout,
+		sessionTimeout{"wait_timeout", wait},
+		sessionTimeout{"interactive_timeout", interactive})
+}
+
+// MinNetTimeout is the minimum session `net_read_timeout` and `net_write_timeout`
+// in seconds DM expects for streaming connections, such as the ones reading or
+// writing a large result set.
+const MinNetTimeout = 600
+
+// GetNetTimeouts gets session variables `net_read_timeout` and `net_write_timeout`
+// of the connection in seconds.
+func GetNetTimeouts(ctx *tcontext.Context, conn *BaseConn) (read, write int, err error) {
+	read, err = getSessionTimeout(ctx, conn, "net_read_timeout")
+	if err != nil {
+		return 0, 0, err
+	}
+	write, err = getSessionTimeout(ctx, conn, "net_write_timeout")
+	if err != nil {
+		return 0, 0, err
+	}
+	return read, write, nil
+}
+
+// AdjustNetTimeouts warns and raises session `net_read_timeout` and `net_write_timeout`
+// of the connection to minTimeout if they are shorter than it. It's used to set up
+// streaming connections.
+func AdjustNetTimeouts(ctx *tcontext.Context, conn *BaseConn, minTimeout int) error {
+	read, write, err := GetNetTimeouts(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return raiseSessionTimeouts(ctx, conn, minTimeout,
+		sessionTimeout{"net_read_timeout", read},
+		sessionTimeout{"net_write_timeout", write})
+}
+
+type sessionTimeout struct {
+	variable string
+	value    int
+}
+
+// raiseSessionTimeouts sets the session timeouts which are shorter than minTimeout to minTimeout.
+func raiseSessionTimeouts(ctx *tcontext.Context, conn *BaseConn, minTimeout int, timeouts ...sessionTimeout) error {
+	queries := make([]string, 0, len(timeouts))
+	fields := make([]zap.Field, 0, len(timeouts)+1)
+	for _, t := range timeouts {
+		if t.value < minTimeout {
+			queries = append(queries, fmt.Sprintf("SET SESSION %s = %d", t.variable, minTimeout))
+		}
+		fields = append(fields, zap.Int(t.variable, t.value))
+	}
+	if len(queries) == 0 {
+		return nil
+	}
+	fields = append(fields, zap.Int("min timeout", minTimeout))
+	ctx.L().Warn("session timeouts are too short, adjust them", fields...)
+	_, err := conn.ExecuteSQL(ctx, nil, "", queries)
+	return err
+}
+
+// WarmUpConn prepares a long-lived connection by reading all session variables
+// it needs in one query. It raises `wait_timeout` and `interactive_timeout` to
+// MinWaitTimeout, and `net_read_timeout` and `net_write_timeout` to MinNetTimeout
+// if the connection streams large result sets, then caches `collation_connection`.
+// It returns `innodb_lock_wait_timeout` in seconds, which is zero if unknown.
+func WarmUpConn(ctx *tcontext.Context, conn *BaseConn, streaming bool) (lockWaitTimeout int, err error) {
+	if conn == nil || conn.DBConn == nil {
+		return 0, terror.ErrDBUnExpect.Generate("database connection not valid")
+	}
+	waitTimeouts := []string{"wait_timeout", "interactive_timeout"}
+	var netTimeouts []string
+	if streaming {
+		netTimeouts = []string{"net_read_timeout", "net_write_timeout"}
+	}
+	variables := append([]string{"collation_connection", "innodb_lock_wait_timeout"}, waitTimeouts...)
+	variables = append(variables, netTimeouts...)
+	values, err := GetSessionVariables(ctx, conn, variables...)
+	if err != nil {
+		return 0, err
+	}
+	parseTimeouts := func(variables []string) ([]sessionTimeout, error) {
+		timeouts := make([]sessionTimeout, 0, len(variables))
+		for _, variable := range variables {
+			valueStr, ok := values[variable]
+			if !ok {
+				continue
+			}
+			value, err := strconv.Atoi(valueStr)
+			if err != nil {
+				return nil, terror.ErrDBUnExpect.Delegate(err, fmt.Sprintf("invalid `%s` value '%s'", variable, valueStr))
+			}
+			timeouts = append(timeouts, sessionTimeout{variable, value})
+		}
+		return timeouts, nil
+	}
+
+	if collation := values["collation_connection"]; collation != "" {
+		conn.collationConnection = collation
+	}
+	waits, err := parseTimeouts(waitTimeouts)
+	if err != nil {
+		return 0, err
+	}
+	nets, err := parseTimeouts(netTimeouts)
+	if err != nil {
+		return 0, err
+	}
+	if err = raiseSessionTimeouts(ctx, conn, MinWaitTimeout, waits...); err != nil {
+		return 0, err
+	}
+	if err = raiseSessionTimeouts(ctx, conn, MinNetTimeout, nets...); err != nil {
+		return 0, err
+	}
+	lockWait, err := parseTimeouts([]string{"innodb_lock_wait_timeout"})
+	if err != nil || len(lockWait) == 0 {
+		return 0, err
+	}
+	return lockWait[0].value, nil
+}
+
+// GetInnoDBLockWaitTimeout gets session variable `innodb_lock_wait_timeout` of
+// the connection in seconds, which is how long a statement waits for a row lock
+// before it fails.
+func GetInnoDBLockWaitTimeout(ctx *tcontext.Context, conn *BaseConn) (int, error) {
+	return getSessionTimeout(ctx, conn, "innodb_lock_wait_timeout")
+}
+
+// GetMaxExecutionTime gets session variable `max_execution_time` of the connection
+// in milliseconds, the server kills SELECT statements running longer than it.
+// Zero means no limit.
+func GetMaxExecutionTime(ctx *tcontext.Context, conn *BaseConn) (int, error) {
+	return getSessionTimeout(ctx, conn, "max_execution_time")
+}
+
+// ClearMaxExecutionTime warns and sets session `max_execution_time` of the
+// connection to zero if it's not, so long queries are not killed by the server.
+func ClearMaxExecutionTime(ctx *tcontext.Context, conn *BaseConn) error {
+	timeout, err := GetMaxExecutionTime(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if timeout == 0 {
+		return nil
+	}
+	ctx.L().Warn("session max_execution_time may kill long queries, clear it", zap.Int("max_execution_time", timeout))
+	_, err = conn.ExecuteSQL(ctx, nil, "", []string{"SET SESSION max_execution_time = 0"})
+	return err
+}
+
+// GetBinlogOrderCommits gets global variable `binlog_order_commits`. If it's
+// OFF, transactions may be committed in a different order from the binlog.
+func GetBinlogOrderCommits(ctx *tcontext.Context, db *BaseDB) (bool, error) {
+	value, err := GetGlobalVariable(ctx, db, "binlog_order_commits")
+	if err != nil {
+		return false, err
+	}
+	return parseBoolVariable("binlog_order_commits", value)
+}
+
+// GetBinlogRowsQueryLogEvents gets global variable `binlog_rows_query_log_events`.
+// If it's ON, the binlog includes the original statements of row events.
+func GetBinlogRowsQueryLogEvents(ctx *tcontext.Context, db *BaseDB) (bool, error) {
+	value, err := GetGlobalVariable(ctx, db, "binlog_rows_query_log_events")
+	if err != nil {
+		return false, err
+	}
+	return parseBoolVariable("binlog_rows_query_log_events", value)
+}
+
+// GetBinlogTransactionDependencyTracking gets global variable
+// `binlog_transaction_dependency_tracking`, like `COMMIT_ORDER`, `WRITESET` or
+// `WRITESET_SESSION`.
+func GetBinlogTransactionDependencyTracking(ctx *tcontext.Context, db *BaseDB) (string, error) {
+	return GetGlobalVariable(ctx, db, "binlog_transaction_dependency_tracking")
+}
+
+// GetBinlogTransactionCompression gets global variable `binlog_transaction_compression`,
+// ON means transactions are written to binlog as compressed events, which is
+// supported since MySQL 8.0.20.
+func GetBinlogTransactionCompression(ctx *tcontext.Context, db *BaseDB) (string, error) {
+	return GetGlobalVariable(ctx, db, "binlog_transaction_compression")
+}
+
+// GetTiDBClusteredIndex gets global variable `tidb_enable_clustered_index`.
+// It returns an empty string without error if the server is not TiDB.
+func GetTiDBClusteredIndex(ctx *tcontext.Context, db *BaseDB) (string, error) {
+	version, err := GetGlobalVariable(ctx, db, "version")
+	if err != nil {
+		return "", err
+	}
+	if !IsTiDB(version) {
+		return "", nil
+	}
+	return GetGlobalVariable(ctx, db, "tidb_enable_clustered_index")
+}
+
+// GetTiDBStatsHealthy gets the stats health of the table from TiDB, which is
+// in [0, 100] and a lower value means the stats are more stale. The lowest health
+// of all partitions is returned for a partitioned table. It returns -1 and an
+// error if the server is not TiDB or the table has no stats.
+func GetTiDBStatsHealthy(ctx *tcontext.Context, db *BaseDB, schema, table string) (int, error) {
+	version, err := GetGlobalVariable(ctx, db, "version")
+	if err != nil {
+		return -1, err
+	}
+	if !IsTiDB(version) {
+		return -1, terror.ErrDBUnExpect.Generate(fmt.Sprintf("stats health is not supported by %s", version))
+	}
+
+	// Show an example.
+	/*
+		mysql> SHOW STATS_HEALTHY WHERE Db_name = 'test' AND Table_name = 't';
+		+---------+------------+----------------+---------+
+		| Db_name | Table_name | Partition_name | Healthy |
+		+---------+------------+----------------+---------+
+		| test    | t          |                |     100 |
+		+---------+------------+----------------+---------+
+	*/
+	rows, err := db.QueryContext(ctx, "SHOW STATS_HEALTHY WHERE Db_name = ? AND Table_name = ?", schema, table)
+	if err != nil {
+		return -1, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+	defer rows.Close()
+
+	healthy := -1
+	var dbName, tableName, partitionName string
+	for rows.Next() {
+		var value int
+		if err = rows.Scan(&dbName, &tableName, &partitionName, &value); err != nil {
+			return -1, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+		}
+		if healthy == -1 || value < healthy {
+			healthy = value
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return -1, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+	if healthy == -1 {
+		return -1, terror.ErrDBUnExpect.Generate(fmt.Sprintf("no stats of table %s.%s", schema, table))
+	}
+	return healthy, nil
+}
+
+// GetAutoIncrementIncrement gets session variable `auto_increment_increment` for BaseConn.
+func GetAutoIncrementIncrement(ctx *tcontext.Context, conn *BaseConn) (int, error) {
+	return getAutoIncrementVariable(ctx, conn, "auto_increment_increment")
+}
+
+// GetAutoIncrementOffset gets session variable `auto_increment_offset` for BaseConn.
+func GetAutoIncrementOffset(ctx *tcontext.Context, conn *BaseConn) (int, error) {
+	return getAutoIncrementVariable(ctx, conn, "auto_increment_offset")
+}
+
+func getAutoIncrementVariable(ctx *tcontext.Context, conn *BaseConn, variable string) (int, error) {
+	valueStr, err := GetSessionVariable(ctx, conn, variable)
+	if err != nil {
+		return 0, err
+	}
+	// both variables are in range [1, 65535].
+	value, err := strconv.ParseUint(valueStr, 10, 16)
+	if err != nil {
+		return 0, terror.ErrDBUnExpect.Delegate(err, fmt.Sprintf("invalid `%s` value '%s'", variable, valueStr))
+	}
+	return int(value), nil
+}
+
+// GetCharacterSetClientHandshake gets global variable `character_set_client_handshake`.
+// It is false when the server is started with `--skip-character-set-client-handshake`,
+// which means the character set requested by the client is ignored.
+func GetCharacterSetClientHandshake(ctx *tcontext.Context, db *BaseDB) (bool, error) {
+	value, err := GetGlobalVariable(ctx, db, "character_set_client_handshake")
+	if err != nil {
+		return false, err
+	}
+	return parseBoolVariable("character_set_client_handshake", value)
+}
+
+// IsCharsetHandshakeRisky returns true if the server ignores the character set
+// requested by the client and falls back to a different `character_set_server`,
+// which may silently mangle the replicated data.
+func IsCharsetHandshakeRisky(ctx *tcontext.Context, db *BaseDB, clientCharset string) (bool, error) {
+	handshake, err := GetCharacterSetClientHandshake(ctx, db)
+	if err != nil || handshake {
+		return false, err
+	}
+	serverCharset, err := GetGlobalVariable(ctx, db, "character_set_server")
+	if err != nil {
+		return false, err
+	}
+	return !strings.EqualFold(serverCharset, clientCharset), nil
+}
+
+// parseBoolVariable parses the value of a boolean system variable.
+func parseBoolVariable(variable, value string) (bool, error) {
+	switch strings.ToUpper(value) {
+	case "ON", "1", "TRUE":
+		return true, nil
+	case "OFF", "0", "FALSE":
+		return false, nil
+	default:
+		return false, terror.ErrDBUnExpect.Generate(fmt.Sprintf("invalid `%s` value '%s'", variable, value))
+	}
+}
+
+// SetUniqueChecks sets session variable `unique_checks` for BaseConn.
+func SetUniqueChecks(ctx *tcontext.Context, conn *BaseConn, on bool) error {
+	return setSessionBoolVariable(ctx, conn, "unique_checks", on)
+}
+
+// WithUniqueChecks sets session variable `unique_checks` to on for BaseConn and calls fn,
+// then restores `unique_checks` to its original value after fn returns.
+func WithUniqueChecks(ctx *tcontext.Context, conn *BaseConn, on bool, fn func() error) (err error) {
+	origStr, err := GetSessionVariable(ctx, conn, "unique_checks")
+	if err != nil {
+		return err
+	}
+	orig, err := parseBoolVariable("unique_checks", origStr)
+	if err != nil {
+		return err
+	}
+	return withSessionBoolVariable(ctx, conn, "unique_checks", orig, on, fn)
+}
+
+// GetSQLRequirePrimaryKey gets session variable `sql_require_primary_key` for BaseConn.
+// It returns false if the variable is not supported, such as MySQL before 8.0.13.
+func GetSQLRequirePrimaryKey(ctx *tcontext.Context, conn *BaseConn) (bool, error) {
+	if conn == nil || conn.DBConn == nil {
+		return false, terror.ErrDBUnExpect.Generate("database connection not valid")
+	}
+	query := "SHOW VARIABLES LIKE 'sql_require_primary_key'"
+	rows, err := conn.QuerySQL(ctx, query)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = rows.Close()
+		_ = rows.Err()
+	}()
+	if !rows.Next() {
+		return false, nil
+	}
+	var variable, value string
+	if err = rows.Scan(&variable, &value); err != nil {
+		return false, terror.DBErrorAdapt(err, conn.Scope, terror.ErrDBDriverError)
+	}
+	return parseBoolVariable("sql_require_primary_key", value)
+}
+
+// WithoutSQLRequirePrimaryKey disables session variable `sql_require_primary_key`
+// for BaseConn and calls fn, then restores it after fn returns. It's used to
+// apply DDLs which create tables without primary key.
+func WithoutSQLRequirePrimaryKey(ctx *tcontext.Context, conn *BaseConn, fn func() error) error {
+	orig, err := GetSQLRequirePrimaryKey(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return withSessionBoolVariable(ctx, conn, "sql_require_primary_key", orig, false, fn)
+}
+
+// GetSQLSafeUpdates gets session variable `sql_safe_updates` for BaseConn.
+func GetSQLSafeUpdates(ctx *tcontext.Context, conn *BaseConn) (bool, error) {
+	value, err := GetSessionVariable(ctx, conn, "sql_safe_updates")
+	if err != nil {
+		return false, err
+	}
+	return parseBoolVariable("sql_safe_updates", value)
+}
+
+// WithoutSQLSafeUpdates disables session variable `sql_safe_updates` for BaseConn
+// and calls fn, then restores it after fn returns. It's used to run UPDATE or
+// DELETE statements which don't use a key in the WHERE clause.
+func WithoutSQLSafeUpdates(ctx *tcontext.Context, conn *BaseConn, fn func() error) error {
+	orig, err := GetSQLSafeUpdates(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return withSessionBoolVariable(ctx, conn, "sql_safe_updates", orig, false, fn)
+}
+
+// GetTiDBTxnMode gets session variable `tidb_txn_mode` for BaseConn, which is
+// `optimistic`, `pessimistic` or empty for the default mode of old versions.
+// It returns an empty string without error if the server is not TiDB.
+func GetTiDBTxnMode(ctx *tcontext.Context, conn *BaseConn) (string, error) {
+	mode, _, err := getTiDBTxnMode(ctx, conn)
+	return mode, err
+}
+
+func getTiDBTxnMode(ctx *tcontext.Context, conn *BaseConn) (mode string, isTiDB bool, err error) {
+	version, err := GetSessionVariable(ctx, conn, "version")
+	if err != nil {
+		return "", false, err
+	}
+	if !IsTiDB(version) {
+		return "", false, nil
+	}
+	mode, err = GetSessionVariable(ctx, conn, "tidb_txn_mode")
+	return mode, true, err
+}
+
+// WithTiDBTxnMode sets session variable `tidb_txn_mode` to mode for BaseConn and
+// calls fn, then restores it to its original value after fn returns. fn is called
+// directly if the server is not TiDB.
+func WithTiDBTxnMode(ctx *tcontext.Context, conn *BaseConn, mode string, fn func() error) (err error) {
+	if !strings.EqualFold(mode, "optimistic") && !strings.EqualFold(mode, "pessimistic") {
+		return terror.ErrDBUnExpect.Generate(fmt.Sprintf("invalid `tidb_txn_mode` value '%s'", mode))
+	}
+	orig, isTiDB, err := getTiDBTxnMode(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if !isTiDB || strings.EqualFold(orig, mode) {
+		return fn()
+	}
+
+	if err = setSessionStringVariable(ctx, conn, "tidb_txn_mode", mode); err != nil {
+		return err
+	}
+	defer func() {
+		if err2 := setSessionStringVariable(ctx, conn, "tidb_txn_mode", orig); err2 != nil {
+			ctx.L().Warn("fail to restore session variable", zap.String("variable", "tidb_txn_mode"), zap.String("value", orig), zap.Error(err2))
+			if err == nil {
+				err = err2
+			}
+		}
+	}()
+	return fn()
+}
+
+func setSessionStringVariable(ctx *tcontext.Context, conn *BaseConn, variable, value string) error {
+	if conn == nil || conn.DBConn == nil {
+		return terror.ErrDBUnExpect.Generate("database connection not valid")
+	}
+	query := fmt.Sprintf("SET SESSION %s = ?", variable)
+	_, err := conn.DBConn.ExecContext(ctx.Context(), query, value)
+	if err != nil {
+		return terror.ErrDBExecuteFailed.Delegate(err, query)
+	}
+	return nil
+}
+
+func setSessionBoolVariable(ctx *tcontext.Context, conn *BaseConn, variable string, on bool) error {
+	if conn == nil || conn.DBConn == nil {
+		return terror.ErrDBUnExpect.Generate("database connection not valid")
+	}
+	value := 0
+	if on {
+		value = 1
+	}
+	query := fmt.Sprintf("SET SESSION %s = %d", variable, value)
+	_, err := conn.DBConn.ExecContext(ctx.Context(), query)
+	if err != nil {
+		return terror.ErrDBExecuteFailed.Delegate(err, query)
+	}
+	return nil
+}
+
+// withSessionBoolVariable sets the session variable from orig to on and calls fn,
+// then restores the variable to orig after fn returns.
+func withSessionBoolVariable(
+	ctx *tcontext.Context, conn *BaseConn, variable string, orig, on bool, fn func() error,
+) (err error) {
+	if orig == on {
+		return fn()
+	}
+
+	if err = setSessionBoolVariable(ctx, conn, variable, on); err != nil {
+		return err
+	}
+	defer func() {
+		if err2 := setSessionBoolVariable(ctx, conn, variable, orig); err2 != nil {
+			ctx.L().Warn("fail to restore session variable", zap.String("variable", variable), zap.Bool("value", orig), zap.Error(err2))
+			if err == nil {
+				err = err2
+			}
+		}
+	}()
+	return fn()
+}
+
+// IsMariaDB tells whether the version is mariadb.
+func IsMariaDB(version string) bool {
+	return strings.Contains(strings.ToUpper(version), "MARIADB")
+}
+
+// IsTiDB tells whether the version is tidb.
+func IsTiDB(version string) bool {
+	return strings.Contains(strings.ToUpper(version), "TIDB")
+}
+
+// Vendors of MySQL forks detected by DetectVendor.
+const (
+	VendorPolarDB = "polardb"
+	VendorTDSQL   = "tdsql"
+	VendorAurora  = "aurora"
+	VendorPercona = "percona"
+)
+
+// vendorKeywords are the upper case keywords in `version` or `version_comment`
+// of each vendor, in the order of detection.
+var vendorKeywords = []struct {
+	vendor   string
+	keywords []string
+}{
+	// PolarDB-X reports its version like "5.6.29-TDDL-5.4.12".
+	{VendorPolarDB, []string{"POLARDB", "TDDL"}},
+	// TXSQL is the MySQL kernel of TDSQL.
+	{VendorTDSQL, []string{"TDSQL", "TXSQL"}},
+	{VendorAurora, []string{"AURORA"}},
+	{VendorPercona, []string{"PERCONA"}},
+}
+
+// DetectVendor tells the vendor of a MySQL fork by `version` and `version_comment`,
+// which is one of VendorPolarDB, VendorTDSQL, VendorAurora and VendorPercona. It
+// returns an empty string if the vendor is unknown. Unlike GetFlavor, it's only
+// used to apply workarounds for the vendor.
+func DetectVendor(version, versionComment string) string {
+	s := strings.ToUpper(version + " " + versionComment)
+	for _, v := range vendorKeywords {
+		for _, keyword := range v.keywords {
+			if strings.Contains(s, keyword) {
+				return v.vendor
+			}
+		}
+	}
+	return ""
+}
+
+// CreateTableSQLToOneRow formats the result of SHOW CREATE TABLE to one row.
+func CreateTableSQLToOneRow(sql string) string {
+	sql = strings.ReplaceAll(sql, "\n", "")
+	sql = strings.ReplaceAll(sql, "  ", " ")
+	return sql
+}
+
+// GetCreateTableSQL gets the result of SHOW CREATE TABLE as it is, use
+// CreateTableSQLToOneRow to format it if needed.
+func GetCreateTableSQL(ctx context.Context, db *BaseDB, schema, table string) (string, error) {
+	query := fmt.Sprintf("SHOW CREATE TABLE %s", QuoteSchema(schema, table))
+	var name, createSQL string
+	err := db.DB.QueryRowContext(ctx, query).Scan(&name, &createSQL)
+	if err == sql.ErrNoRows {
+		return "", terror.ErrDBUnExpect.Generate(fmt.Sprintf("table %s not found", QuoteSchema(schema, table)))
+	}
+	if err != nil {
+		return "", terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+	return createSQL, nil
+}
+
+// FetchAllDoTables returns all need to do tables after filtered (fetches from upstream MySQL).
+// If maxTables > 0 and more than maxTables tables are fetched, it returns ErrTooManyDoTables
+// early to avoid holding a huge table list in memory. maxTables <= 0 means no limit.
+func FetchAllDoTables(ctx context.Context, db *BaseDB, bw *filter.Filter, maxTables int) (map[string][]string, error) {
+	return fetchAllDoTables(ctx, db, bw, maxTables, false)
+}
+
+// FetchAllDoTablesSkipTemporary is like FetchAllDoTables, but temporary tables
+// are skipped even if they are included by the filter.
+func FetchAllDoTablesSkipTemporary(ctx context.Context, db *BaseDB, bw *filter.Filter, maxTables int) (map[string][]string, error) {
+	return fetchAllDoTables(ctx, db, bw, maxTables, true)
+}
+
+func fetchAllDoTables(
+	ctx context.Context,
+	db *BaseDB,
+	bw *filter.Filter,
+	maxTables int,
+	skipTemporary bool,
+) (map[string][]string, error) {
+	schemaToTables := make(map[string][]string)
+	err := forEachDoTables(ctx, db, bw, maxTables, skipTemporary, func(schema string, ftTables []*filter.Table) error {
+		tables := make([]string, 0, len(ftTables))
+		for _, ftTable := range ftTables {
+			tables = append(tables, ftTable.Name)
+		}
+		schemaToTables[schema] = tables
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return schemaToTables, nil
+}
+
+// forEachDoTables fetches tables from upstream MySQL schema by schema, and calls
+// fn with the filtered tables of each schema. Schemas without any table to do are skipped.
+// If skipTemporary is true, temporary tables are skipped too.
+func forEachDoTables(
+	ctx context.Context,
+	db *BaseDB,
+	bw *filter.Filter,
+	maxTables int,
+	skipTemporary bool,
+	fn func(schema string, ftTables []*filter.Table) error,
+) error {
+	schemas, err := dbutil.GetSchemas(ctx, db.DB)
+
+	failpoint.Inject("FetchAllDoTablesFailed", func(val failpoint.Value) {
+		err = tmysql.NewErr(uint16(val.(int)))
+		log.L().Warn("FetchAllDoTables failed", zap.String("failpoint", "FetchAllDoTablesFailed"), zap.Error(err))
+	})
+
+	if err != nil {
+		return terror.WithScope(err, db.Scope)
+	}
+
+	ftSchemas := make([]*filter.Table, 0, len(schemas))
+	for _, schema := range schemas {
+		if filter.IsSystemSchema(schema) {
+			continue
+		}
+		ftSchemas = append(ftSchemas, &filter.Table{
+			Schema: schema,
+			Name:   "", // schema level
+		})
+	}
+	ftSchemas = bw.Apply(ftSchemas)
+	if len(ftSchemas) == 0 {
+		log.L().Warn("no schema need to sync")
+		return nil
+	}
+
+	tableCount := 0
+	for _, ftSchema := range ftSchemas {
+		schema := ftSchema.Schema
+		// use `GetTables` from tidb-tools, no view included
+		tables, err := dbutil.GetTables(ctx, db.DB, schema)
+		if err != nil {
+			return terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+		}
+		ftTables := make([]*filter.Table, 0, len(tables))
+		for _, table := range tables {
+			ftTables = append(ftTables, &filter.Table{
+				Schema: schema,
+				Name:   table,
+			})
+		}
+		ftTables = bw.Apply(ftTables)
+		if skipTemporary && len(ftTables) > 0 {
+			ftTables, err = skipTemporaryTables(ctx, db, schema, ftTables)
+			if err != nil {
+				return err
+			}
+		}
+		if len(ftTables) == 0 {
+			log.L().Info("no tables need to sync", zap.String("schema", schema))
+			continue // NOTE: should we still keep it as an empty elem?
+		}
+		tableCount += len(ftTables)
+		if maxTables > 0 && tableCount > maxTables {
+			return terror.ErrTooManyDoTables.Generate(tableCount, maxTables)
+		}
+		if err = fn(schema, ftTables); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipTemporaryTables removes temporary tables from ftTables of the schema.
+func skipTemporaryTables(ctx context.Context, db *BaseDB, schema string, ftTables []*filter.Table) ([]*filter.Table, error) {
+	temporaryTables, err := getTemporaryTables(ctx, db, schema)
+	if err != nil || len(temporaryTables) == 0 {
+		return ftTables, err
+	}
+	tables := make([]*filter.Table, 0, len(ftTables))
+	for _, ftTable := range ftTables {
+		if _, ok := temporaryTables[ftTable.Name]; ok {
+			log.L().Warn("skip temporary table", zap.String("schema", schema), zap.String("table", ftTable.Name))
+			continue
+		}
+		tables = append(tables, ftTable)
+	}
+	return tables, nil
+}
+
+// IsTemporaryTable checks whether the table is a temporary table, which
+// shouldn't be captured. Temporary tables visible in information_schema.TABLES
+// have a table type containing "TEMPORARY". It returns false if the table isn't found.
+func IsTemporaryTable(ctx context.Context, db *BaseDB, schema, table string) (bool, error) {
+	query := "SELECT TABLE_TYPE FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?"
+	var tableType string
+	err := db.DB.QueryRowContext(ctx, query, schema, table).Scan(&tableType)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+	return strings.Contains(strings.ToUpper(tableType), "TEMPORARY"), nil
+}
+
+// GetTableAutoIncrement gets the next AUTO_INCREMENT value of the table from
+// information_schema.TABLES. It returns 0 if the table has no AUTO_INCREMENT column.
+// It's used to detect overlapping auto increment ranges when merging tables.
+func GetTableAutoIncrement(ctx context.Context, db *BaseDB, schema, table string) (uint64, error) {
+	query := "SELECT AUTO_INCREMENT FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?"
+	var autoIncrement sql.NullInt64
+	err := db.DB.QueryRowContext(ctx, query, schema, table).Scan(&autoIncrement)
+	if err == sql.ErrNoRows {
+		return 0, terror.ErrDBUnExpect.Generate(fmt.Sprintf("table %s.%s not found", schema, table))
+	}
+	if err != nil {
+		return 0, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+	if !autoIncrement.Valid {
+		return 0, nil
+	}
+	return uint64(autoIncrement.Int64), nil
+}
+
+// GetTableRowEstimate gets the estimated row count of the table from
+// information_schema.TABLES, which may differ a lot from the exact count. It
+// returns 0 if the server doesn't provide it, like views.
+func GetTableRowEstimate(ctx context.Context, db *BaseDB, schema, table string) (uint64, error) {
+	query := "SELECT TABLE_ROWS FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?"
+	var rows sql.NullInt64
+	err := db.DB.QueryRowContext(ctx, query, schema, table).Scan(&rows)
+	if err == sql.ErrNoRows {
+		return 0, terror.ErrDBUnExpect.Generate(fmt.Sprintf("table %s.%s not found", schema, table))
+	}
+	if err != nil {
+		return 0, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+	if !rows.Valid || rows.Int64 < 0 {
+		return 0, nil
+	}
+	return uint64(rows.Int64), nil
+}
+
+// GetUniqueKeys gets all unique keys of the table including the primary key from
+// information_schema.STATISTICS, it maps index names to their columns in order.
+// Unique keys containing expressions are ignored because they can't be represented
+// by columns.
+func GetUniqueKeys(ctx context.Context, db *BaseDB, schema, table string) (map[string][]string, error) {
+	query := "SELECT INDEX_NAME, COLUMN_NAME FROM information_schema.STATISTICS " +
+		"WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND NON_UNIQUE = 0 ORDER BY INDEX_NAME, SEQ_IN_INDEX"
+	rows, err := db.DB.QueryContext(ctx, query, schema, table)
+	if err != nil {
+		return nil, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+	defer rows.Close()
+
+	uniqueKeys := make(map[string][]string)
+	expressionKeys := make(map[string]struct{})
+	for rows.Next() {
+		var (
+			indexName string
+			column    sql.NullString
+		)
+		if err = rows.Scan(&indexName, &column); err != nil {
+			return nil, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+		}
+		if !column.Valid {
+			expressionKeys[indexName] = struct{}{}
+			continue
+		}
+		uniqueKeys[indexName] = append(uniqueKeys[indexName], column.String)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+	for indexName := range expressionKeys {
+		delete(uniqueKeys, indexName)
+	}
+	return uniqueKeys, nil
+}
+
+// GetAutoIncrementColumns gets the AUTO_INCREMENT columns of the table from the
+// EXTRA field of information_schema.COLUMNS, in the order of the table definition.
+// MySQL allows at most one such column, but it's not assumed here.
+func GetAutoIncrementColumns(ctx context.Context, db *BaseDB, schema, table string) ([]string, error) {
+	query := "SELECT COLUMN_NAME, EXTRA FROM information_schema.COLUMNS " +
+		"WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION"
+	rows, err := db.DB.QueryContext(ctx, query, schema, table)
+	if err != nil {
+		return nil, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var (
+			column string
+			extra  sql.NullString
+		)
+		if err = rows.Scan(&column, &extra); err != nil {
+			return nil, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+		}
+		if strings.Contains(strings.ToLower(extra.String), "auto_increment") {
+			columns = append(columns, column)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+	return columns, nil
+}
+
+// GetClusteredPKColumns gets the primary key columns of the table in order if the
+// primary key is the clustered index in TiDB, which is told by the TIDB_PK_TYPE
+// field of information_schema.TABLES. nil is returned if the table has no clustered
+// primary key, or the server doesn't report TIDB_PK_TYPE.
+func GetClusteredPKColumns(ctx context.Context, db *BaseDB, schema, table string) ([]string, error) {
+	query := "SELECT TIDB_PK_TYPE FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?"
+	var pkType sql.NullString
+	err := db.DB.QueryRowContext(ctx, query, schema, table).Scan(&pkType)
+	if err == sql.ErrNoRows {
+		return nil, terror.ErrDBUnExpect.Generate(fmt.Sprintf("table %s.%s not found", schema, table))
+	}
+	if err != nil {
+		if IsMySQLError(err, tmysql.ErrBadField) {
+			return nil, nil
+		}
+		return nil, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+	if !strings.EqualFold(pkType.String, "CLUSTERED") {
+		return nil, nil
+	}
+
+	query = "SELECT COLUMN_NAME FROM information_schema.STATISTICS " +
+		"WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND INDEX_NAME = 'PRIMARY' ORDER BY SEQ_IN_INDEX"
+	rows, err := db.DB.QueryContext(ctx, query, schema, table)
+	if err != nil {
+		return nil, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err = rows.Scan(&column); err != nil {
+			return nil, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+		}
+		columns = append(columns, column)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+	return columns, nil
+}
+
+// getTemporaryTables returns the names of all temporary tables in the schema.
+func getTemporaryTables(ctx context.Context, db *BaseDB, schema string) (map[string]struct{}, error) {
+	query := "SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND UPPER(TABLE_TYPE) LIKE '%TEMPORARY%'"
+	rows, err := db.DB.QueryContext(ctx, query, schema)
+	if err != nil {
+		return nil, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+	defer rows.Close()
+
+	tables := make(map[string]struct{})
+	for rows.Next() {
+		var table string
+		if err = rows.Scan(&table); err != nil {
+			return nil, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+		}
+		tables[table] = struct{}{}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+	return tables, nil
+}
+
+// FetchTargetDoTables returns all need to do tables after filtered and routed (fetches from upstream MySQL).
+// The extended columns are keyed by the target table, if more than one source
+// table is routed to the target, only one of their extended columns is kept. Use
+// FetchTargetDoTablesWithExtendedColumns to get the extended columns of each source table.
+func FetchTargetDoTables(
+	ctx context.Context,
+	source string,
+	db *BaseDB,
+	bw *filter.Filter,
+	router *regexprrouter.RouteTable,
+) (map[filter.Table][]filter.Table, map[filter.Table][]string, error) {
+	tableMapper, extendedColumnPerSource, err := FetchTargetDoTablesWithExtendedColumns(ctx, source, db, bw, router)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	extendedColumnPerTable := make(map[filter.Table][]string)
+	for target, sourceTables := range tableMapper {
+		for _, sourceTable := range sourceTables {
+			if ext, ok := extendedColumnPerSource[sourceTable]; ok {
+				extendedColumnPerTable[target] = ext.Columns
+			}
+		}
+	}
+	return tableMapper, extendedColumnPerTable, nil
+}
+
+// ExtendedColumns is the extended columns and their values of a source table,
+// which are extracted by the table routing rule.
+type ExtendedColumns struct {
+	Columns []string
+	Values  []string
+}
+
+// FetchTargetDoTablesWithExtendedColumns is the same as FetchTargetDoTables, but
+// the extended columns are keyed by the source table, so the extended columns of
+// all source tables routed to the same target are kept.
+func FetchTargetDoTablesWithExtendedColumns(
+	ctx context.Context,
+	source string,
+	db *BaseDB,
+	bw *filter.Filter,
+	router *regexprrouter.RouteTable,
+) (map[filter.Table][]filter.Table, map[filter.Table]ExtendedColumns, error) {
+	// fetch tables from source and filter them
+	sourceTables, err := FetchAllDoTables(ctx, db, bw, 0)
+
+	failpoint.Inject("FetchTargetDoTablesFailed", func(val failpoint.Value) {
+		err = tmysql.NewErr(uint16(val.(int)))
+		log.L().Warn("FetchTargetDoTables failed", zap.String("failpoint", "FetchTargetDoTablesFailed"), zap.Error(err))
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tableMapper := make(map[filter.Table][]filter.Table)
+	extendedColumnPerSource := make(map[filter.Table]ExtendedColumns)
 	for schema, tables := range sourceTables {
 		for _, table := range tables {
 			targetSchema, targetTable, err := router.Route(schema, table)
 			if err != nil {
-				return nil, nil, terror.ErrGenTableRouter.Delegate(err)
+				return nil, nil, terror.Annotatef(terror.ErrGenTableRouter.Delegate(err),
+					"route table %s", QuoteSchema(schema, table))
 			}
 
 			target := filter.Table{
 				Schema: targetSchema,
 				Name:   targetTable,
 			}
-			tableMapper[target] = append(tableMapper[target], filter.Table{
+			sourceTable := filter.Table{
 				Schema: schema,
 				Name:   table,
-			})
+			}
+			tableMapper[target] = append(tableMapper[target], sourceTable)
+			cols, vals := router.FetchExtendColumn(schema, table, source)
+			if len(cols) > 0 {
+				extendedColumnPerSource[sourceTable] = ExtendedColumns{Columns: cols, Values: vals}
+			}
+		}
+	}
+
+	return tableMapper, extendedColumnPerSource, nil
+}
+
+// FetchTargetDoTablesSinglePass is the same as FetchTargetDoTables, but it routes
+// the tables of each schema right after they are fetched and filtered, instead of
+// collecting all source tables first. It reduces the memory usage for upstreams
+// with a large number of tables.
+func FetchTargetDoTablesSinglePass(
+	ctx context.Context,
+	source string,
+	db *BaseDB,
+	bw *filter.Filter,
+	router *regexprrouter.RouteTable,
+) (map[filter.Table][]filter.Table, map[filter.Table][]string, error) {
+	tableMapper := make(map[filter.Table][]filter.Table)
+	extendedColumnPerTable := make(map[filter.Table][]string)
+	err := forEachDoTables(ctx, db, bw, 0, false, func(schema string, ftTables []*filter.Table) error {
+		for _, ftTable := range ftTables {
+			table := ftTable.Name
+			targetSchema, targetTable, err := router.Route(schema, table)
+			if err != nil {
+				return terror.Annotatef(terror.ErrGenTableRouter.Delegate(err),
+					"route table %s", QuoteSchema(schema, table))
+			}
+
+			target := filter.Table{
+				Schema: targetSchema,
+				Name:   targetTable,
+			}
+			tableMapper[target] = append(tableMapper[target], *ftTable)
 			col, _ := router.FetchExtendColumn(schema, table, source)
 			if len(col) > 0 {
 				extendedColumnPerTable[target] = col
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return tableMapper, extendedColumnPerTable, nil
+}
+
+// TriggerInfo is the information of a trigger in `information_schema.TRIGGERS`.
+type TriggerInfo struct {
+	Name string
+	// Table is the table which the trigger is associated with.
+	Table string
+	// Event is the triggering event, one of INSERT, UPDATE and DELETE.
+	Event string
+	// Timing is the action timing, BEFORE or AFTER.
+	Timing string
+}
+
+// GetTriggers returns all triggers in the schema. Triggers on the downstream
+// can double-apply changes which are already replicated from the upstream.
+func GetTriggers(ctx context.Context, db *BaseDB, schema string) ([]TriggerInfo, error) {
+	query := "SELECT TRIGGER_NAME, EVENT_OBJECT_TABLE, EVENT_MANIPULATION, ACTION_TIMING FROM information_schema.TRIGGERS WHERE TRIGGER_SCHEMA = ? ORDER BY EVENT_OBJECT_TABLE, TRIGGER_NAME"
+	rows, err := db.DB.QueryContext(ctx, query, schema)
+	if err != nil {
+		return nil, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+	defer rows.Close()
+
+	var triggers []TriggerInfo
+	for rows.Next() {
+		var trigger TriggerInfo
+		if err = rows.Scan(&trigger.Name, &trigger.Table, &trigger.Event, &trigger.Timing); err != nil {
+			return nil, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+		}
+		triggers = append(triggers, trigger)
 	}
+	if err = rows.Err(); err != nil {
+		return nil, terror.DBErrorAdapt(err, db.Scope, terror.ErrDBDriverError)
+	}
+	return triggers, nil
+}
 
-	return tableMapper, extendedColumnPerTable, nil
+// tidbOptimizerSwitches are the TiDB optimizer switches which may change the
+// plans of the statements executed by DM.
+var tidbOptimizerSwitches = map[string]struct{}{
+	"tidb_opt_agg_push_down":             {},
+	"tidb_opt_distinct_agg_push_down":    {},
+	"tidb_opt_insubq_to_join_and_agg":    {},
+	"tidb_opt_prefer_range_scan":         {},
+	"tidb_opt_write_row_id":              {},
+	"tidb_opt_limit_push_down_threshold": {},
+}
+
+// GetTiDBOptimizerSwitches gets the session variables `tidb_opt_*` of interest
+// for BaseConn, keyed by the lower case variable name. It returns an empty map
+// without error if the server is not TiDB.
+func GetTiDBOptimizerSwitches(ctx *tcontext.Context, conn *BaseConn) (map[string]string, error) {
+	version, err := GetSessionVariable(ctx, conn, "version")
+	if err != nil {
+		return nil, err
+	}
+	switches := make(map[string]string)
+	if !IsTiDB(version) {
+		return switches, nil
+	}
+
+	row, err := conn.QuerySQL(ctx, "SHOW VARIABLES LIKE 'tidb\\_opt\\_%'")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = row.Close()
+		_ = row.Err()
+	}()
+	var name, value string
+	for row.Next() {
+		if err = row.Scan(&name, &value); err != nil {
+			return nil, terror.DBErrorAdapt(err, conn.Scope, terror.ErrDBDriverError)
+		}
+		name = strings.ToLower(name)
+		if _, ok := tidbOptimizerSwitches[name]; ok {
+			switches[name] = value
+		}
+	}
+	if err = row.Err(); err != nil {
+		return nil, terror.DBErrorAdapt(err, conn.Scope, terror.ErrDBDriverError)
+	}
+	return switches, nil
 }
@@ -32,6 +32,7 @@ import (
 	tcontext "github.com/pingcap/tiflow/dm/pkg/context"
 	"github.com/pingcap/tiflow/dm/pkg/gtid"
 	"github.com/pingcap/tiflow/dm/pkg/log"
+	"github.com/pingcap/tiflow/dm/pkg/terror"
 	"github.com/stretchr/testify/require"
 )
 
@@ -99,6 +100,56 @@ func TestGetMariaDBGtidDomainID(t *testing.T) {
 	require.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestGetMariaDBServerIDBits(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDBTimeout)
+	defer cancel()
+	tctx := tcontext.NewContext(ctx, log.L())
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	rows := mock.NewRows([]string{"Variable_name", "Value"}).AddRow("server_id_bits", 32)
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'server_id_bits'`).WillReturnRows(rows)
+	bits, err := GetMariaDBServerIDBits(tctx, NewBaseDBForTest(db))
+	require.NoError(t, err)
+	require.Equal(t, uint32(32), bits)
+
+	rows = mock.NewRows([]string{"Variable_name", "Value"}).AddRow("server_id_bits", "abc")
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'server_id_bits'`).WillReturnRows(rows)
+	_, err = GetMariaDBServerIDBits(tctx, NewBaseDBForTest(db))
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetMariaDBGTIDConfig(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDBTimeout)
+	defer cancel()
+	tctx := tcontext.NewContext(ctx, log.L())
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	rows := mock.NewRows([]string{"Variable_name", "Value"}).AddRow("gtid_domain_id", 123)
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'gtid_domain_id'`).WillReturnRows(rows)
+	rows = mock.NewRows([]string{"Variable_name", "Value"}).AddRow("server_id", 456)
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'server_id'`).WillReturnRows(rows)
+	cfg, err := GetMariaDBGTIDConfig(tctx, NewBaseDBForTest(db))
+	require.NoError(t, err)
+	require.Equal(t, MariaDBGTIDConfig{DomainID: 123, ServerID: 456, UUID: "123-456"}, cfg)
+
+	// failed to get `server_id`
+	rows = mock.NewRows([]string{"Variable_name", "Value"}).AddRow("gtid_domain_id", 123)
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'gtid_domain_id'`).WillReturnRows(rows)
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'server_id'`).WillReturnError(errors.New("mock error"))
+	_, err = GetMariaDBGTIDConfig(tctx, NewBaseDBForTest(db))
+	require.Error(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestGetServerUUID(t *testing.T) {
 	t.Parallel()
 
@@ -146,6 +197,37 @@ func TestGetServerUnixTS(t *testing.T) {
 	require.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestGetConnectionID(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDBTimeout)
+	defer cancel()
+	tctx := tcontext.NewContext(ctx, log.L())
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+	conn, err := baseDB.GetBaseConn(ctx)
+	require.NoError(t, err)
+	defer baseDB.ForceCloseConnWithoutErr(conn)
+
+	rows := sqlmock.NewRows([]string{"CONNECTION_ID()"}).AddRow("12345")
+	mock.ExpectQuery(`SELECT CONNECTION_ID\(\)`).WillReturnRows(rows)
+	connID, err := GetConnectionID(tctx, conn)
+	require.NoError(t, err)
+	require.Equal(t, uint32(12345), connID)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	mock.ExpectQuery(`SELECT CONNECTION_ID\(\)`).WillReturnError(errors.New("connection refused"))
+	_, err = GetConnectionID(tctx, conn)
+	require.True(t, terror.ErrDBDriverError.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// invalid connection.
+	_, err = GetConnectionID(tctx, nil)
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+}
+
 func TestGetParser(t *testing.T) {
 	t.Parallel()
 
@@ -289,6 +371,107 @@ func createMockResult(mock sqlmock.Sqlmock, masterID uint32, serverIDs []uint32,
 	mock.ExpectQuery("SHOW GLOBAL VARIABLES LIKE 'server_id'").WillReturnRows(sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("server_id", masterID))
 }
 
+func expectVersion(mock sqlmock.Sqlmock, version string) {
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'version'`).WillReturnRows(
+		sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("version", version))
+}
+
+func TestKillConn(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	tctx := tcontext.NewContext(context.Background(), log.L())
+	baseDB := NewBaseDBForTest(db)
+
+	// the version is only queried by the first call.
+	expectVersion(mock, "8.0.32")
+	mock.ExpectExec("KILL 1").WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, KillConn(tctx, baseDB, 1, 100, false))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// refuse to kill the protected connection by default.
+	err = KillConn(tctx, baseDB, 100, 100, false)
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+	require.ErrorContains(t, err, "refuse to kill the protected connection 100")
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// nothing is protected.
+	mock.ExpectExec("KILL 100").WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, KillConn(tctx, baseDB, 100, 0, false))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// force to kill the protected connection.
+	mock.ExpectExec("KILL 100").WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, KillConn(tctx, baseDB, 100, 100, true))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// fail to kill.
+	mock.ExpectExec("KILL 1").WillReturnError(errors.New("connection refused"))
+	err = KillConn(tctx, baseDB, 1, 100, false)
+	require.True(t, terror.ErrDBDriverError.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// use `KILL TIDB` for TiDB.
+	tidbDB := NewBaseDBForTest(db)
+	expectVersion(mock, "5.7.25-TiDB-v7.1.0")
+	mock.ExpectExec("KILL TIDB 1").WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, KillConn(tctx, tidbDB, 1, 100, false))
+	mock.ExpectExec("KILL TIDB 100").WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, KillConn(tctx, tidbDB, 100, 100, true))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// fail to get the version, and it's detected again by the next call.
+	failedDB := NewBaseDBForTest(db)
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'version'`).WillReturnError(errors.New("connection refused"))
+	err = KillConn(tctx, failedDB, 1, 100, true)
+	require.ErrorContains(t, err, "connection refused")
+	expectVersion(mock, "8.0.32")
+	mock.ExpectExec("KILL 1").WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, KillConn(tctx, failedDB, 1, 100, true))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestKillConns(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	tctx := tcontext.NewContext(context.Background(), log.L())
+
+	expectVersion(mock, "8.0.32")
+	mock.ExpectExec("KILL 1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("KILL 2").WillReturnError(newMysqlErr(tmysql.ErrNoSuchThread, "Unknown thread id: 2"))
+	mock.ExpectExec("KILL 3").WillReturnResult(sqlmock.NewResult(0, 0))
+	killed, err := KillConns(tctx, NewBaseDBForTest(db), []uint32{1, 2, 3}, 100)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{1, 3}, killed)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// other errors are returned, but the rest connections are still killed.
+	expectVersion(mock, "5.7.25-TiDB-v7.1.0")
+	mock.ExpectExec("KILL TIDB 4").WillReturnError(newMysqlErr(tmysql.ErrSpecificAccessDenied, "Access denied"))
+	mock.ExpectExec("KILL TIDB 5").WillReturnError(newMysqlErr(tmysql.ErrNoSuchThread, "Unknown thread id: 5"))
+	mock.ExpectExec("KILL TIDB 6").WillReturnResult(sqlmock.NewResult(0, 0))
+	killed, err = KillConns(tctx, NewBaseDBForTest(db), []uint32{4, 5, 6}, 100)
+	require.True(t, terror.ErrDBDriverError.Equal(err))
+	require.ErrorContains(t, err, "Access denied")
+	require.Equal(t, []uint32{6}, killed)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// the protected connection is skipped with an error.
+	expectVersion(mock, "8.0.32")
+	mock.ExpectExec("KILL 7").WillReturnResult(sqlmock.NewResult(0, 0))
+	killed, err = KillConns(tctx, NewBaseDBForTest(db), []uint32{100, 7}, 100)
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+	require.Equal(t, []uint32{7}, killed)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	killed, err = KillConns(tctx, NewBaseDBForTest(db), nil, 100)
+	require.NoError(t, err)
+	require.Len(t, killed, 0)
+}
+
 func newMysqlErr(number uint16, message string) *mysql.MySQLError {
 	return &mysql.MySQLError{
 		Number:  number,
@@ -334,6 +517,121 @@ func TestTiDBVersion(t *testing.T) {
 	}
 }
 
+func TestSupportsInstantDDL(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		version string
+		support bool
+	}{
+		{"5.7.25-TiDB-v2.1.19", false},
+		{"5.7.25-TiDB-v3.0.0", true},
+		{"5.7.25-TiDB-v4.0.0-beta.2-1293-g0843f32c0-dirty", true},
+		{"8.0.11-TiDB-v7.1.0", true},
+		{"5.7.25-TiDB-invalid", false},
+		{"5.7.31-log", false},
+		{"8.0.11", false},
+		{"8.0.12", true},
+		{"8.0.32-0ubuntu0.22.04.2", true},
+		{"10.3.1-MariaDB", false},
+		{"10.3.2-MariaDB-1~wheezy", true},
+		{"5.5.5-10.6.12-MariaDB-log", true},
+		{"5.5.5-10.2.44-MariaDB", false},
+		{"wrong-version", false},
+		{"", false},
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.support, SupportsInstantDDL(tc.version), tc.version)
+	}
+}
+
+func TestRequireMinVersion(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+	minMySQL, minTiDB := semver.New("5.7.0"), semver.New("5.0.0")
+
+	testCases := []struct {
+		version string
+		tooLow  bool
+	}{
+		// MySQL and MariaDB.
+		{"5.6.51-log", true},
+		{"5.7.0", false},
+		{"8.0.32-0ubuntu0.22.04.2", false},
+		{"5.5.5-10.6.12-MariaDB-log", false},
+		// TiDB is checked by its own version rather than the compatible MySQL version.
+		{"5.7.25-TiDB-v4.0.16", true},
+		{"5.7.25-TiDB-v5.0.0", false},
+		{"8.0.11-TiDB-v7.1.0", false},
+	}
+	for _, tc := range testCases {
+		expectVersion(mock, tc.version)
+		err = RequireMinVersion(context.Background(), baseDB, minMySQL, minTiDB)
+		if tc.tooLow {
+			require.True(t, terror.ErrDBVersionTooLow.Equal(err), tc.version)
+		} else {
+			require.NoError(t, err, tc.version)
+		}
+	}
+
+	// no requirement for the flavor.
+	expectVersion(mock, "5.7.25-TiDB-v3.0.0")
+	require.NoError(t, RequireMinVersion(context.Background(), baseDB, minMySQL, nil))
+
+	expectVersion(mock, "wrong-version")
+	err = RequireMinVersion(context.Background(), baseDB, minMySQL, minTiDB)
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'version'`).WillReturnError(errors.New("connection refused"))
+	err = RequireMinVersion(context.Background(), baseDB, minMySQL, minTiDB)
+	require.True(t, terror.ErrDBDriverError.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCompareVersions(t *testing.T) {
+	t.Parallel()
+
+	aDB, aMock, err := sqlmock.New()
+	require.NoError(t, err)
+	bDB, bMock, err := sqlmock.New()
+	require.NoError(t, err)
+	a, b := NewBaseDBForTest(aDB), NewBaseDBForTest(bDB)
+
+	testCases := []struct {
+		aVer string
+		bVer string
+		cmp  int
+	}{
+		{"5.7.31-log", "8.0.32-0ubuntu0.22.04.2", -1},
+		{"8.0.32", "8.0.32-log", 0},
+		{"5.5.5-10.6.12-MariaDB-log", "10.3.2-MariaDB-1~wheezy", 1},
+		// TiDB is compared by its own version rather than the compatible MySQL version.
+		{"5.7.25-TiDB-v7.1.0", "8.0.11", -1},
+		{"8.0.11-TiDB-v7.1.0", "5.7.25-TiDB-v4.0.0-beta.2-1293-g0843f32c0-dirty", 1},
+		// fall back to compare strings.
+		{"wrong-version", "8.0.32", 1},
+	}
+	for _, tc := range testCases {
+		expectVersion(aMock, tc.aVer)
+		expectVersion(bMock, tc.bVer)
+		aVer, bVer, cmp, err := CompareVersions(context.Background(), a, b)
+		require.NoError(t, err)
+		require.Equal(t, tc.aVer, aVer)
+		require.Equal(t, tc.bVer, bVer)
+		require.Equal(t, tc.cmp, cmp, "%s vs %s", tc.aVer, tc.bVer)
+	}
+
+	expectVersion(aMock, "8.0.32")
+	bMock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'version';`).WillReturnError(errors.New("mock error"))
+	_, _, _, err = CompareVersions(context.Background(), a, b)
+	require.True(t, terror.ErrDBDriverError.Equal(err))
+	require.NoError(t, aMock.ExpectationsWereMet())
+	require.NoError(t, bMock.ExpectationsWereMet())
+}
+
 func getGSetFromString(t *testing.T, s string) gmysql.GTIDSet {
 	t.Helper()
 	gSet, err := gtid.ParserGTID("mysql", s)
@@ -396,125 +694,1594 @@ func TestAddGSetWithPurged(t *testing.T) {
 	}
 }
 
-func TestGetMaxConnections(t *testing.T) {
+func TestGetGTIDPurged(t *testing.T) {
 	t.Parallel()
 
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
 	ctx, cancel := context.WithTimeout(context.Background(), DefaultDBTimeout)
 	defer cancel()
-	tctx := tcontext.NewContext(ctx, log.L())
+	baseDB := NewBaseDBForTest(db)
 
-	db, mock, err := sqlmock.New()
+	purged := "3ccc475b-2343-11e7-be21-6c0b84d59f30:1-5"
+	mock.ExpectQuery("select @@GLOBAL.gtid_purged").WillReturnRows(
+		sqlmock.NewRows([]string{"@@GLOBAL.gtid_purged"}).AddRow(purged))
+	gtidStr, err := GetGTIDPurged(ctx, baseDB)
 	require.NoError(t, err)
+	require.Equal(t, purged, gtidStr)
 
-	rows := mock.NewRows([]string{"Variable_name", "Value"}).AddRow("max_connections", "151")
-	mock.ExpectQuery(`SHOW VARIABLES LIKE 'max_connections'`).WillReturnRows(rows)
-	maxConnections, err := GetMaxConnections(tctx, NewBaseDBForTest(db))
+	mock.ExpectQuery("select @@GLOBAL.gtid_purged").WillReturnError(errors.New("connection refused"))
+	_, err = GetGTIDPurged(ctx, baseDB)
+	require.True(t, terror.ErrDBDriverError.Equal(err))
+
+	conn, err := baseDB.GetBaseConn(ctx)
 	require.NoError(t, err)
-	require.Equal(t, 151, maxConnections)
+	defer baseDB.ForceCloseConnWithoutErr(conn)
+	mock.ExpectQuery("select @@GLOBAL.gtid_purged").WillReturnRows(
+		sqlmock.NewRows([]string{"@@GLOBAL.gtid_purged"}).AddRow(""))
+	gtidStr, err = GetGTIDPurgedForConn(ctx, conn)
+	require.NoError(t, err)
+	require.Equal(t, "", gtidStr)
 	require.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestIsMariaDB(t *testing.T) {
+func TestGetGTIDExecuted(t *testing.T) {
 	t.Parallel()
 
-	require.True(t, IsMariaDB("5.5.50-MariaDB-1~wheezy"))
-	require.False(t, IsMariaDB("5.7.19-17-log"))
-}
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDBTimeout)
+	defer cancel()
+	baseDB := NewBaseDBForTest(db)
 
-func TestCreateTableSQLToOneRow(t *testing.T) {
-	t.Parallel()
+	executed := "3ccc475b-2343-11e7-be21-6c0b84d59f30:1-10"
+	psQuery := "SELECT VARIABLE_VALUE FROM performance_schema.global_variables WHERE VARIABLE_NAME = 'gtid_executed'"
+	mock.ExpectQuery("select @@GLOBAL.gtid_executed").WillReturnRows(
+		sqlmock.NewRows([]string{"@@GLOBAL.gtid_executed"}).AddRow(executed))
+	gtidStr, err := GetGTIDExecuted(ctx, baseDB)
+	require.NoError(t, err)
+	require.Equal(t, executed, gtidStr)
+	require.NoError(t, mock.ExpectationsWereMet())
 
-	input := "CREATE TABLE `t1` (\n  `id` bigint(20) NOT NULL,\n  `c1` varchar(20) DEFAULT NULL,\n  `c2` varchar(20) DEFAULT NULL,\n  PRIMARY KEY (`id`) /*T![clustered_index] NONCLUSTERED */\n) ENGINE=InnoDB DEFAULT CHARSET=latin1 COLLATE=latin1_bin"
-	expected := "CREATE TABLE `t1` ( `id` bigint(20) NOT NULL, `c1` varchar(20) DEFAULT NULL, `c2` varchar(20) DEFAULT NULL, PRIMARY KEY (`id`) /*T![clustered_index] NONCLUSTERED */) ENGINE=InnoDB DEFAULT CHARSET=latin1 COLLATE=latin1_bin"
-	require.Equal(t, expected, CreateTableSQLToOneRow(input))
+	// fall back to performance_schema if the `@@` read is denied.
+	mock.ExpectQuery("select @@GLOBAL.gtid_executed").WillReturnError(
+		newMysqlErr(tmysql.ErrSpecificAccessDenied, "Access denied; you need (at least one of) the SUPER privilege(s) for this operation"))
+	mock.ExpectQuery(psQuery).WillReturnRows(
+		sqlmock.NewRows([]string{"VARIABLE_VALUE"}).AddRow(executed))
+	gtidStr, err = GetGTIDExecuted(ctx, baseDB)
+	require.NoError(t, err)
+	require.Equal(t, executed, gtidStr)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// performance_schema is also denied.
+	mock.ExpectQuery("select @@GLOBAL.gtid_executed").WillReturnError(
+		newMysqlErr(tmysql.ErrAccessDenied, "Access denied"))
+	mock.ExpectQuery(psQuery).WillReturnError(
+		newMysqlErr(tmysql.ErrTableaccessDenied, "SELECT command denied"))
+	_, err = GetGTIDExecuted(ctx, baseDB)
+	require.True(t, terror.ErrDBDriverError.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// other errors don't fall back.
+	mock.ExpectQuery("select @@GLOBAL.gtid_executed").WillReturnError(errors.New("connection refused"))
+	_, err = GetGTIDExecuted(ctx, baseDB)
+	require.True(t, terror.ErrDBDriverError.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestGetSlaveServerID(t *testing.T) {
+func TestGetGTIDPurgedNormalized(t *testing.T) {
 	t.Parallel()
 
 	db, mock, err := sqlmock.New()
 	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDBTimeout)
+	defer cancel()
+	baseDB := NewBaseDBForTest(db)
+	conn, err := baseDB.GetBaseConn(ctx)
+	require.NoError(t, err)
+	defer baseDB.ForceCloseConnWithoutErr(conn)
 
-	cases := []struct {
-		rows    *sqlmock.Rows
-		results map[uint32]struct{}
+	testCases := []struct {
+		purged   string
+		expected string
+		hasErr   bool
 	}{
-		// For MySQL
+		{"", "", false},
+		{" \n", "", false},
+		// MySQL 8.0 style.
 		{
-			sqlmock.NewRows([]string{"Server_id", "Host", "Port", "Master_id", "Slave_UUID"}).
-				AddRow(192168010, "iconnect2", 3306, 192168011, "14cb6624-7f93-11e0-b2c0-c80aa9429562").
-				AddRow(1921680101, "athena", 3306, 192168011, "07af4990-f41f-11df-a566-7ac56fdaf645"),
-			map[uint32]struct{}{
-				192168010: {}, 1921680101: {},
-			},
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-5,53bfca22-690d-11e7-8a62-18ded7a37b78:1-495",
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-5,53bfca22-690d-11e7-8a62-18ded7a37b78:1-495",
+			false,
 		},
-		// For MariaDB
+		// MySQL 5.6 style.
 		{
-			sqlmock.NewRows([]string{"Server_id", "Host", "Port", "Master_id"}).
-				AddRow(192168010, "iconnect2", 3306, 192168011).
-				AddRow(1921680101, "athena", 3306, 192168011),
-			map[uint32]struct{}{
-				192168010: {}, 1921680101: {},
-			},
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-5,\n53bfca22-690d-11e7-8a62-18ded7a37b78:1-495\n",
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-5,53bfca22-690d-11e7-8a62-18ded7a37b78:1-495",
+			false,
 		},
-		// For MariaDB, with Server_id greater than 2^31, to test uint conversion
+		// trailing separators.
 		{
-			sqlmock.NewRows([]string{"Server_id", "Host", "Port", "Master_id"}).
-				AddRow(2147483649, "iconnect2", 3306, 192168011).
-				AddRow(2147483650, "athena", 3306, 192168011),
-			map[uint32]struct{}{
-				2147483649: {}, 2147483650: {},
-			},
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-5,\n",
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-5",
+			false,
 		},
+		{"1-2-100", "", true},
 	}
 
-	tctx := tcontext.NewContext(context.Background(), log.L())
-	for _, ca := range cases {
-		mock.ExpectQuery("SHOW SLAVE HOSTS").WillReturnRows(ca.rows)
-		results, err2 := GetSlaveServerID(tctx, NewBaseDBForTest(db))
-		require.NoError(t, err2)
-		require.Equal(t, ca.results, results)
+	for _, tc := range testCases {
+		mock.ExpectQuery("select @@GLOBAL.gtid_purged").WillReturnRows(
+			sqlmock.NewRows([]string{"@@GLOBAL.gtid_purged"}).AddRow(tc.purged))
+		gtidStr, err := GetGTIDPurgedNormalized(ctx, conn)
+		if tc.hasErr {
+			require.Error(t, err)
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, gtidStr)
 	}
+	require.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestFetchAllDoTables(t *testing.T) {
+func TestGetMaxConnections(t *testing.T) {
 	t.Parallel()
 
-	db, mock, err := sqlmock.New()
-	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDBTimeout)
+	defer cancel()
+	tctx := tcontext.NewContext(ctx, log.L())
 
-	// empty filter, exclude system schemas
-	ba, err := filter.New(false, nil)
+	db, mock, err := sqlmock.New()
 	require.NoError(t, err)
 
-	// no schemas need to do.
-	mock.ExpectQuery(`SHOW DATABASES`).WillReturnRows(sqlmock.NewRows([]string{"Database"}))
-	got, err := FetchAllDoTables(context.Background(), NewBaseDBForTest(db), ba)
+	rows := mock.NewRows([]string{"Variable_name", "Value"}).AddRow("max_connections", "151")
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'max_connections'`).WillReturnRows(rows)
+	maxConnections, err := GetMaxConnections(tctx, NewBaseDBForTest(db))
 	require.NoError(t, err)
-	require.Len(t, got, 0)
+	require.Equal(t, 151, maxConnections)
 	require.NoError(t, mock.ExpectationsWereMet())
+}
 
-	// only system schemas exist, still no need to do.
-	schemas := []string{"information_schema", "mysql", "performance_schema", "sys", filter.DMHeartbeatSchema}
-	rows := sqlmock.NewRows([]string{"Database"})
-	addRowsForSchemas(rows, schemas)
-	mock.ExpectQuery(`SHOW DATABASES`).WillReturnRows(rows)
-	got, err = FetchAllDoTables(context.Background(), NewBaseDBForTest(db), ba)
+func TestGetMaxBinlogSize(t *testing.T) {
+	t.Parallel()
+
+	tctx := tcontext.NewContext(context.Background(), log.L())
+	db, mock, err := sqlmock.New()
 	require.NoError(t, err)
-	require.Len(t, got, 0)
-	require.NoError(t, mock.ExpectationsWereMet())
 
-	// schemas without tables in them.
-	doSchema := "test_db"
-	schemas = []string{"information_schema", "mysql", "performance_schema", "sys", filter.DMHeartbeatSchema, doSchema}
-	rows = sqlmock.NewRows([]string{"Database"})
-	addRowsForSchemas(rows, schemas)
-	mock.ExpectQuery(`SHOW DATABASES`).WillReturnRows(rows)
-	mock.ExpectQuery(fmt.Sprintf("SHOW FULL TABLES IN `%s` WHERE Table_Type != 'VIEW'", doSchema)).WillReturnRows(
-		sqlmock.NewRows([]string{fmt.Sprintf("Tables_in_%s", doSchema), "Table_type"}))
-	got, err = FetchAllDoTables(context.Background(), NewBaseDBForTest(db), ba)
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'max_binlog_size'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("max_binlog_size", "1073741824"))
+	size, err := GetMaxBinlogSize(tctx, NewBaseDBForTest(db))
 	require.NoError(t, err)
-	require.Len(t, got, 0)
+	require.Equal(t, uint64(1073741824), size)
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'max_binlog_size'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("max_binlog_size", "1G"))
+	_, err = GetMaxBinlogSize(tctx, NewBaseDBForTest(db))
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetLogErrorVerbosity(t *testing.T) {
+	t.Parallel()
+
+	tctx := tcontext.NewContext(context.Background(), log.L())
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'log_error_verbosity'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("log_error_verbosity", "2"))
+	verbosity, err := GetLogErrorVerbosity(tctx, NewBaseDBForTest(db))
+	require.NoError(t, err)
+	require.Equal(t, 2, verbosity)
+	require.Equal(t, "errors and warnings", DescribeLogErrorVerbosity(verbosity))
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'log_error_verbosity'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("log_error_verbosity", "WARNING"))
+	_, err = GetLogErrorVerbosity(tctx, NewBaseDBForTest(db))
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	require.Equal(t, "errors only", DescribeLogErrorVerbosity(1))
+	require.Equal(t, "errors, warnings and notes", DescribeLogErrorVerbosity(3))
+	require.Equal(t, "unknown", DescribeLogErrorVerbosity(0))
+}
+
+func TestGetSlowQueryLogStatus(t *testing.T) {
+	t.Parallel()
+
+	tctx := tcontext.NewContext(context.Background(), log.L())
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+
+	expectVariable := func(variable, value string) {
+		mock.ExpectQuery(fmt.Sprintf(`SHOW GLOBAL VARIABLES LIKE '%s'`, variable)).WillReturnRows(
+			mock.NewRows([]string{"Variable_name", "Value"}).AddRow(variable, value))
+	}
+
+	expectVariable("slow_query_log", "ON")
+	expectVariable("long_query_time", "0.500000")
+	enabled, longQueryTime, err := GetSlowQueryLogStatus(tctx, baseDB)
+	require.NoError(t, err)
+	require.True(t, enabled)
+	require.Equal(t, 0.5, longQueryTime)
+
+	expectVariable("slow_query_log", "OFF")
+	expectVariable("long_query_time", "10.000000")
+	enabled, longQueryTime, err = GetSlowQueryLogStatus(tctx, baseDB)
+	require.NoError(t, err)
+	require.False(t, enabled)
+	require.Equal(t, float64(10), longQueryTime)
+
+	expectVariable("slow_query_log", "UNKNOWN")
+	_, _, err = GetSlowQueryLogStatus(tctx, baseDB)
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+
+	expectVariable("slow_query_log", "ON")
+	expectVariable("long_query_time", "abc")
+	_, _, err = GetSlowQueryLogStatus(tctx, baseDB)
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'slow_query_log'`).WillReturnError(errors.New("conn refused"))
+	_, _, err = GetSlowQueryLogStatus(tctx, baseDB)
+	require.Error(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetInnoDBFlushLogAtTrxCommit(t *testing.T) {
+	t.Parallel()
+
+	tctx := tcontext.NewContext(context.Background(), log.L())
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'innodb_flush_log_at_trx_commit'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("innodb_flush_log_at_trx_commit", "2"))
+	value, err := GetInnoDBFlushLogAtTrxCommit(tctx, NewBaseDBForTest(db))
+	require.NoError(t, err)
+	require.Equal(t, 2, value)
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'innodb_flush_log_at_trx_commit'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("innodb_flush_log_at_trx_commit", "ON"))
+	_, err = GetInnoDBFlushLogAtTrxCommit(tctx, NewBaseDBForTest(db))
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	require.True(t, IsRelaxedDurability(0))
+	require.False(t, IsRelaxedDurability(1))
+	require.True(t, IsRelaxedDurability(2))
+}
+
+func TestGetGroupReplicationStatus(t *testing.T) {
+	t.Parallel()
+
+	tctx := tcontext.NewContext(context.Background(), log.L())
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+
+	query := `SELECT MEMBER_ROLE, MEMBER_STATE FROM performance_schema.replication_group_members WHERE MEMBER_ID = @@server_uuid`
+	columns := []string{"MEMBER_ROLE", "MEMBER_STATE"}
+
+	mock.ExpectQuery(query).WillReturnRows(mock.NewRows(columns).AddRow("PRIMARY", "ONLINE"))
+	role, state, err := GetGroupReplicationStatus(tctx, baseDB)
+	require.NoError(t, err)
+	require.Equal(t, "PRIMARY", role)
+	require.Equal(t, "ONLINE", state)
+
+	mock.ExpectQuery(query).WillReturnRows(mock.NewRows(columns).AddRow("SECONDARY", "RECOVERING"))
+	role, state, err = GetGroupReplicationStatus(tctx, baseDB)
+	require.NoError(t, err)
+	require.Equal(t, "SECONDARY", role)
+	require.Equal(t, "RECOVERING", state)
+
+	// not in a group.
+	mock.ExpectQuery(query).WillReturnRows(mock.NewRows(columns))
+	role, state, err = GetGroupReplicationStatus(tctx, baseDB)
+	require.NoError(t, err)
+	require.Equal(t, "", role)
+	require.Equal(t, "", state)
+
+	// Group Replication is not supported.
+	mock.ExpectQuery(query).WillReturnError(&mysql.MySQLError{Number: tmysql.ErrNoSuchTable})
+	role, state, err = GetGroupReplicationStatus(tctx, baseDB)
+	require.NoError(t, err)
+	require.Equal(t, "", role)
+	require.Equal(t, "", state)
+
+	mock.ExpectQuery(query).WillReturnError(errors.New("conn refused"))
+	_, _, err = GetGroupReplicationStatus(tctx, baseDB)
+	require.True(t, terror.ErrDBDriverError.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSlaveNetTimeout(t *testing.T) {
+	t.Parallel()
+
+	tctx := tcontext.NewContext(context.Background(), log.L())
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'slave_net_timeout'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("slave_net_timeout", "60"))
+	timeout, err := GetSlaveNetTimeout(tctx, NewBaseDBForTest(db))
+	require.NoError(t, err)
+	require.Equal(t, 60, timeout)
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'slave_net_timeout'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("slave_net_timeout", "60s"))
+	_, err = GetSlaveNetTimeout(tctx, NewBaseDBForTest(db))
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'slave_net_timeout'`).WillReturnError(errors.New("conn refused"))
+	_, err = GetSlaveNetTimeout(tctx, NewBaseDBForTest(db))
+	require.Error(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetInnoDBLockWaitTimeout(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDBTimeout)
+	defer cancel()
+	tctx := tcontext.NewContext(ctx, log.L())
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+	conn, err := baseDB.GetBaseConn(ctx)
+	require.NoError(t, err)
+	defer baseDB.ForceCloseConnWithoutErr(conn)
+
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'innodb_lock_wait_timeout'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("innodb_lock_wait_timeout", "50"))
+	timeout, err := GetInnoDBLockWaitTimeout(tctx, conn)
+	require.NoError(t, err)
+	require.Equal(t, 50, timeout)
+
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'innodb_lock_wait_timeout'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("innodb_lock_wait_timeout", "50s"))
+	_, err = GetInnoDBLockWaitTimeout(tctx, conn)
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'innodb_lock_wait_timeout'`).WillReturnError(errors.New("conn refused"))
+	_, err = GetInnoDBLockWaitTimeout(tctx, conn)
+	require.Error(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAndClearMaxExecutionTime(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDBTimeout)
+	defer cancel()
+	tctx := tcontext.NewContext(ctx, log.L())
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+	conn, err := baseDB.GetBaseConn(ctx)
+	require.NoError(t, err)
+	defer baseDB.ForceCloseConnWithoutErr(conn)
+
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'max_execution_time'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("max_execution_time", "1000"))
+	timeout, err := GetMaxExecutionTime(tctx, conn)
+	require.NoError(t, err)
+	require.Equal(t, 1000, timeout)
+
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'max_execution_time'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("max_execution_time", "1s"))
+	_, err = GetMaxExecutionTime(tctx, conn)
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// no limit, nothing to clear.
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'max_execution_time'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("max_execution_time", "0"))
+	require.NoError(t, ClearMaxExecutionTime(tctx, conn))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'max_execution_time'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("max_execution_time", "1000"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET SESSION max_execution_time = 0`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	require.NoError(t, ClearMaxExecutionTime(tctx, conn))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'max_execution_time'`).WillReturnError(errors.New("conn refused"))
+	require.Error(t, ClearMaxExecutionTime(tctx, conn))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetWaitTimeouts(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDBTimeout)
+	defer cancel()
+	tctx := tcontext.NewContext(ctx, log.L())
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+	conn, err := baseDB.GetBaseConn(ctx)
+	require.NoError(t, err)
+	defer baseDB.ForceCloseConnWithoutErr(conn)
+
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'wait_timeout'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("wait_timeout", "28800"))
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'interactive_timeout'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("interactive_timeout", "3600"))
+	wait, interactive, err := GetWaitTimeouts(tctx, conn)
+	require.NoError(t, err)
+	require.Equal(t, 28800, wait)
+	require.Equal(t, 3600, interactive)
+
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'wait_timeout'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("wait_timeout", "8h"))
+	_, _, err = GetWaitTimeouts(tctx, conn)
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'wait_timeout'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("wait_timeout", "28800"))
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'interactive_timeout'`).WillReturnError(errors.New("conn refused"))
+	_, _, err = GetWaitTimeouts(tctx, conn)
+	require.Error(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdjustWaitTimeouts(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDBTimeout)
+	defer cancel()
+	tctx := tcontext.NewContext(ctx, log.L())
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+	conn, err := baseDB.GetBaseConn(ctx)
+	require.NoError(t, err)
+	defer baseDB.ForceCloseConnWithoutErr(conn)
+
+	// long enough, nothing to adjust.
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'wait_timeout'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("wait_timeout", "28800"))
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'interactive_timeout'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("interactive_timeout", "28800"))
+	require.NoError(t, AdjustWaitTimeouts(tctx, conn, MinWaitTimeout))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// only wait_timeout is too short.
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'wait_timeout'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("wait_timeout", "60"))
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'interactive_timeout'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("interactive_timeout", "28800"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET SESSION wait_timeout = 600`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	require.NoError(t, AdjustWaitTimeouts(tctx, conn, MinWaitTimeout))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// both are too short.
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'wait_timeout'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("wait_timeout", "30"))
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'interactive_timeout'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("interactive_timeout", "30"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET SESSION wait_timeout = 600`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SET SESSION interactive_timeout = 600`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	require.NoError(t, AdjustWaitTimeouts(tctx, conn, MinWaitTimeout))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAndAdjustNetTimeouts(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDBTimeout)
+	defer cancel()
+	tctx := tcontext.NewContext(ctx, log.L())
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+	conn, err := baseDB.GetBaseConn(ctx)
+	require.NoError(t, err)
+	defer baseDB.ForceCloseConnWithoutErr(conn)
+
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'net_read_timeout'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("net_read_timeout", "30"))
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'net_write_timeout'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("net_write_timeout", "60"))
+	read, write, err := GetNetTimeouts(tctx, conn)
+	require.NoError(t, err)
+	require.Equal(t, 30, read)
+	require.Equal(t, 60, write)
+
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'net_read_timeout'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("net_read_timeout", "abc"))
+	_, _, err = GetNetTimeouts(tctx, conn)
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// long enough, nothing to adjust.
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'net_read_timeout'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("net_read_timeout", "600"))
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'net_write_timeout'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("net_write_timeout", "3600"))
+	require.NoError(t, AdjustNetTimeouts(tctx, conn, MinNetTimeout))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// both are too short.
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'net_read_timeout'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("net_read_timeout", "30"))
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'net_write_timeout'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("net_write_timeout", "60"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET SESSION net_read_timeout = 600`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SET SESSION net_write_timeout = 600`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	require.NoError(t, AdjustNetTimeouts(tctx, conn, MinNetTimeout))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSessionVariables(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDBTimeout)
+	defer cancel()
+	tctx := tcontext.NewContext(ctx, log.L())
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+	conn, err := baseDB.GetBaseConn(ctx)
+	require.NoError(t, err)
+	defer baseDB.ForceCloseConnWithoutErr(conn)
+
+	values, err := GetSessionVariables(tctx, conn)
+	require.NoError(t, err)
+	require.Empty(t, values)
+
+	mock.ExpectQuery(`SHOW VARIABLES WHERE Variable_name IN \(\?,\?,\?\)`).
+		WithArgs("wait_timeout", "interactive_timeout", "unknown_variable").
+		WillReturnRows(mock.NewRows([]string{"Variable_name", "Value"}).
+			AddRow("WAIT_TIMEOUT", "28800").
+			AddRow("interactive_timeout", "600"))
+	values, err = GetSessionVariables(tctx, conn, "wait_timeout", "interactive_timeout", "unknown_variable")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"wait_timeout": "28800", "interactive_timeout": "600"}, values)
+
+	mock.ExpectQuery(`SHOW VARIABLES WHERE Variable_name IN \(\?\)`).
+		WillReturnError(errors.New("connection refused"))
+	_, err = GetSessionVariables(tctx, conn, "wait_timeout")
+	require.Error(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWarmUpConn(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDBTimeout)
+	defer cancel()
+	tctx := tcontext.NewContext(ctx, log.L())
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+	conn, err := baseDB.GetBaseConn(ctx)
+	require.NoError(t, err)
+	defer baseDB.ForceCloseConnWithoutErr(conn)
+
+	// all variables are read by one query, and only too short timeouts are raised.
+	mock.ExpectQuery(`SHOW VARIABLES WHERE Variable_name IN \(\?,\?,\?,\?,\?,\?\)`).
+		WithArgs("collation_connection", "innodb_lock_wait_timeout", "wait_timeout",
+			"interactive_timeout", "net_read_timeout", "net_write_timeout").
+		WillReturnRows(mock.NewRows([]string{"Variable_name", "Value"}).
+			AddRow("collation_connection", "utf8mb4_bin").
+			AddRow("innodb_lock_wait_timeout", "50").
+			AddRow("wait_timeout", "60").
+			AddRow("interactive_timeout", "28800").
+			AddRow("net_read_timeout", "30").
+			AddRow("net_write_timeout", "3600"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET SESSION wait_timeout = 600`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET SESSION net_read_timeout = 600`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	lockWaitTimeout, err := WarmUpConn(tctx, conn, true)
+	require.NoError(t, err)
+	require.Equal(t, 50, lockWaitTimeout)
+	require.NoError(t, mock.ExpectationsWereMet())
+	// collation_connection is cached.
+	collation, err := GetCollationConnection(tctx, conn)
+	require.NoError(t, err)
+	require.Equal(t, "utf8mb4_bin", collation)
+
+	// network timeouts are not read for non-streaming connections, and unknown
+	// innodb_lock_wait_timeout is zero.
+	mock.ExpectQuery(`SHOW VARIABLES WHERE Variable_name IN \(\?,\?,\?,\?\)`).
+		WithArgs("collation_connection", "innodb_lock_wait_timeout", "wait_timeout", "interactive_timeout").
+		WillReturnRows(mock.NewRows([]string{"Variable_name", "Value"}).
+			AddRow("wait_timeout", "28800").
+			AddRow("interactive_timeout", "28800"))
+	lockWaitTimeout, err = WarmUpConn(tctx, conn, false)
+	require.NoError(t, err)
+	require.Equal(t, 0, lockWaitTimeout)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// invalid timeout.
+	mock.ExpectQuery(`SHOW VARIABLES WHERE Variable_name IN`).
+		WillReturnRows(mock.NewRows([]string{"Variable_name", "Value"}).
+			AddRow("wait_timeout", "abc"))
+	_, err = WarmUpConn(tctx, conn, false)
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = WarmUpConn(tctx, nil, false)
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+}
+
+func TestGetMaxPreparedStmtCount(t *testing.T) {
+	t.Parallel()
+
+	tctx := tcontext.NewContext(context.Background(), log.L())
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'max_prepared_stmt_count'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("max_prepared_stmt_count", "16382"))
+	count, err := GetMaxPreparedStmtCount(tctx, NewBaseDBForTest(db))
+	require.NoError(t, err)
+	require.Equal(t, 16382, count)
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'max_prepared_stmt_count'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("max_prepared_stmt_count", "0"))
+	count, err = GetMaxPreparedStmtCount(tctx, NewBaseDBForTest(db))
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'max_prepared_stmt_count'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("max_prepared_stmt_count", "unlimited"))
+	_, err = GetMaxPreparedStmtCount(tctx, NewBaseDBForTest(db))
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'max_prepared_stmt_count'`).WillReturnError(errors.New("conn refused"))
+	_, err = GetMaxPreparedStmtCount(tctx, NewBaseDBForTest(db))
+	require.Error(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCompareTimeZones(t *testing.T) {
+	t.Parallel()
+
+	tctx := tcontext.NewContext(context.Background(), log.L())
+	upDB, upMock, err := sqlmock.New()
+	require.NoError(t, err)
+	downDB, downMock, err := sqlmock.New()
+	require.NoError(t, err)
+	up, down := NewBaseDBForTest(upDB), NewBaseDBForTest(downDB)
+
+	expectTimeZone := func(mock sqlmock.Sqlmock, tz string) {
+		mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'time_zone'`).WillReturnRows(
+			mock.NewRows([]string{"Variable_name", "Value"}).AddRow("time_zone", tz))
+	}
+
+	// matching zones, SYSTEM is resolved to system_time_zone.
+	expectTimeZone(upMock, "SYSTEM")
+	upMock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'system_time_zone'`).WillReturnRows(
+		upMock.NewRows([]string{"Variable_name", "Value"}).AddRow("system_time_zone", "UTC"))
+	expectTimeZone(downMock, "utc")
+	match, upTZ, downTZ, err := CompareTimeZones(tctx, up, down)
+	require.NoError(t, err)
+	require.True(t, match)
+	require.Equal(t, "UTC", upTZ)
+	require.Equal(t, "utc", downTZ)
+
+	// mismatching zones.
+	expectTimeZone(upMock, "+08:00")
+	expectTimeZone(downMock, "+00:00")
+	match, upTZ, downTZ, err = CompareTimeZones(tctx, up, down)
+	require.NoError(t, err)
+	require.False(t, match)
+	require.Equal(t, "+08:00", upTZ)
+	require.Equal(t, "+00:00", downTZ)
+
+	// failed to get downstream time zone.
+	expectTimeZone(upMock, "+08:00")
+	downMock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'time_zone'`).WillReturnError(errors.New("conn refused"))
+	_, _, _, err = CompareTimeZones(tctx, up, down)
+	require.Error(t, err)
+
+	require.NoError(t, upMock.ExpectationsWereMet())
+	require.NoError(t, downMock.ExpectationsWereMet())
+}
+
+func TestGetExplicitDefaultsForTimestamp(t *testing.T) {
+	t.Parallel()
+
+	tctx := tcontext.NewContext(context.Background(), log.L())
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'explicit_defaults_for_timestamp'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("explicit_defaults_for_timestamp", "ON"))
+	explicit, err := GetExplicitDefaultsForTimestamp(tctx, NewBaseDBForTest(db))
+	require.NoError(t, err)
+	require.True(t, explicit)
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'explicit_defaults_for_timestamp'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("explicit_defaults_for_timestamp", "OFF"))
+	explicit, err = GetExplicitDefaultsForTimestamp(tctx, NewBaseDBForTest(db))
+	require.NoError(t, err)
+	require.False(t, explicit)
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'explicit_defaults_for_timestamp'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("explicit_defaults_for_timestamp", "MAYBE"))
+	_, err = GetExplicitDefaultsForTimestamp(tctx, NewBaseDBForTest(db))
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetBinlogOrderCommits(t *testing.T) {
+	t.Parallel()
+
+	tctx := tcontext.NewContext(context.Background(), log.L())
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'binlog_order_commits'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("binlog_order_commits", "ON"))
+	ordered, err := GetBinlogOrderCommits(tctx, NewBaseDBForTest(db))
+	require.NoError(t, err)
+	require.True(t, ordered)
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'binlog_order_commits'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("binlog_order_commits", "OFF"))
+	ordered, err = GetBinlogOrderCommits(tctx, NewBaseDBForTest(db))
+	require.NoError(t, err)
+	require.False(t, ordered)
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'binlog_order_commits'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("binlog_order_commits", "UNKNOWN"))
+	_, err = GetBinlogOrderCommits(tctx, NewBaseDBForTest(db))
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'binlog_order_commits'`).WillReturnError(errors.New("conn refused"))
+	_, err = GetBinlogOrderCommits(tctx, NewBaseDBForTest(db))
+	require.Error(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetBinlogRowsQueryLogEvents(t *testing.T) {
+	t.Parallel()
+
+	tctx := tcontext.NewContext(context.Background(), log.L())
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'binlog_rows_query_log_events'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("binlog_rows_query_log_events", "ON"))
+	enabled, err := GetBinlogRowsQueryLogEvents(tctx, NewBaseDBForTest(db))
+	require.NoError(t, err)
+	require.True(t, enabled)
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'binlog_rows_query_log_events'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("binlog_rows_query_log_events", "OFF"))
+	enabled, err = GetBinlogRowsQueryLogEvents(tctx, NewBaseDBForTest(db))
+	require.NoError(t, err)
+	require.False(t, enabled)
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'binlog_rows_query_log_events'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("binlog_rows_query_log_events", "UNKNOWN"))
+	_, err = GetBinlogRowsQueryLogEvents(tctx, NewBaseDBForTest(db))
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'binlog_rows_query_log_events'`).WillReturnError(errors.New("conn refused"))
+	_, err = GetBinlogRowsQueryLogEvents(tctx, NewBaseDBForTest(db))
+	require.Error(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetBinlogTransactionDependencyTracking(t *testing.T) {
+	t.Parallel()
+
+	tctx := tcontext.NewContext(context.Background(), log.L())
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	for _, tracking := range []string{"COMMIT_ORDER", "WRITESET", "WRITESET_SESSION"} {
+		mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'binlog_transaction_dependency_tracking'`).WillReturnRows(
+			mock.NewRows([]string{"Variable_name", "Value"}).AddRow("binlog_transaction_dependency_tracking", tracking))
+		value, err2 := GetBinlogTransactionDependencyTracking(tctx, NewBaseDBForTest(db))
+		require.NoError(t, err2)
+		require.Equal(t, tracking, value)
+	}
+
+	// the variable doesn't exist, like MySQL 5.6 or MariaDB.
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'binlog_transaction_dependency_tracking'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}))
+	_, err = GetBinlogTransactionDependencyTracking(tctx, NewBaseDBForTest(db))
+	require.Error(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetBinlogTransactionCompression(t *testing.T) {
+	t.Parallel()
+
+	tctx := tcontext.NewContext(context.Background(), log.L())
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	for _, compression := range []string{"ON", "OFF"} {
+		mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'binlog_transaction_compression'`).WillReturnRows(
+			mock.NewRows([]string{"Variable_name", "Value"}).AddRow("binlog_transaction_compression", compression))
+		value, err2 := GetBinlogTransactionCompression(tctx, NewBaseDBForTest(db))
+		require.NoError(t, err2)
+		require.Equal(t, compression, value)
+	}
+
+	// the variable doesn't exist, like MySQL before 8.0.20.
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'binlog_transaction_compression'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}))
+	_, err = GetBinlogTransactionCompression(tctx, NewBaseDBForTest(db))
+	require.Error(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTiDBClusteredIndex(t *testing.T) {
+	t.Parallel()
+
+	tctx := tcontext.NewContext(context.Background(), log.L())
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'version'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("version", "5.7.25-TiDB-v7.1.0"))
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'tidb_enable_clustered_index'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("tidb_enable_clustered_index", "INT_ONLY"))
+	value, err := GetTiDBClusteredIndex(tctx, NewBaseDBForTest(db))
+	require.NoError(t, err)
+	require.Equal(t, "INT_ONLY", value)
+
+	// not TiDB.
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'version'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("version", "8.0.32"))
+	value, err = GetTiDBClusteredIndex(tctx, NewBaseDBForTest(db))
+	require.NoError(t, err)
+	require.Equal(t, "", value)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTiDBStatsHealthy(t *testing.T) {
+	t.Parallel()
+
+	tctx := tcontext.NewContext(context.Background(), log.L())
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+
+	query := "SHOW STATS_HEALTHY WHERE Db_name = \\? AND Table_name = \\?"
+	columns := []string{"Db_name", "Table_name", "Partition_name", "Healthy"}
+	expectTiDB := func() {
+		mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'version'`).WillReturnRows(
+			mock.NewRows([]string{"Variable_name", "Value"}).AddRow("version", "5.7.25-TiDB-v7.1.0"))
+	}
+
+	expectTiDB()
+	mock.ExpectQuery(query).WithArgs("db1", "tbl1").WillReturnRows(
+		mock.NewRows(columns).AddRow("db1", "tbl1", "", 80))
+	healthy, err := GetTiDBStatsHealthy(tctx, baseDB, "db1", "tbl1")
+	require.NoError(t, err)
+	require.Equal(t, 80, healthy)
+
+	// partitioned table.
+	expectTiDB()
+	mock.ExpectQuery(query).WithArgs("db1", "tbl2").WillReturnRows(
+		mock.NewRows(columns).
+			AddRow("db1", "tbl2", "global", 90).
+			AddRow("db1", "tbl2", "p0", 100).
+			AddRow("db1", "tbl2", "p1", 40))
+	healthy, err = GetTiDBStatsHealthy(tctx, baseDB, "db1", "tbl2")
+	require.NoError(t, err)
+	require.Equal(t, 40, healthy)
+
+	// no stats.
+	expectTiDB()
+	mock.ExpectQuery(query).WithArgs("db1", "tbl3").WillReturnRows(mock.NewRows(columns))
+	healthy, err = GetTiDBStatsHealthy(tctx, baseDB, "db1", "tbl3")
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+	require.Equal(t, -1, healthy)
+
+	expectTiDB()
+	mock.ExpectQuery(query).WithArgs("db1", "tbl4").WillReturnError(errors.New("query failed"))
+	healthy, err = GetTiDBStatsHealthy(tctx, baseDB, "db1", "tbl4")
+	require.True(t, terror.ErrDBDriverError.Equal(err))
+	require.Equal(t, -1, healthy)
+
+	// not TiDB.
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'version'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).AddRow("version", "8.0.32"))
+	healthy, err = GetTiDBStatsHealthy(tctx, baseDB, "db1", "tbl1")
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+	require.Equal(t, -1, healthy)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAutoIncrementIncrementAndOffset(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDBTimeout)
+	defer cancel()
+	tctx := tcontext.NewContext(ctx, log.L())
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+	conn, err := baseDB.GetBaseConn(ctx)
+	require.NoError(t, err)
+	defer baseDB.ForceCloseConnWithoutErr(conn)
+
+	rows := mock.NewRows([]string{"Variable_name", "Value"}).AddRow("auto_increment_increment", "2")
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'auto_increment_increment'`).WillReturnRows(rows)
+	increment, err := GetAutoIncrementIncrement(tctx, conn)
+	require.NoError(t, err)
+	require.Equal(t, 2, increment)
+
+	rows = mock.NewRows([]string{"Variable_name", "Value"}).AddRow("auto_increment_offset", "1")
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'auto_increment_offset'`).WillReturnRows(rows)
+	offset, err := GetAutoIncrementOffset(tctx, conn)
+	require.NoError(t, err)
+	require.Equal(t, 1, offset)
+
+	// invalid value
+	rows = mock.NewRows([]string{"Variable_name", "Value"}).AddRow("auto_increment_offset", "abc")
+	mock.ExpectQuery(`SHOW VARIABLES LIKE 'auto_increment_offset'`).WillReturnRows(rows)
+	_, err = GetAutoIncrementOffset(tctx, conn)
+	require.Error(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetCharacterSetClientHandshake(t *testing.T) {
+	t.Parallel()
+
+	tctx := tcontext.NewContext(context.Background(), log.L())
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+
+	rows := mock.NewRows([]string{"Variable_name", "Value"}).AddRow("character_set_client_handshake", "ON")
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'character_set_client_handshake'`).WillReturnRows(rows)
+	handshake, err := GetCharacterSetClientHandshake(tctx, baseDB)
+	require.NoError(t, err)
+	require.True(t, handshake)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	cases := []struct {
+		handshake     string
+		serverCharset string
+		risky         bool
+	}{
+		{"ON", "", false},
+		{"OFF", "utf8mb4", false},
+		{"OFF", "UTF8MB4", false},
+		{"OFF", "latin1", true},
+	}
+	for _, ca := range cases {
+		rows = mock.NewRows([]string{"Variable_name", "Value"}).AddRow("character_set_client_handshake", ca.handshake)
+		mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'character_set_client_handshake'`).WillReturnRows(rows)
+		if ca.handshake == "OFF" {
+			rows = mock.NewRows([]string{"Variable_name", "Value"}).AddRow("character_set_server", ca.serverCharset)
+			mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'character_set_server'`).WillReturnRows(rows)
+		}
+		risky, err2 := IsCharsetHandshakeRisky(tctx, baseDB, "utf8mb4")
+		require.NoError(t, err2)
+		require.Equal(t, ca.risky, risky)
+		require.NoError(t, mock.ExpectationsWereMet())
+	}
+
+	// invalid value
+	rows = mock.NewRows([]string{"Variable_name", "Value"}).AddRow("character_set_client_handshake", "unknown")
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'character_set_client_handshake'`).WillReturnRows(rows)
+	_, err = GetCharacterSetClientHandshake(tctx, baseDB)
+	require.Error(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetUniqueChecks(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDBTimeout)
+	defer cancel()
+	tctx := tcontext.NewContext(ctx, log.L())
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+	conn, err := baseDB.GetBaseConn(ctx)
+	require.NoError(t, err)
+	defer baseDB.ForceCloseConnWithoutErr(conn)
+
+	mock.ExpectExec("SET SESSION unique_checks = 0").WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, SetUniqueChecks(tctx, conn, false))
+	mock.ExpectExec("SET SESSION unique_checks = 1").WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, SetUniqueChecks(tctx, conn, true))
+	mock.ExpectExec("SET SESSION unique_checks = 0").WillReturnError(errors.New("access denied"))
+	err = SetUniqueChecks(tctx, conn, false)
+	require.True(t, terror.ErrDBExecuteFailed.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// disable unique_checks around the callback and restore it.
+	called := false
+	mock.ExpectQuery("SHOW VARIABLES LIKE 'unique_checks'").WillReturnRows(
+		sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("unique_checks", "ON"))
+	mock.ExpectExec("SET SESSION unique_checks = 0").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SET SESSION unique_checks = 1").WillReturnResult(sqlmock.NewResult(0, 0))
+	err = WithUniqueChecks(tctx, conn, false, func() error {
+		called = true
+		return nil
+	})
+	require.NoError(t, err)
+	require.True(t, called)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// restore even if the callback fails.
+	mock.ExpectQuery("SHOW VARIABLES LIKE 'unique_checks'").WillReturnRows(
+		sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("unique_checks", "ON"))
+	mock.ExpectExec("SET SESSION unique_checks = 0").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SET SESSION unique_checks = 1").WillReturnResult(sqlmock.NewResult(0, 0))
+	err = WithUniqueChecks(tctx, conn, false, func() error {
+		return errors.New("callback failed")
+	})
+	require.ErrorContains(t, err, "callback failed")
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// already the expected value, no SET statement.
+	called = false
+	mock.ExpectQuery("SHOW VARIABLES LIKE 'unique_checks'").WillReturnRows(
+		sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("unique_checks", "OFF"))
+	err = WithUniqueChecks(tctx, conn, false, func() error {
+		called = true
+		return nil
+	})
+	require.NoError(t, err)
+	require.True(t, called)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSQLRequirePrimaryKey(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDBTimeout)
+	defer cancel()
+	tctx := tcontext.NewContext(ctx, log.L())
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+	conn, err := baseDB.GetBaseConn(ctx)
+	require.NoError(t, err)
+	defer baseDB.ForceCloseConnWithoutErr(conn)
+
+	mock.ExpectQuery("SHOW VARIABLES LIKE 'sql_require_primary_key'").WillReturnRows(
+		sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("sql_require_primary_key", "ON"))
+	on, err := GetSQLRequirePrimaryKey(tctx, conn)
+	require.NoError(t, err)
+	require.True(t, on)
+
+	// not supported.
+	mock.ExpectQuery("SHOW VARIABLES LIKE 'sql_require_primary_key'").WillReturnRows(
+		sqlmock.NewRows([]string{"Variable_name", "Value"}))
+	on, err = GetSQLRequirePrimaryKey(tctx, conn)
+	require.NoError(t, err)
+	require.False(t, on)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// disable sql_require_primary_key around the callback and restore it.
+	called := false
+	mock.ExpectQuery("SHOW VARIABLES LIKE 'sql_require_primary_key'").WillReturnRows(
+		sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("sql_require_primary_key", "ON"))
+	mock.ExpectExec("SET SESSION sql_require_primary_key = 0").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SET SESSION sql_require_primary_key = 1").WillReturnResult(sqlmock.NewResult(0, 0))
+	err = WithoutSQLRequirePrimaryKey(tctx, conn, func() error {
+		called = true
+		return nil
+	})
+	require.NoError(t, err)
+	require.True(t, called)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// already disabled, no SET statement.
+	called = false
+	mock.ExpectQuery("SHOW VARIABLES LIKE 'sql_require_primary_key'").WillReturnRows(
+		sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("sql_require_primary_key", "OFF"))
+	err = WithoutSQLRequirePrimaryKey(tctx, conn, func() error {
+		called = true
+		return nil
+	})
+	require.NoError(t, err)
+	require.True(t, called)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// fail to disable, the callback is not called.
+	called = false
+	mock.ExpectQuery("SHOW VARIABLES LIKE 'sql_require_primary_key'").WillReturnRows(
+		sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("sql_require_primary_key", "ON"))
+	mock.ExpectExec("SET SESSION sql_require_primary_key = 0").WillReturnError(errors.New("access denied"))
+	err = WithoutSQLRequirePrimaryKey(tctx, conn, func() error {
+		called = true
+		return nil
+	})
+	require.True(t, terror.ErrDBExecuteFailed.Equal(err))
+	require.False(t, called)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetCollationConnection(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDBTimeout)
+	defer cancel()
+	tctx := tcontext.NewContext(ctx, log.L())
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+	conn, err := baseDB.GetBaseConn(ctx)
+	require.NoError(t, err)
+	defer baseDB.ForceCloseConnWithoutErr(conn)
+
+	// the first failure is not cached.
+	mock.ExpectQuery("SHOW VARIABLES LIKE 'collation_connection'").WillReturnError(errors.New("conn refused"))
+	_, err = GetCollationConnection(tctx, conn)
+	require.Error(t, err)
+
+	mock.ExpectQuery("SHOW VARIABLES LIKE 'collation_connection'").WillReturnRows(
+		sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("collation_connection", "utf8mb4_bin"))
+	collation, err := GetCollationConnection(tctx, conn)
+	require.NoError(t, err)
+	require.Equal(t, "utf8mb4_bin", collation)
+
+	// cached, no more query.
+	collation, err = GetCollationConnection(tctx, conn)
+	require.NoError(t, err)
+	require.Equal(t, "utf8mb4_bin", collation)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = GetCollationConnection(tctx, nil)
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+}
+
+func TestGetSQLSafeUpdates(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDBTimeout)
+	defer cancel()
+	tctx := tcontext.NewContext(ctx, log.L())
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+	conn, err := baseDB.GetBaseConn(ctx)
+	require.NoError(t, err)
+	defer baseDB.ForceCloseConnWithoutErr(conn)
+
+	mock.ExpectQuery("SHOW VARIABLES LIKE 'sql_safe_updates'").WillReturnRows(
+		sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("sql_safe_updates", "ON"))
+	on, err := GetSQLSafeUpdates(tctx, conn)
+	require.NoError(t, err)
+	require.True(t, on)
+
+	mock.ExpectQuery("SHOW VARIABLES LIKE 'sql_safe_updates'").WillReturnRows(
+		sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("sql_safe_updates", "invalid"))
+	_, err = GetSQLSafeUpdates(tctx, conn)
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// disable sql_safe_updates around the callback and restore it even if the callback fails.
+	mock.ExpectQuery("SHOW VARIABLES LIKE 'sql_safe_updates'").WillReturnRows(
+		sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("sql_safe_updates", "ON"))
+	mock.ExpectExec("SET SESSION sql_safe_updates = 0").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SET SESSION sql_safe_updates = 1").WillReturnResult(sqlmock.NewResult(0, 0))
+	err = WithoutSQLSafeUpdates(tctx, conn, func() error {
+		return errors.New("callback failed")
+	})
+	require.ErrorContains(t, err, "callback failed")
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// already disabled, no SET statement.
+	called := false
+	mock.ExpectQuery("SHOW VARIABLES LIKE 'sql_safe_updates'").WillReturnRows(
+		sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("sql_safe_updates", "OFF"))
+	err = WithoutSQLSafeUpdates(tctx, conn, func() error {
+		called = true
+		return nil
+	})
+	require.NoError(t, err)
+	require.True(t, called)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTiDBTxnMode(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDBTimeout)
+	defer cancel()
+	tctx := tcontext.NewContext(ctx, log.L())
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+	conn, err := baseDB.GetBaseConn(ctx)
+	require.NoError(t, err)
+	defer baseDB.ForceCloseConnWithoutErr(conn)
+
+	expectSessionVersion := func(version string) {
+		mock.ExpectQuery("SHOW VARIABLES LIKE 'version'").WillReturnRows(
+			sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("version", version))
+	}
+	expectTxnMode := func(mode string) {
+		mock.ExpectQuery("SHOW VARIABLES LIKE 'tidb_txn_mode'").WillReturnRows(
+			sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("tidb_txn_mode", mode))
+	}
+
+	// not TiDB.
+	expectSessionVersion("8.0.32")
+	mode, err := GetTiDBTxnMode(tctx, conn)
+	require.NoError(t, err)
+	require.Equal(t, "", mode)
+
+	expectSessionVersion("5.7.25-TiDB-v7.1.0")
+	expectTxnMode("pessimistic")
+	mode, err = GetTiDBTxnMode(tctx, conn)
+	require.NoError(t, err)
+	require.Equal(t, "pessimistic", mode)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// set tidb_txn_mode around the callback and restore it even if the callback fails.
+	expectSessionVersion("5.7.25-TiDB-v7.1.0")
+	expectTxnMode("pessimistic")
+	mock.ExpectExec("SET SESSION tidb_txn_mode = ?").WithArgs("optimistic").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SET SESSION tidb_txn_mode = ?").WithArgs("pessimistic").WillReturnResult(sqlmock.NewResult(0, 0))
+	err = WithTiDBTxnMode(tctx, conn, "optimistic", func() error {
+		return errors.New("callback failed")
+	})
+	require.ErrorContains(t, err, "callback failed")
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// already in the mode or not TiDB, no SET statement.
+	called := 0
+	fn := func() error {
+		called++
+		return nil
+	}
+	expectSessionVersion("5.7.25-TiDB-v7.1.0")
+	expectTxnMode("optimistic")
+	require.NoError(t, WithTiDBTxnMode(tctx, conn, "optimistic", fn))
+	expectSessionVersion("8.0.32")
+	require.NoError(t, WithTiDBTxnMode(tctx, conn, "optimistic", fn))
+	require.Equal(t, 2, called)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// invalid mode.
+	err = WithTiDBTxnMode(tctx, conn, "unknown", fn)
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+	require.Equal(t, 2, called)
+}
+
+func TestGetTiDBOptimizerSwitches(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDBTimeout)
+	defer cancel()
+	tctx := tcontext.NewContext(ctx, log.L())
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+	conn, err := baseDB.GetBaseConn(ctx)
+	require.NoError(t, err)
+	defer baseDB.ForceCloseConnWithoutErr(conn)
+
+	expectSessionVersion := func(version string) {
+		mock.ExpectQuery("SHOW VARIABLES LIKE 'version'").WillReturnRows(
+			sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("version", version))
+	}
+	optQuery := `SHOW VARIABLES LIKE 'tidb\\_opt\\_%'`
+
+	// not TiDB.
+	expectSessionVersion("8.0.32")
+	switches, err := GetTiDBOptimizerSwitches(tctx, conn)
+	require.NoError(t, err)
+	require.Empty(t, switches)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// only switches of interest are returned.
+	expectSessionVersion("5.7.25-TiDB-v7.1.0")
+	mock.ExpectQuery(optQuery).WillReturnRows(
+		sqlmock.NewRows([]string{"Variable_name", "Value"}).
+			AddRow("tidb_opt_agg_push_down", "OFF").
+			AddRow("TIDB_OPT_PREFER_RANGE_SCAN", "ON").
+			AddRow("tidb_opt_cpu_factor", "3"))
+	switches, err = GetTiDBOptimizerSwitches(tctx, conn)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"tidb_opt_agg_push_down":     "OFF",
+		"tidb_opt_prefer_range_scan": "ON",
+	}, switches)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// query failed.
+	expectSessionVersion("5.7.25-TiDB-v7.1.0")
+	mock.ExpectQuery(optQuery).WillReturnError(errors.New("connection refused"))
+	_, err = GetTiDBOptimizerSwitches(tctx, conn)
+	require.ErrorContains(t, err, "connection refused")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIsMariaDB(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, IsMariaDB("5.5.50-MariaDB-1~wheezy"))
+	require.False(t, IsMariaDB("5.7.19-17-log"))
+}
+
+func TestDetectVendor(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		version        string
+		versionComment string
+		vendor         string
+	}{
+		{"8.0.18", "PolarDB MySQL Server", VendorPolarDB},
+		{"5.6.29-TDDL-5.4.12-16327949", "", VendorPolarDB},
+		{"8.0.22-txsql", "Source distribution", VendorTDSQL},
+		{"5.7.17-tdsql-log", "Tencent TDSQL", VendorTDSQL},
+		{"5.7.12-log", "mysql_aurora.2.11.2", VendorAurora},
+		{"8.0.mysql_aurora.3.02.0", "Source distribution", VendorAurora},
+		{"5.7.40-43-log", "Percona Server (GPL), Release 43, Revision ab4d0d7", VendorPercona},
+		{"8.0.32", "MySQL Community Server - GPL", ""},
+		{"10.6.12-MariaDB-log", "mariadb.org binary distribution", ""},
+		{"", "", ""},
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.vendor, DetectVendor(tc.version, tc.versionComment), "%s, %s", tc.version, tc.versionComment)
+	}
+}
+
+func TestGetCreateTableSQL(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+
+	createSQL := "CREATE TABLE `t1` (\n  `id` bigint(20) NOT NULL,\n  `c1` varchar(20)  DEFAULT NULL,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB DEFAULT CHARSET=latin1"
+	mock.ExpectQuery("SHOW CREATE TABLE `db1`.`t1`").WillReturnRows(
+		sqlmock.NewRows([]string{"Table", "Create Table"}).AddRow("t1", createSQL))
+	got, err := GetCreateTableSQL(ctx, baseDB, "db1", "t1")
+	require.NoError(t, err)
+	// newlines and spacing are preserved.
+	require.Equal(t, createSQL, got)
+
+	mock.ExpectQuery("SHOW CREATE TABLE `db1`.`t2`").WillReturnRows(
+		sqlmock.NewRows([]string{"Table", "Create Table"}))
+	_, err = GetCreateTableSQL(ctx, baseDB, "db1", "t2")
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+
+	mock.ExpectQuery("SHOW CREATE TABLE `db1`.`t3`").WillReturnError(errors.New("conn refused"))
+	_, err = GetCreateTableSQL(ctx, baseDB, "db1", "t3")
+	require.True(t, terror.ErrDBDriverError.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateTableSQLToOneRow(t *testing.T) {
+	t.Parallel()
+
+	input := "CREATE TABLE `t1` (\n  `id` bigint(20) NOT NULL,\n  `c1` varchar(20) DEFAULT NULL,\n  `c2` varchar(20) DEFAULT NULL,\n  PRIMARY KEY (`id`) /*T![clustered_index] NONCLUSTERED */\n) ENGINE=InnoDB DEFAULT CHARSET=latin1 COLLATE=latin1_bin"
+	expected := "CREATE TABLE `t1` ( `id` bigint(20) NOT NULL, `c1` varchar(20) DEFAULT NULL, `c2` varchar(20) DEFAULT NULL, PRIMARY KEY (`id`) /*T![clustered_index] NONCLUSTERED */) ENGINE=InnoDB DEFAULT CHARSET=latin1 COLLATE=latin1_bin"
+	require.Equal(t, expected, CreateTableSQLToOneRow(input))
+}
+
+func TestGetSlaveServerID(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	cases := []struct {
+		rows    *sqlmock.Rows
+		results map[uint32]struct{}
+	}{
+		// For MySQL
+		{
+			sqlmock.NewRows([]string{"Server_id", "Host", "Port", "Master_id", "Slave_UUID"}).
+				AddRow(192168010, "iconnect2", 3306, 192168011, "14cb6624-7f93-11e0-b2c0-c80aa9429562").
+				AddRow(1921680101, "athena", 3306, 192168011, "07af4990-f41f-11df-a566-7ac56fdaf645"),
+			map[uint32]struct{}{
+				192168010: {}, 1921680101: {},
+			},
+		},
+		// For MariaDB
+		{
+			sqlmock.NewRows([]string{"Server_id", "Host", "Port", "Master_id"}).
+				AddRow(192168010, "iconnect2", 3306, 192168011).
+				AddRow(1921680101, "athena", 3306, 192168011),
+			map[uint32]struct{}{
+				192168010: {}, 1921680101: {},
+			},
+		},
+		// For MariaDB, with Server_id greater than 2^31, to test uint conversion
+		{
+			sqlmock.NewRows([]string{"Server_id", "Host", "Port", "Master_id"}).
+				AddRow(2147483649, "iconnect2", 3306, 192168011).
+				AddRow(2147483650, "athena", 3306, 192168011),
+			map[uint32]struct{}{
+				2147483649: {}, 2147483650: {},
+			},
+		},
+	}
+
+	tctx := tcontext.NewContext(context.Background(), log.L())
+	for _, ca := range cases {
+		mock.ExpectQuery("SHOW SLAVE HOSTS").WillReturnRows(ca.rows)
+		results, err2 := GetSlaveServerID(tctx, NewBaseDBForTest(db))
+		require.NoError(t, err2)
+		require.Equal(t, ca.results, results)
+	}
+}
+
+func TestGetRelayLogSpace(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	tctx := tcontext.NewContext(context.Background(), log.L())
+
+	cases := []struct {
+		rows   *sqlmock.Rows
+		space  uint64
+		errMsg string
+	}{
+		// not a replica
+		{
+			sqlmock.NewRows([]string{"Slave_IO_State", "Master_Host", "Relay_Log_Space"}),
+			0,
+			"",
+		},
+		{
+			sqlmock.NewRows([]string{"Slave_IO_State", "Master_Host", "Relay_Log_Space"}).
+				AddRow("Waiting for master to send event", "127.0.0.1", "1073741824"),
+			1073741824,
+			"",
+		},
+		// multi-source replication
+		{
+			sqlmock.NewRows([]string{"Slave_IO_State", "Master_Host", "Relay_Log_Space"}).
+				AddRow("Waiting for master to send event", "127.0.0.1", "1024").
+				AddRow("Waiting for master to send event", "127.0.0.2", "2048"),
+			3072,
+			"",
+		},
+		{
+			sqlmock.NewRows([]string{"Slave_IO_State", "Master_Host", "Relay_Log_Space"}).
+				AddRow("Waiting for master to send event", "127.0.0.1", "abc"),
+			0,
+			"invalid `Relay_Log_Space` value 'abc'",
+		},
+	}
+
+	for _, ca := range cases {
+		mock.ExpectQuery("SHOW SLAVE STATUS").WillReturnRows(ca.rows)
+		space, err2 := GetRelayLogSpace(tctx, NewBaseDBForTest(db))
+		if ca.errMsg != "" {
+			require.ErrorContains(t, err2, ca.errMsg)
+		} else {
+			require.NoError(t, err2)
+			require.Equal(t, ca.space, space)
+		}
+	}
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetReplicaLastError(t *testing.T) {
+	t.Parallel()
+
+	tctx := tcontext.NewContext(context.Background(), log.L())
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	columns := []string{"Slave_IO_State", "Last_IO_Error", "Last_SQL_Error"}
+	cases := []struct {
+		rows   *sqlmock.Rows
+		ioErr  string
+		sqlErr string
+	}{
+		// not a replica
+		{
+			sqlmock.NewRows(columns),
+			"",
+			"",
+		},
+		// replicating without errors
+		{
+			sqlmock.NewRows(columns).AddRow("Waiting for master to send event", "", ""),
+			"",
+			"",
+		},
+		{
+			sqlmock.NewRows(columns).AddRow("", "", "Error 'Duplicate entry '1' for key 'PRIMARY'' on query."),
+			"",
+			"Error 'Duplicate entry '1' for key 'PRIMARY'' on query.",
+		},
+		// multi-source replication
+		{
+			sqlmock.NewRows(columns).
+				AddRow("", "error connecting to master", "").
+				AddRow("", "", "Table 'db.t1' doesn't exist").
+				AddRow("", "", "Table 'db.t2' doesn't exist"),
+			"error connecting to master",
+			"Table 'db.t1' doesn't exist; Table 'db.t2' doesn't exist",
+		},
+	}
+
+	for _, ca := range cases {
+		mock.ExpectQuery("SHOW SLAVE STATUS").WillReturnRows(ca.rows)
+		ioErr, sqlErr, err2 := GetReplicaLastError(tctx, NewBaseDBForTest(db))
+		require.NoError(t, err2)
+		require.Equal(t, ca.ioErr, ioErr)
+		require.Equal(t, ca.sqlErr, sqlErr)
+	}
+
+	mock.ExpectQuery("SHOW SLAVE STATUS").WillReturnError(errors.New("access denied"))
+	_, _, err = GetReplicaLastError(tctx, NewBaseDBForTest(db))
+	require.True(t, terror.ErrDBDriverError.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFetchAllDoTables(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	// empty filter, exclude system schemas
+	ba, err := filter.New(false, nil)
+	require.NoError(t, err)
+
+	// no schemas need to do.
+	mock.ExpectQuery(`SHOW DATABASES`).WillReturnRows(sqlmock.NewRows([]string{"Database"}))
+	got, err := FetchAllDoTables(context.Background(), NewBaseDBForTest(db), ba, 0)
+	require.NoError(t, err)
+	require.Len(t, got, 0)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// only system schemas exist, still no need to do.
+	schemas := []string{"information_schema", "mysql", "performance_schema", "sys", filter.DMHeartbeatSchema}
+	rows := sqlmock.NewRows([]string{"Database"})
+	addRowsForSchemas(rows, schemas)
+	mock.ExpectQuery(`SHOW DATABASES`).WillReturnRows(rows)
+	got, err = FetchAllDoTables(context.Background(), NewBaseDBForTest(db), ba, 0)
+	require.NoError(t, err)
+	require.Len(t, got, 0)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// schemas without tables in them.
+	doSchema := "test_db"
+	schemas = []string{"information_schema", "mysql", "performance_schema", "sys", filter.DMHeartbeatSchema, doSchema}
+	rows = sqlmock.NewRows([]string{"Database"})
+	addRowsForSchemas(rows, schemas)
+	mock.ExpectQuery(`SHOW DATABASES`).WillReturnRows(rows)
+	mock.ExpectQuery(fmt.Sprintf("SHOW FULL TABLES IN `%s` WHERE Table_Type != 'VIEW'", doSchema)).WillReturnRows(
+		sqlmock.NewRows([]string{fmt.Sprintf("Tables_in_%s", doSchema), "Table_type"}))
+	got, err = FetchAllDoTables(context.Background(), NewBaseDBForTest(db), ba, 0)
+	require.NoError(t, err)
+	require.Len(t, got, 0)
 	require.NoError(t, mock.ExpectationsWereMet())
 
 	// do all tables under the schema.
@@ -525,7 +2292,7 @@ func TestFetchAllDoTables(t *testing.T) {
 	rows = sqlmock.NewRows([]string{fmt.Sprintf("Tables_in_%s", doSchema), "Table_type"})
 	addRowsForTables(rows, tables)
 	mock.ExpectQuery(fmt.Sprintf("SHOW FULL TABLES IN `%s` WHERE Table_Type != 'VIEW'", doSchema)).WillReturnRows(rows)
-	got, err = FetchAllDoTables(context.Background(), NewBaseDBForTest(db), ba)
+	got, err = FetchAllDoTables(context.Background(), NewBaseDBForTest(db), ba, 0)
 	require.NoError(t, err)
 	require.Len(t, got, 1)
 	require.Equal(t, tables, got[doSchema])
@@ -547,13 +2314,67 @@ func TestFetchAllDoTables(t *testing.T) {
 	rows = sqlmock.NewRows([]string{fmt.Sprintf("Tables_in_%s", doSchema), "Table_type"})
 	addRowsForTables(rows, tables)
 	mock.ExpectQuery(fmt.Sprintf("SHOW FULL TABLES IN `%s` WHERE Table_Type != 'VIEW'", doSchema)).WillReturnRows(rows)
-	got, err = FetchAllDoTables(context.Background(), NewBaseDBForTest(db), ba)
+	got, err = FetchAllDoTables(context.Background(), NewBaseDBForTest(db), ba, 0)
 	require.NoError(t, err)
 	require.Len(t, got, 1)
 	require.Equal(t, []string{"tbl1", "tbl2"}, got[doSchema])
 	require.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestFetchAllDoTablesWithLimit(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	ba, err := filter.New(false, nil)
+	require.NoError(t, err)
+
+	// synthetic large table set spread over several schemas.
+	schemas := []string{"db1", "db2", "db3"}
+	tables := make([][]string, 0, len(schemas))
+	for i := range schemas {
+		names := make([]string, 0, 1000)
+		for j := 0; j < 1000; j++ {
+			names = append(names, fmt.Sprintf("tbl_%d_%d", i, j))
+		}
+		tables = append(tables, names)
+	}
+	expectQueries := func(count int) {
+		rows := sqlmock.NewRows([]string{"Database"})
+		addRowsForSchemas(rows, schemas)
+		mock.ExpectQuery(`SHOW DATABASES`).WillReturnRows(rows)
+		for i := 0; i < count; i++ {
+			rows = sqlmock.NewRows([]string{fmt.Sprintf("Tables_in_%s", schemas[i]), "Table_type"})
+			addRowsForTables(rows, tables[i])
+			mock.ExpectQuery(fmt.Sprintf("SHOW FULL TABLES IN `%s` WHERE Table_Type != 'VIEW'", schemas[i])).WillReturnRows(rows)
+		}
+	}
+
+	// exceed the limit in the second schema, the third schema is not fetched.
+	expectQueries(2)
+	got, err := FetchAllDoTables(context.Background(), NewBaseDBForTest(db), ba, 1500)
+	require.True(t, terror.ErrTooManyDoTables.Equal(err))
+	require.ErrorContains(t, err, "fetched at least 2000 tables, limit 1500")
+	require.Nil(t, got)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// exactly reach the limit.
+	expectQueries(3)
+	got, err = FetchAllDoTables(context.Background(), NewBaseDBForTest(db), ba, 3000)
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// no limit.
+	expectQueries(3)
+	got, err = FetchAllDoTables(context.Background(), NewBaseDBForTest(db), ba, 0)
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+	require.Equal(t, tables[2], got["db3"])
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestFetchTargetDoTables(t *testing.T) {
 	t.Parallel()
 
@@ -614,6 +2435,206 @@ func TestFetchTargetDoTables(t *testing.T) {
 	}, tablesMap)
 	require.Len(t, extendedCols, 0)
 	require.NoError(t, mock.ExpectationsWereMet())
+
+	// tbl2 matches more than one rule, the error should contain the table name.
+	r, err = regexprrouter.NewRegExprRouter(false, []*router.TableRule{
+		{SchemaPattern: "shard*", TablePattern: "tbl*", TargetSchema: "shard", TargetTable: "tbl"},
+		{SchemaPattern: "shard*", TablePattern: "tbl2", TargetSchema: "shard", TargetTable: "tbl2"},
+	})
+	require.NoError(t, err)
+
+	rows = sqlmock.NewRows([]string{"Database"})
+	addRowsForSchemas(rows, schemas)
+	mock.ExpectQuery(`SHOW DATABASES`).WillReturnRows(rows)
+	for schema, tables := range tablesM {
+		rows = sqlmock.NewRows([]string{fmt.Sprintf("Tables_in_%s", schema), "Table_type"})
+		addRowsForTables(rows, tables)
+		mock.ExpectQuery(fmt.Sprintf("SHOW FULL TABLES IN `%s` WHERE Table_Type != 'VIEW'", schema)).WillReturnRows(rows)
+	}
+
+	_, _, err = FetchTargetDoTables(context.Background(), "", NewBaseDBForTest(db), ba, r)
+	require.True(t, terror.ErrGenTableRouter.Equal(err))
+	require.ErrorContains(t, err, "route table `shard1`.`tbl2`")
+	require.ErrorContains(t, err, "matches more than one rule")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func expectFetchDoTablesQueries(mock sqlmock.Sqlmock, schemas []string, tables [][]string) {
+	rows := sqlmock.NewRows([]string{"Database"})
+	addRowsForSchemas(rows, schemas)
+	mock.ExpectQuery(`SHOW DATABASES`).WillReturnRows(rows)
+	for i, schema := range schemas {
+		if filter.IsSystemSchema(schema) {
+			continue
+		}
+		rows = sqlmock.NewRows([]string{fmt.Sprintf("Tables_in_%s", schema), "Table_type"})
+		addRowsForTables(rows, tables[i])
+		mock.ExpectQuery(fmt.Sprintf("SHOW FULL TABLES IN `%s` WHERE Table_Type != 'VIEW'", schema)).WillReturnRows(rows)
+	}
+}
+
+func TestFetchTargetDoTablesWithExtendedColumns(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	ba, err := filter.New(false, nil)
+	require.NoError(t, err)
+	// two source tables are routed to the same target with different extended columns.
+	r, err := regexprrouter.NewRegExprRouter(false, []*router.TableRule{
+		{
+			SchemaPattern: "shard*", TablePattern: "tbl1", TargetSchema: "shard", TargetTable: "tbl",
+			TableExtractor: &router.TableExtractor{TargetColumn: "c_table", TableRegexp: "tbl(.*)"},
+		},
+		{
+			SchemaPattern: "shard*", TablePattern: "tbl2", TargetSchema: "shard", TargetTable: "tbl",
+			SchemaExtractor: &router.SchemaExtractor{TargetColumn: "c_schema", SchemaRegexp: "shard(.*)"},
+			SourceExtractor: &router.SourceExtractor{TargetColumn: "c_source", SourceRegexp: "(.*)"},
+		},
+	})
+	require.NoError(t, err)
+
+	rows := sqlmock.NewRows([]string{"Database"})
+	addRowsForSchemas(rows, []string{"shard1"})
+	mock.ExpectQuery(`SHOW DATABASES`).WillReturnRows(rows)
+	rows = sqlmock.NewRows([]string{"Tables_in_shard1", "Table_type"})
+	addRowsForTables(rows, []string{"tbl1", "tbl2", "tbl3"})
+	mock.ExpectQuery("SHOW FULL TABLES IN `shard1` WHERE Table_Type != 'VIEW'").WillReturnRows(rows)
+
+	tablesMap, extendedCols, err := FetchTargetDoTablesWithExtendedColumns(
+		context.Background(), "source1", NewBaseDBForTest(db), ba, r)
+	require.NoError(t, err)
+	require.Equal(t, map[filter.Table][]filter.Table{
+		{Schema: "shard", Name: "tbl"}: {
+			{Schema: "shard1", Name: "tbl1"},
+			{Schema: "shard1", Name: "tbl2"},
+		},
+		{Schema: "shard1", Name: "tbl3"}: {{Schema: "shard1", Name: "tbl3"}},
+	}, tablesMap)
+	require.Equal(t, map[filter.Table]ExtendedColumns{
+		{Schema: "shard1", Name: "tbl1"}: {
+			Columns: []string{"c_table"},
+			Values:  []string{"1"},
+		},
+		{Schema: "shard1", Name: "tbl2"}: {
+			Columns: []string{"c_schema", "c_source"},
+			Values:  []string{"1", "source1"},
+		},
+	}, extendedCols)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFetchTargetDoTablesSinglePass(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	ba, err := filter.New(false, &filter.Rules{
+		IgnoreTables: []*filter.Table{{Schema: "shard2", Name: "tbl_ignore"}},
+	})
+	require.NoError(t, err)
+	r, err := regexprrouter.NewRegExprRouter(false, []*router.TableRule{
+		{SchemaPattern: "shard*", TablePattern: "tbl*", TargetSchema: "shard", TargetTable: "tbl"},
+		{SchemaPattern: "other", TargetSchema: "other_target"},
+	})
+	require.NoError(t, err)
+
+	schemas := []string{"mysql", "shard1", "shard2", "other"}
+	tables := [][]string{
+		nil,
+		{"tbl1", "tbl2"},
+		{"tbl1", "tbl_ignore"},
+		{"t1"},
+	}
+
+	// both variants should return the same result.
+	expectFetchDoTablesQueries(mock, schemas, tables)
+	expectedMapper, expectedCols, err := FetchTargetDoTables(context.Background(), "", NewBaseDBForTest(db), ba, r)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	expectFetchDoTablesQueries(mock, schemas, tables)
+	tableMapper, extendedCols, err := FetchTargetDoTablesSinglePass(context.Background(), "", NewBaseDBForTest(db), ba, r)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	// the two-pass variant iterates over a map, so the order of source tables may differ.
+	require.Len(t, tableMapper, len(expectedMapper))
+	for target, sourceTables := range expectedMapper {
+		require.ElementsMatch(t, sourceTables, tableMapper[target])
+	}
+	require.Equal(t, expectedCols, extendedCols)
+	require.Equal(t, map[filter.Table][]filter.Table{
+		{Schema: "shard", Name: "tbl"}: {
+			{Schema: "shard1", Name: "tbl1"},
+			{Schema: "shard1", Name: "tbl2"},
+			{Schema: "shard2", Name: "tbl1"},
+		},
+		{Schema: "other_target", Name: "t1"}: {{Schema: "other", Name: "t1"}},
+	}, tableMapper)
+
+	// routing error.
+	r, err = regexprrouter.NewRegExprRouter(false, []*router.TableRule{
+		{SchemaPattern: "shard*", TablePattern: "tbl*", TargetSchema: "shard", TargetTable: "tbl"},
+		{SchemaPattern: "shard*", TablePattern: "tbl2", TargetSchema: "shard", TargetTable: "tbl2"},
+	})
+	require.NoError(t, err)
+	// it returns after routing the tables of shard1.
+	expectFetchDoTablesQueries(mock, schemas[:2], tables[:2])
+	_, _, err = FetchTargetDoTablesSinglePass(context.Background(), "", NewBaseDBForTest(db), ba, r)
+	require.True(t, terror.ErrGenTableRouter.Equal(err))
+	require.ErrorContains(t, err, "route table `shard1`.`tbl2`")
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// fail to fetch tables.
+	mock.ExpectQuery(`SHOW DATABASES`).WillReturnError(errors.New("fetch failed"))
+	_, _, err = FetchTargetDoTablesSinglePass(context.Background(), "", NewBaseDBForTest(db), ba, r)
+	require.ErrorContains(t, err, "fetch failed")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func BenchmarkFetchTargetDoTables(b *testing.B) {
+	ba, err := filter.New(false, nil)
+	require.NoError(b, err)
+	r, err := regexprrouter.NewRegExprRouter(false, []*router.TableRule{
+		{SchemaPattern: "db*", TablePattern: "tbl*", TargetSchema: "db", TargetTable: "tbl"},
+	})
+	require.NoError(b, err)
+
+	schemas := make([]string, 0, 10)
+	tables := make([][]string, 0, 10)
+	for i := 0; i < 10; i++ {
+		schemas = append(schemas, fmt.Sprintf("db%d", i))
+		names := make([]string, 0, 1000)
+		for j := 0; j < 1000; j++ {
+			names = append(names, fmt.Sprintf("tbl_%d", j))
+		}
+		tables = append(tables, names)
+	}
+
+	fetchFuncs := map[string]func(
+		context.Context, string, *BaseDB, *filter.Filter, *regexprrouter.RouteTable,
+	) (map[filter.Table][]filter.Table, map[filter.Table][]string, error){
+		"two-pass":    FetchTargetDoTables,
+		"single-pass": FetchTargetDoTablesSinglePass,
+	}
+	for name, fetch := range fetchFuncs {
+		b.Run(name, func(b *testing.B) {
+			db, mock, err := sqlmock.New()
+			require.NoError(b, err)
+			baseDB := NewBaseDBForTest(db)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				expectFetchDoTablesQueries(mock, schemas, tables)
+				b.StartTimer()
+				_, _, err = fetch(context.Background(), "", baseDB, ba, r)
+				require.NoError(b, err)
+			}
+		})
+	}
 }
 
 func addRowsForSchemas(rows *sqlmock.Rows, schemas []string) {
@@ -627,3 +2648,309 @@ func addRowsForTables(rows *sqlmock.Rows, tables []string) {
 		rows.AddRow(table, "BASE TABLE")
 	}
 }
+
+func TestIsTemporaryTable(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+
+	query := "SELECT TABLE_TYPE FROM information_schema.TABLES WHERE TABLE_SCHEMA = \\? AND TABLE_NAME = \\?"
+	cases := []struct {
+		tableType string
+		temporary bool
+	}{
+		{"BASE TABLE", false},
+		{"VIEW", false},
+		{"TEMPORARY", true},
+		{"LOCAL TEMPORARY", true},
+		{"global temporary", true},
+	}
+	for _, cs := range cases {
+		mock.ExpectQuery(query).WithArgs("db1", "tbl1").WillReturnRows(
+			sqlmock.NewRows([]string{"TABLE_TYPE"}).AddRow(cs.tableType))
+		temporary, err2 := IsTemporaryTable(context.Background(), baseDB, "db1", "tbl1")
+		require.NoError(t, err2)
+		require.Equal(t, cs.temporary, temporary, cs.tableType)
+	}
+
+	// table not found.
+	mock.ExpectQuery(query).WithArgs("db1", "tbl2").WillReturnRows(sqlmock.NewRows([]string{"TABLE_TYPE"}))
+	temporary, err := IsTemporaryTable(context.Background(), baseDB, "db1", "tbl2")
+	require.NoError(t, err)
+	require.False(t, temporary)
+
+	mock.ExpectQuery(query).WithArgs("db1", "tbl3").WillReturnError(errors.New("query failed"))
+	_, err = IsTemporaryTable(context.Background(), baseDB, "db1", "tbl3")
+	require.True(t, terror.ErrDBDriverError.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTableRowEstimate(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+
+	query := "SELECT TABLE_ROWS FROM information_schema.TABLES WHERE TABLE_SCHEMA = \\? AND TABLE_NAME = \\?"
+	mock.ExpectQuery(query).WithArgs("db1", "tbl1").WillReturnRows(
+		sqlmock.NewRows([]string{"TABLE_ROWS"}).AddRow(123456))
+	rows, err := GetTableRowEstimate(context.Background(), baseDB, "db1", "tbl1")
+	require.NoError(t, err)
+	require.Equal(t, uint64(123456), rows)
+
+	// no estimate, like views.
+	mock.ExpectQuery(query).WithArgs("db1", "view1").WillReturnRows(
+		sqlmock.NewRows([]string{"TABLE_ROWS"}).AddRow(nil))
+	rows, err = GetTableRowEstimate(context.Background(), baseDB, "db1", "view1")
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), rows)
+
+	// table not found.
+	mock.ExpectQuery(query).WithArgs("db1", "tbl2").WillReturnRows(sqlmock.NewRows([]string{"TABLE_ROWS"}))
+	_, err = GetTableRowEstimate(context.Background(), baseDB, "db1", "tbl2")
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+
+	mock.ExpectQuery(query).WithArgs("db1", "tbl3").WillReturnError(errors.New("query failed"))
+	_, err = GetTableRowEstimate(context.Background(), baseDB, "db1", "tbl3")
+	require.True(t, terror.ErrDBDriverError.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTableAutoIncrement(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+
+	query := "SELECT AUTO_INCREMENT FROM information_schema.TABLES WHERE TABLE_SCHEMA = \\? AND TABLE_NAME = \\?"
+	mock.ExpectQuery(query).WithArgs("db1", "tbl1").WillReturnRows(
+		sqlmock.NewRows([]string{"AUTO_INCREMENT"}).AddRow(1001))
+	autoIncrement, err := GetTableAutoIncrement(context.Background(), baseDB, "db1", "tbl1")
+	require.NoError(t, err)
+	require.Equal(t, uint64(1001), autoIncrement)
+
+	// no AUTO_INCREMENT column.
+	mock.ExpectQuery(query).WithArgs("db1", "tbl2").WillReturnRows(
+		sqlmock.NewRows([]string{"AUTO_INCREMENT"}).AddRow(nil))
+	autoIncrement, err = GetTableAutoIncrement(context.Background(), baseDB, "db1", "tbl2")
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), autoIncrement)
+
+	// table not found.
+	mock.ExpectQuery(query).WithArgs("db1", "tbl3").WillReturnRows(sqlmock.NewRows([]string{"AUTO_INCREMENT"}))
+	_, err = GetTableAutoIncrement(context.Background(), baseDB, "db1", "tbl3")
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+
+	mock.ExpectQuery(query).WithArgs("db1", "tbl4").WillReturnError(errors.New("query failed"))
+	_, err = GetTableAutoIncrement(context.Background(), baseDB, "db1", "tbl4")
+	require.True(t, terror.ErrDBDriverError.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUniqueKeys(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+
+	query := "SELECT INDEX_NAME, COLUMN_NAME FROM information_schema.STATISTICS " +
+		"WHERE TABLE_SCHEMA = \\? AND TABLE_NAME = \\? AND NON_UNIQUE = 0 ORDER BY INDEX_NAME, SEQ_IN_INDEX"
+	columns := []string{"INDEX_NAME", "COLUMN_NAME"}
+
+	// multiple unique keys and composite keys.
+	mock.ExpectQuery(query).WithArgs("db1", "tbl1").WillReturnRows(
+		sqlmock.NewRows(columns).
+			AddRow("PRIMARY", "id").
+			AddRow("uk_email", "email").
+			AddRow("uk_name", "first_name").
+			AddRow("uk_name", "last_name").
+			AddRow("uk_expr", "a").
+			AddRow("uk_expr", nil))
+	uniqueKeys, err := GetUniqueKeys(context.Background(), baseDB, "db1", "tbl1")
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{
+		"PRIMARY":  {"id"},
+		"uk_email": {"email"},
+		"uk_name":  {"first_name", "last_name"},
+	}, uniqueKeys)
+
+	// no unique keys.
+	mock.ExpectQuery(query).WithArgs("db1", "tbl2").WillReturnRows(sqlmock.NewRows(columns))
+	uniqueKeys, err = GetUniqueKeys(context.Background(), baseDB, "db1", "tbl2")
+	require.NoError(t, err)
+	require.Len(t, uniqueKeys, 0)
+
+	mock.ExpectQuery(query).WithArgs("db1", "tbl3").WillReturnError(errors.New("query failed"))
+	_, err = GetUniqueKeys(context.Background(), baseDB, "db1", "tbl3")
+	require.True(t, terror.ErrDBDriverError.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAutoIncrementColumns(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+
+	query := "SELECT COLUMN_NAME, EXTRA FROM information_schema.COLUMNS " +
+		"WHERE TABLE_SCHEMA = \\? AND TABLE_NAME = \\? ORDER BY ORDINAL_POSITION"
+	columns := []string{"COLUMN_NAME", "EXTRA"}
+
+	mock.ExpectQuery(query).WithArgs("db1", "tbl1").WillReturnRows(
+		sqlmock.NewRows(columns).
+			AddRow("id", "auto_increment").
+			AddRow("name", "").
+			AddRow("updated_at", "DEFAULT_GENERATED on update CURRENT_TIMESTAMP").
+			AddRow("seq", "AUTO_INCREMENT").
+			AddRow("note", nil))
+	autoIncColumns, err := GetAutoIncrementColumns(context.Background(), baseDB, "db1", "tbl1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"id", "seq"}, autoIncColumns)
+
+	// no auto increment columns.
+	mock.ExpectQuery(query).WithArgs("db1", "tbl2").WillReturnRows(
+		sqlmock.NewRows(columns).AddRow("id", ""))
+	autoIncColumns, err = GetAutoIncrementColumns(context.Background(), baseDB, "db1", "tbl2")
+	require.NoError(t, err)
+	require.Len(t, autoIncColumns, 0)
+
+	mock.ExpectQuery(query).WithArgs("db1", "tbl3").WillReturnError(errors.New("query failed"))
+	_, err = GetAutoIncrementColumns(context.Background(), baseDB, "db1", "tbl3")
+	require.True(t, terror.ErrDBDriverError.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetClusteredPKColumns(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+
+	pkTypeQuery := "SELECT TIDB_PK_TYPE FROM information_schema.TABLES WHERE TABLE_SCHEMA = \\? AND TABLE_NAME = \\?"
+	pkQuery := "SELECT COLUMN_NAME FROM information_schema.STATISTICS " +
+		"WHERE TABLE_SCHEMA = \\? AND TABLE_NAME = \\? AND INDEX_NAME = 'PRIMARY' ORDER BY SEQ_IN_INDEX"
+
+	// clustered primary key.
+	mock.ExpectQuery(pkTypeQuery).WithArgs("db1", "tbl1").WillReturnRows(
+		sqlmock.NewRows([]string{"TIDB_PK_TYPE"}).AddRow("CLUSTERED"))
+	mock.ExpectQuery(pkQuery).WithArgs("db1", "tbl1").WillReturnRows(
+		sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("a").AddRow("b"))
+	columns, err := GetClusteredPKColumns(context.Background(), baseDB, "db1", "tbl1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, columns)
+
+	// non-clustered primary key.
+	mock.ExpectQuery(pkTypeQuery).WithArgs("db1", "tbl2").WillReturnRows(
+		sqlmock.NewRows([]string{"TIDB_PK_TYPE"}).AddRow("NONCLUSTERED"))
+	columns, err = GetClusteredPKColumns(context.Background(), baseDB, "db1", "tbl2")
+	require.NoError(t, err)
+	require.Nil(t, columns)
+
+	// MySQL doesn't have TIDB_PK_TYPE.
+	mock.ExpectQuery(pkTypeQuery).WithArgs("db1", "tbl3").WillReturnError(
+		newMysqlErr(tmysql.ErrBadField, "Unknown column 'TIDB_PK_TYPE' in 'field list'"))
+	columns, err = GetClusteredPKColumns(context.Background(), baseDB, "db1", "tbl3")
+	require.NoError(t, err)
+	require.Nil(t, columns)
+
+	mock.ExpectQuery(pkTypeQuery).WithArgs("db1", "tbl4").WillReturnRows(
+		sqlmock.NewRows([]string{"TIDB_PK_TYPE"}))
+	_, err = GetClusteredPKColumns(context.Background(), baseDB, "db1", "tbl4")
+	require.True(t, terror.ErrDBUnExpect.Equal(err))
+
+	mock.ExpectQuery(pkTypeQuery).WithArgs("db1", "tbl5").WillReturnError(errors.New("query failed"))
+	_, err = GetClusteredPKColumns(context.Background(), baseDB, "db1", "tbl5")
+	require.True(t, terror.ErrDBDriverError.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFetchAllDoTablesSkipTemporary(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+
+	ba, err := filter.New(false, nil)
+	require.NoError(t, err)
+	schemas := []string{"db1", "db2"}
+	tables := [][]string{{"tbl1", "tmp1", "tbl2"}, {"tbl3"}}
+	query := "SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = \\? AND UPPER\\(TABLE_TYPE\\) LIKE '%TEMPORARY%'"
+
+	// temporary tables are kept by FetchAllDoTables.
+	expectFetchDoTablesQueries(mock, schemas, tables)
+	got, err := FetchAllDoTables(context.Background(), baseDB, ba, 0)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{"db1": tables[0], "db2": tables[1]}, got)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// temporary tables are skipped, and the limit of tables doesn't count them.
+	rows := sqlmock.NewRows([]string{"Database"})
+	addRowsForSchemas(rows, schemas)
+	mock.ExpectQuery(`SHOW DATABASES`).WillReturnRows(rows)
+	for i, schema := range schemas {
+		rows = sqlmock.NewRows([]string{fmt.Sprintf("Tables_in_%s", schema), "Table_type"})
+		addRowsForTables(rows, tables[i])
+		mock.ExpectQuery(fmt.Sprintf("SHOW FULL TABLES IN `%s` WHERE Table_Type != 'VIEW'", schema)).WillReturnRows(rows)
+		temporaryRows := sqlmock.NewRows([]string{"TABLE_NAME"})
+		if schema == "db1" {
+			temporaryRows.AddRow("tmp1")
+		}
+		mock.ExpectQuery(query).WithArgs(schema).WillReturnRows(temporaryRows)
+	}
+	got, err = FetchAllDoTablesSkipTemporary(context.Background(), baseDB, ba, 3)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{"db1": {"tbl1", "tbl2"}, "db2": {"tbl3"}}, got)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// failed to get temporary tables.
+	rows = sqlmock.NewRows([]string{"Database"})
+	addRowsForSchemas(rows, schemas[:1])
+	mock.ExpectQuery(`SHOW DATABASES`).WillReturnRows(rows)
+	rows = sqlmock.NewRows([]string{"Tables_in_db1", "Table_type"})
+	addRowsForTables(rows, tables[0])
+	mock.ExpectQuery("SHOW FULL TABLES IN `db1` WHERE Table_Type != 'VIEW'").WillReturnRows(rows)
+	mock.ExpectQuery(query).WithArgs("db1").WillReturnError(errors.New("query failed"))
+	_, err = FetchAllDoTablesSkipTemporary(context.Background(), baseDB, ba, 0)
+	require.True(t, terror.ErrDBDriverError.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTriggers(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+
+	query := "SELECT TRIGGER_NAME, EVENT_OBJECT_TABLE, EVENT_MANIPULATION, ACTION_TIMING FROM information_schema.TRIGGERS WHERE TRIGGER_SCHEMA = \\? ORDER BY EVENT_OBJECT_TABLE, TRIGGER_NAME"
+	mock.ExpectQuery(query).WithArgs("db1").WillReturnRows(
+		sqlmock.NewRows([]string{"TRIGGER_NAME", "EVENT_OBJECT_TABLE", "EVENT_MANIPULATION", "ACTION_TIMING"}).
+			AddRow("trg1", "tbl1", "INSERT", "BEFORE").
+			AddRow("trg2", "tbl2", "DELETE", "AFTER"))
+	triggers, err := GetTriggers(context.Background(), baseDB, "db1")
+	require.NoError(t, err)
+	require.Equal(t, []TriggerInfo{
+		{Name: "trg1", Table: "tbl1", Event: "INSERT", Timing: "BEFORE"},
+		{Name: "trg2", Table: "tbl2", Event: "DELETE", Timing: "AFTER"},
+	}, triggers)
+
+	// no triggers.
+	mock.ExpectQuery(query).WithArgs("db2").WillReturnRows(
+		sqlmock.NewRows([]string{"TRIGGER_NAME", "EVENT_OBJECT_TABLE", "EVENT_MANIPULATION", "ACTION_TIMING"}))
+	triggers, err = GetTriggers(context.Background(), baseDB, "db2")
+	require.NoError(t, err)
+	require.Len(t, triggers, 0)
+
+	mock.ExpectQuery(query).WithArgs("db3").WillReturnError(errors.New("query failed"))
+	_, err = GetTriggers(context.Background(), baseDB, "db3")
+	require.True(t, terror.ErrDBDriverError.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -84,15 +84,22 @@ func getVariable(ctx *tcontext.Context, conn *BaseConn, variable string, isGloba
 		+---------------+-------+
 	*/
 
-	if !row.Next() {
-		return "", terror.WithScope(terror.ErrDBDriverError.Generatef("variable %s not found", variable), conn.Scope)
+	// Some proxies may return multiple rows, and `_` in LIKE pattern matches any
+	// character, so only the row with the exact variable name is used.
+	var name string
+	for row.Next() {
+		err = row.Scan(&name, &value)
+		if err != nil {
+			return "", terror.DBErrorAdapt(err, conn.Scope, terror.ErrDBDriverError)
+		}
+		if strings.EqualFold(name, variable) {
+			return value, nil
+		}
 	}
-
-	err = row.Scan(&variable, &value)
-	if err != nil {
+	if err = row.Err(); err != nil {
 		return "", terror.DBErrorAdapt(err, conn.Scope, terror.ErrDBDriverError)
 	}
-	return value, nil
+	return "", terror.WithScope(terror.ErrDBDriverError.Generatef("variable %s not found", variable), conn.Scope)
 }
 
 // GetMasterStatus gets status from master.
@@ -307,3 +314,18 @@ func FetchTableEstimatedBytes(ctx context.Context, db *BaseDB, schema string, ta
 	}
 	return size, nil
 }
+
+// QuoteName quotes the identifier with backticks, the embedded backticks are
+// escaped by doubling them.
+func QuoteName(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// QuoteSchema quotes the schema and table to `schema`.`table`. If table is
+// empty, only the quoted schema is returned.
+func QuoteSchema(schema, table string) string {
+	if table == "" {
+		return QuoteName(schema)
+	}
+	return QuoteName(schema) + "." + QuoteName(table)
+}
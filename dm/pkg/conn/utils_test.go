@@ -21,6 +21,7 @@ import (
 	gmysql "github.com/go-mysql-org/go-mysql/mysql"
 	tcontext "github.com/pingcap/tiflow/dm/pkg/context"
 	"github.com/pingcap/tiflow/dm/pkg/log"
+	"github.com/pingcap/tiflow/dm/pkg/terror"
 	"github.com/stretchr/testify/require"
 )
 
@@ -136,3 +137,60 @@ func TestGetMasterStatus(t *testing.T) {
 		require.NoError(t, mock.ExpectationsWereMet())
 	}
 }
+
+func TestGetVariableWithMultipleRows(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDBTimeout)
+	defer cancel()
+	tctx := tcontext.NewContext(ctx, log.L())
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	baseDB := NewBaseDBForTest(db)
+
+	// a proxy returns extra rows, the first row matching the name case-insensitively is used.
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'server_id'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).
+			AddRow("server_id_bits", "32").
+			AddRow("SERVER_ID", "123").
+			AddRow("server_id", "456"))
+	value, err := GetGlobalVariable(tctx, baseDB, "server_id")
+	require.NoError(t, err)
+	require.Equal(t, "123", value)
+
+	// no exact match.
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'server_id'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}).
+			AddRow("server_id_bits", "32").
+			AddRow("serverXid", "123"))
+	_, err = GetGlobalVariable(tctx, baseDB, "server_id")
+	require.True(t, terror.ErrDBDriverError.Equal(err))
+	require.ErrorContains(t, err, "variable server_id not found")
+
+	// no rows.
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES LIKE 'server_id'`).WillReturnRows(
+		mock.NewRows([]string{"Variable_name", "Value"}))
+	_, err = GetGlobalVariable(tctx, baseDB, "server_id")
+	require.True(t, terror.ErrDBDriverError.Equal(err))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQuoteName(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		schema, table string
+		name, full    string
+	}{
+		{"db", "tbl", "`db`", "`db`.`tbl`"},
+		{"db", "", "`db`", "`db`"},
+		{"d`b", "t``bl", "`d``b`", "`d``b`.`t````bl`"},
+		{"db.1", "tbl.2", "`db.1`", "`db.1`.`tbl.2`"},
+		{"`db`", "`.`", "```db```", "```db```.```.```"},
+	}
+	for _, ca := range cases {
+		require.Equal(t, ca.name, QuoteName(ca.schema))
+		require.Equal(t, ca.full, QuoteSchema(ca.schema, ca.table))
+	}
+}
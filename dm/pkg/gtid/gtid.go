@@ -14,6 +14,9 @@
 package gtid
 
 import (
+	"encoding/json"
+	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/go-mysql-org/go-mysql/mysql"
@@ -26,8 +29,17 @@ var (
 )
 
 // CheckGTIDSetEmpty is used to check whether a GTID set is zero.
+// Both untyped nil and typed nil GTID sets are regarded as empty.
 func CheckGTIDSetEmpty(gSet mysql.GTIDSet) bool {
-	return gSet == nil || gSet.Equal(emptyMySQLGTIDSet) || gSet.Equal(emptyMariaDBGTIDSet)
+	switch s := gSet.(type) {
+	case nil:
+		return true
+	case *mysql.MysqlGTIDSet:
+		return s == nil || len(s.Sets) == 0
+	case *mysql.MariadbGTIDSet:
+		return s == nil || len(s.Sets) == 0
+	}
+	return gSet.Equal(emptyMySQLGTIDSet) || gSet.Equal(emptyMariaDBGTIDSet)
 }
 
 // ParserGTID parses GTID from string. If the flavor is not specified, it will
@@ -121,3 +133,238 @@ func IsZeroMariaDBGTIDSet(gStr string) bool {
 	interval := strings.TrimSpace(sep[2])
 	return interval == "0"
 }
+
+// gtidSetJSON is the JSON form of a GTID set with its flavor.
+type gtidSetJSON struct {
+	Flavor  string `json:"flavor"`
+	GTIDSet string `json:"gtid-set"`
+}
+
+// MarshalGTIDSet marshals a GTID set to JSON, the flavor is embedded so that
+// UnmarshalGTIDSet can restore the GTID set without knowing the flavor.
+func MarshalGTIDSet(gSet mysql.GTIDSet) ([]byte, error) {
+	flavor, err := gtidSetFlavor(gSet)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(gtidSetJSON{Flavor: flavor, GTIDSet: gSet.String()})
+	if err != nil {
+		return nil, terror.ErrParseGTID.Delegate(err, gSet.String())
+	}
+	return data, nil
+}
+
+// GTIDSetEqual checks whether two GTID sets contain the same GTIDs, the order
+// of intervals in their string forms doesn't matter. Empty GTID sets are equal
+// regardless of the flavor, otherwise GTID sets of different flavors can't be
+// compared.
+func GTIDSetEqual(a, b mysql.GTIDSet) (bool, error) {
+	aEmpty, bEmpty := CheckGTIDSetEmpty(a), CheckGTIDSetEmpty(b)
+	if aEmpty || bEmpty {
+		return aEmpty == bEmpty, nil
+	}
+	if err := checkSameFlavor(a, b); err != nil {
+		return false, err
+	}
+	return a.Contain(b) && b.Contain(a), nil
+}
+
+// checkSameFlavor checks whether two non-empty GTID sets are of the same flavor.
+func checkSameFlavor(a, b mysql.GTIDSet) error {
+	aFlavor, err := gtidSetFlavor(a)
+	if err != nil {
+		return err
+	}
+	bFlavor, err := gtidSetFlavor(b)
+	if err != nil {
+		return err
+	}
+	if aFlavor != bFlavor {
+		return terror.ErrNotSupportedFlavor.Generate(fmt.Sprintf("%s compared with %s", aFlavor, bFlavor))
+	}
+	return nil
+}
+
+func gtidSetFlavor(gSet mysql.GTIDSet) (string, error) {
+	switch gSet.(type) {
+	case *mysql.MysqlGTIDSet:
+		return mysql.MySQLFlavor, nil
+	case *mysql.MariadbGTIDSet:
+		return mysql.MariaDBFlavor, nil
+	default:
+		return "", terror.ErrNotSupportedFlavor.Generate(fmt.Sprintf("%T", gSet))
+	}
+}
+
+// DetectForeignGTIDs returns the UUID sets in upstream whose UUIDs are absent in
+// checkpoint, sorted by UUID. They usually come from another server after a
+// failover of upstream. For MariaDB, GTIDs of domains absent in checkpoint are
+// returned.
+func DetectForeignGTIDs(checkpoint, upstream mysql.GTIDSet) ([]string, error) {
+	if CheckGTIDSetEmpty(upstream) {
+		return nil, nil
+	}
+	if !CheckGTIDSetEmpty(checkpoint) {
+		if err := checkSameFlavor(checkpoint, upstream); err != nil {
+			return nil, err
+		}
+	}
+
+	var foreign []string
+	switch upstreamSet := upstream.(type) {
+	case *mysql.MysqlGTIDSet:
+		checkpointSet, _ := checkpoint.(*mysql.MysqlGTIDSet)
+		for sid, uuidSet := range upstreamSet.Sets {
+			if checkpointSet != nil {
+				if _, ok := checkpointSet.Sets[sid]; ok {
+					continue
+				}
+			}
+			foreign = append(foreign, uuidSet.String())
+		}
+	case *mysql.MariadbGTIDSet:
+		checkpointSet, _ := checkpoint.(*mysql.MariadbGTIDSet)
+		for domainID, mariaDBGTID := range upstreamSet.Sets {
+			if checkpointSet != nil {
+				if _, ok := checkpointSet.Sets[domainID]; ok {
+					continue
+				}
+			}
+			foreign = append(foreign, mariaDBGTID.String())
+		}
+	default:
+		return nil, terror.ErrNotSupportedFlavor.Generate(fmt.Sprintf("%T", upstream))
+	}
+	sort.Strings(foreign)
+	return foreign, nil
+}
+
+// MissingPurgedGTIDs returns the GTIDs in purged which are not covered by
+// checkpoint. Replication from checkpoint fails if they are purged from the
+// upstream, unless they are added to checkpoint by AddGSetWithPurged. For MariaDB,
+// GTIDs of domains whose sequence numbers in checkpoint are less than the ones
+// in purged are returned. nil is returned if checkpoint covers purged.
+func MissingPurgedGTIDs(checkpoint, purged mysql.GTIDSet) (mysql.GTIDSet, error) {
+	if CheckGTIDSetEmpty(purged) {
+		return nil, nil
+	}
+	if !CheckGTIDSetEmpty(checkpoint) {
+		if err := checkSameFlavor(checkpoint, purged); err != nil {
+			return nil, err
+		}
+	}
+
+	switch purgedSet := purged.(type) {
+	case *mysql.MysqlGTIDSet:
+		missing := purgedSet.Clone().(*mysql.MysqlGTIDSet)
+		if checkpointSet, ok := checkpoint.(*mysql.MysqlGTIDSet); ok && checkpointSet != nil {
+			_ = missing.Minus(*checkpointSet)
+		}
+		for sid, uuidSet := range missing.Sets {
+			if len(uuidSet.Intervals) == 0 {
+				delete(missing.Sets, sid)
+			}
+		}
+		if len(missing.Sets) == 0 {
+			return nil, nil
+		}
+		return missing, nil
+	case *mysql.MariadbGTIDSet:
+		checkpointSet, _ := checkpoint.(*mysql.MariadbGTIDSet)
+		missing := &mysql.MariadbGTIDSet{Sets: make(map[uint32]*mysql.MariadbGTID)}
+		for domainID, mariaDBGTID := range purgedSet.Sets {
+			if checkpointSet != nil {
+				if covered, ok := checkpointSet.Sets[domainID]; ok && covered.SequenceNumber >= mariaDBGTID.SequenceNumber {
+					continue
+				}
+			}
+			missing.Sets[domainID] = mariaDBGTID.Clone()
+		}
+		if len(missing.Sets) == 0 {
+			return nil, nil
+		}
+		return missing, nil
+	default:
+		return nil, terror.ErrNotSupportedFlavor.Generate(fmt.Sprintf("%T", purged))
+	}
+}
+
+// LogMaxIntervals is the max number of intervals of a GTID set written in logs.
+const LogMaxIntervals = 16
+
+// GTIDSetSummary returns the string of a GTID set which is truncated to the
+// first maxIntervals intervals, followed by an ellipsis and the total count of
+// intervals. For MariaDB, each domain is regarded as an interval. If maxIntervals
+// is not positive or the GTID set doesn't have more intervals, the whole string
+// is returned.
+func GTIDSetSummary(gset mysql.GTIDSet, maxIntervals int) string {
+	if CheckGTIDSetEmpty(gset) {
+		return ""
+	}
+	str := gset.String()
+	if maxIntervals <= 0 {
+		return str
+	}
+	_, isMySQL := gset.(*mysql.MysqlGTIDSet)
+
+	var (
+		kept  = make([]string, 0, maxIntervals)
+		total = 0
+	)
+	for _, set := range strings.Split(str, ",") {
+		if !isMySQL {
+			if total < maxIntervals {
+				kept = append(kept, set)
+			}
+			total++
+			continue
+		}
+		// MySQL GTID set is like `uuid:1-5:7-10`.
+		items := strings.Split(set, ":")
+		intervals := items[1:]
+		if remain := maxIntervals - total; remain > 0 {
+			if len(intervals) > remain {
+				kept = append(kept, strings.Join(items[:remain+1], ":"))
+			} else {
+				kept = append(kept, set)
+			}
+		}
+		total += len(intervals)
+	}
+	if total <= maxIntervals {
+		return str
+	}
+	return fmt.Sprintf("%s...(%d intervals in total)", strings.Join(kept, ","), total)
+}
+
+// ValidateGTIDSetCrossParser checks whether the GTID set string can be parsed,
+// and the string of the parsed GTID set is stable when it's parsed again. GTID
+// sets are stored and exchanged in string forms, so an unstable one may be
+// regarded as a different GTID set after it's persisted.
+func ValidateGTIDSetCrossParser(flavor, s string) error {
+	gSet, err := ParserGTID(flavor, s)
+	if err != nil {
+		return terror.ErrParseGTID.Delegate(err, s)
+	}
+	str := gSet.String()
+	reparsed, err := ParserGTID(flavor, str)
+	if err != nil {
+		return terror.ErrParseGTID.Delegate(err, str)
+	}
+	if reparsed.String() != str || !reparsed.Equal(gSet) {
+		return terror.ErrParseGTID.Generate(fmt.Sprintf("%s, which is unstable after re-parsing %s as %s", s, str, reparsed.String()))
+	}
+	return nil
+}
+
+// UnmarshalGTIDSet unmarshals a GTID set from the JSON generated by MarshalGTIDSet.
+func UnmarshalGTIDSet(data []byte) (mysql.GTIDSet, error) {
+	var j gtidSetJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, terror.ErrParseGTID.Delegate(err, string(data))
+	}
+	if j.Flavor != mysql.MySQLFlavor && j.Flavor != mysql.MariaDBFlavor {
+		return nil, terror.ErrNotSupportedFlavor.Generate(j.Flavor)
+	}
+	return ParserGTID(j.Flavor, j.GTIDSet)
+}
@@ -14,9 +14,12 @@
 package gtid
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/pingcap/tiflow/dm/pkg/terror"
 	"github.com/stretchr/testify/require"
 )
 
@@ -152,4 +155,365 @@ func TestCheckGTIDSetEmpty(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, testCase.isEmpty, CheckGTIDSetEmpty(gset))
 	}
+
+	// nil and typed nil GTID sets
+	require.True(t, CheckGTIDSetEmpty(nil))
+	var mysqlGSet *mysql.MysqlGTIDSet
+	require.True(t, CheckGTIDSetEmpty(mysqlGSet))
+	var mariaDBGSet *mysql.MariadbGTIDSet
+	require.True(t, CheckGTIDSetEmpty(mariaDBGSet))
+	require.True(t, CheckGTIDSetEmpty(&mysql.MysqlGTIDSet{}))
+	require.True(t, CheckGTIDSetEmpty(&mysql.MariadbGTIDSet{}))
+}
+
+func TestMarshalGTIDSet(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		flavor  string
+		gsetStr string
+	}{
+		{mysql.MySQLFlavor, ""},
+		{mysql.MySQLFlavor, "3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14"},
+		{mysql.MySQLFlavor, "3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14,406a3f61-690d-11e7-87c5-6c92bf46f384:1-94321383"},
+		{mysql.MariaDBFlavor, ""},
+		{mysql.MariaDBFlavor, "1-1-1"},
+		{mysql.MariaDBFlavor, "1-1-1,2-2-2"},
+	}
+
+	for _, tc := range testCases {
+		gset, err := ParserGTID(tc.flavor, tc.gsetStr)
+		require.NoError(t, err)
+		data, err := MarshalGTIDSet(gset)
+		require.NoError(t, err)
+		require.Contains(t, string(data), tc.flavor)
+
+		gset2, err := UnmarshalGTIDSet(data)
+		require.NoError(t, err)
+		require.IsType(t, gset, gset2)
+		require.True(t, gset.Equal(gset2), "flavor: %s, gset: %s", tc.flavor, tc.gsetStr)
+	}
+
+	// nil GTID set
+	_, err := MarshalGTIDSet(nil)
+	require.True(t, terror.ErrNotSupportedFlavor.Equal(err))
+	// invalid JSON
+	_, err = UnmarshalGTIDSet([]byte("3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14"))
+	require.True(t, terror.ErrParseGTID.Equal(err))
+	// unknown flavor
+	_, err = UnmarshalGTIDSet([]byte(`{"flavor":"oracle","gtid-set":""}`))
+	require.True(t, terror.ErrNotSupportedFlavor.Equal(err))
+	// flavor mismatch
+	_, err = UnmarshalGTIDSet([]byte(`{"flavor":"mariadb","gtid-set":"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14"}`))
+	require.Error(t, err)
+}
+
+func TestGTIDSetEqual(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		flavor string
+		a      string
+		b      string
+		equal  bool
+	}{
+		{mysql.MySQLFlavor, "", "", true},
+		{mysql.MySQLFlavor, "3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14", "3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14", true},
+		{mysql.MySQLFlavor, "3ccc475b-2343-11e7-be21-6c0b84d59f30:1-5:6-14", "3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14", true},
+		{mysql.MySQLFlavor, "3ccc475b-2343-11e7-be21-6c0b84d59f30:10-14:1-5", "3ccc475b-2343-11e7-be21-6c0b84d59f30:1-5:10-14", true},
+		{
+			mysql.MySQLFlavor,
+			"406a3f61-690d-11e7-87c5-6c92bf46f384:1-94321383,3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14",
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14,406a3f61-690d-11e7-87c5-6c92bf46f384:1-94321383",
+			true,
+		},
+		{mysql.MySQLFlavor, "3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14", "3ccc475b-2343-11e7-be21-6c0b84d59f30:1-15", false},
+		{mysql.MySQLFlavor, "3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14", "", false},
+		{mysql.MariaDBFlavor, "", "", true},
+		{mysql.MariaDBFlavor, "1-1-1,2-2-2", "2-2-2,1-1-1", true},
+		{mysql.MariaDBFlavor, "1-1-1,2-2-2", "1-1-1", false},
+	}
+
+	for _, tc := range testCases {
+		a, err := ParserGTID(tc.flavor, tc.a)
+		require.NoError(t, err)
+		b, err := ParserGTID(tc.flavor, tc.b)
+		require.NoError(t, err)
+		equal, err := GTIDSetEqual(a, b)
+		require.NoError(t, err)
+		require.Equal(t, tc.equal, equal, "a: %s, b: %s", tc.a, tc.b)
+		equal, err = GTIDSetEqual(b, a)
+		require.NoError(t, err)
+		require.Equal(t, tc.equal, equal, "a: %s, b: %s", tc.b, tc.a)
+	}
+
+	// empty GTID sets of different flavors and nil GTID sets.
+	equal, err := GTIDSetEqual(MustZeroGTIDSet(mysql.MySQLFlavor), MustZeroGTIDSet(mysql.MariaDBFlavor))
+	require.NoError(t, err)
+	require.True(t, equal)
+	equal, err = GTIDSetEqual(nil, MustZeroGTIDSet(mysql.MySQLFlavor))
+	require.NoError(t, err)
+	require.True(t, equal)
+
+	// different flavors.
+	mysqlGSet, err := ParserGTID(mysql.MySQLFlavor, "3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14")
+	require.NoError(t, err)
+	mariaDBGSet, err := ParserGTID(mysql.MariaDBFlavor, "1-1-1")
+	require.NoError(t, err)
+	_, err = GTIDSetEqual(mysqlGSet, mariaDBGSet)
+	require.True(t, terror.ErrNotSupportedFlavor.Equal(err))
+}
+
+func TestDetectForeignGTIDs(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		flavor     string
+		checkpoint string
+		upstream   string
+		foreign    []string
+	}{
+		// no failover.
+		{
+			mysql.MySQLFlavor,
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14",
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-20",
+			nil,
+		},
+		// failover to a replica which has replicated all GTIDs, and the new primary writes with its own UUID.
+		{
+			mysql.MySQLFlavor,
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14",
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14,406a3f61-690d-11e7-87c5-6c92bf46f384:1-5",
+			[]string{"406a3f61-690d-11e7-87c5-6c92bf46f384:1-5"},
+		},
+		// failover to a server which has local transactions of several UUIDs.
+		{
+			mysql.MySQLFlavor,
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14",
+			"53bfca22-690d-11e7-8a62-18ded7a37b78:1-495,3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14,406a3f61-690d-11e7-87c5-6c92bf46f384:1-5:7-9",
+			[]string{"406a3f61-690d-11e7-87c5-6c92bf46f384:1-5:7-9", "53bfca22-690d-11e7-8a62-18ded7a37b78:1-495"},
+		},
+		// a UUID in the checkpoint is purged from the upstream.
+		{
+			mysql.MySQLFlavor,
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14,406a3f61-690d-11e7-87c5-6c92bf46f384:1-5",
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-20",
+			nil,
+		},
+		// empty checkpoint.
+		{
+			mysql.MySQLFlavor,
+			"",
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-20",
+			[]string{"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-20"},
+		},
+		{mysql.MySQLFlavor, "3ccc475b-2343-11e7-be21-6c0b84d59f30:1-20", "", nil},
+		{mysql.MariaDBFlavor, "1-1-10", "1-2-15", nil},
+		{mysql.MariaDBFlavor, "1-1-10", "1-1-12,2-2-3", []string{"2-2-3"}},
+	}
+
+	for _, tc := range testCases {
+		checkpoint, err := ParserGTID(tc.flavor, tc.checkpoint)
+		require.NoError(t, err)
+		upstream, err := ParserGTID(tc.flavor, tc.upstream)
+		require.NoError(t, err)
+		foreign, err := DetectForeignGTIDs(checkpoint, upstream)
+		require.NoError(t, err)
+		require.Equal(t, tc.foreign, foreign, "checkpoint: %s, upstream: %s", tc.checkpoint, tc.upstream)
+	}
+
+	// nil checkpoint.
+	upstream, err := ParserGTID(mysql.MySQLFlavor, "3ccc475b-2343-11e7-be21-6c0b84d59f30:1-20")
+	require.NoError(t, err)
+	foreign, err := DetectForeignGTIDs(nil, upstream)
+	require.NoError(t, err)
+	require.Equal(t, []string{"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-20"}, foreign)
+
+	// different flavors.
+	checkpoint, err := ParserGTID(mysql.MariaDBFlavor, "1-1-1")
+	require.NoError(t, err)
+	_, err = DetectForeignGTIDs(checkpoint, upstream)
+	require.True(t, terror.ErrNotSupportedFlavor.Equal(err))
+}
+
+func TestMissingPurgedGTIDs(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		flavor     string
+		checkpoint string
+		purged     string
+		missing    string
+	}{
+		// covered.
+		{
+			mysql.MySQLFlavor,
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14",
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-10",
+			"",
+		},
+		{mysql.MySQLFlavor, "3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14", "", ""},
+		// uncovered intervals.
+		{
+			mysql.MySQLFlavor,
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14",
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-20",
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:15-20",
+		},
+		// uncovered UUIDs.
+		{
+			mysql.MySQLFlavor,
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14",
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14,406a3f61-690d-11e7-87c5-6c92bf46f384:1-5",
+			"406a3f61-690d-11e7-87c5-6c92bf46f384:1-5",
+		},
+		// empty checkpoint.
+		{
+			mysql.MySQLFlavor,
+			"",
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-20",
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-20",
+		},
+		{mysql.MariaDBFlavor, "1-1-10", "1-1-5", ""},
+		{mysql.MariaDBFlavor, "1-1-10", "1-1-12,2-2-3", "1-1-12,2-2-3"},
+		{mysql.MariaDBFlavor, "1-1-10,2-2-3", "1-1-9,2-2-5", "2-2-5"},
+	}
+
+	for _, tc := range testCases {
+		checkpoint, err := ParserGTID(tc.flavor, tc.checkpoint)
+		require.NoError(t, err)
+		purged, err := ParserGTID(tc.flavor, tc.purged)
+		require.NoError(t, err)
+		missing, err := MissingPurgedGTIDs(checkpoint, purged)
+		require.NoError(t, err)
+		if tc.missing == "" {
+			require.Nil(t, missing, "checkpoint: %s, purged: %s", tc.checkpoint, tc.purged)
+			continue
+		}
+		expected, err := ParserGTID(tc.flavor, tc.missing)
+		require.NoError(t, err)
+		equal, err := GTIDSetEqual(expected, missing)
+		require.NoError(t, err)
+		require.True(t, equal, "checkpoint: %s, purged: %s, missing: %s", tc.checkpoint, tc.purged, missing)
+		// purged is not modified.
+		require.Equal(t, tc.purged, purged.String())
+	}
+
+	// nil checkpoint.
+	purged, err := ParserGTID(mysql.MySQLFlavor, "3ccc475b-2343-11e7-be21-6c0b84d59f30:1-20")
+	require.NoError(t, err)
+	missing, err := MissingPurgedGTIDs(nil, purged)
+	require.NoError(t, err)
+	require.Equal(t, "3ccc475b-2343-11e7-be21-6c0b84d59f30:1-20", missing.String())
+
+	// different flavors.
+	checkpoint, err := ParserGTID(mysql.MariaDBFlavor, "1-1-1")
+	require.NoError(t, err)
+	_, err = MissingPurgedGTIDs(checkpoint, purged)
+	require.True(t, terror.ErrNotSupportedFlavor.Equal(err))
+}
+
+func TestGTIDSetSummary(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		flavor       string
+		gsetStr      string
+		maxIntervals int
+		summary      string
+	}{
+		{mysql.MySQLFlavor, "", 2, ""},
+		{
+			mysql.MySQLFlavor,
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14",
+			2,
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14",
+		},
+		{
+			mysql.MySQLFlavor,
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14:20-30,406a3f61-690d-11e7-87c5-6c92bf46f384:1-94321383",
+			3,
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14:20-30,406a3f61-690d-11e7-87c5-6c92bf46f384:1-94321383",
+		},
+		{
+			mysql.MySQLFlavor,
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14:20-30:40-50,406a3f61-690d-11e7-87c5-6c92bf46f384:1-94321383",
+			2,
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14:20-30...(4 intervals in total)",
+		},
+		{
+			mysql.MySQLFlavor,
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14,406a3f61-690d-11e7-87c5-6c92bf46f384:1-94321383:94321385-94321390",
+			2,
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14,406a3f61-690d-11e7-87c5-6c92bf46f384:1-94321383...(3 intervals in total)",
+		},
+		{
+			mysql.MySQLFlavor,
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14:20-30:40-50",
+			0,
+			"3ccc475b-2343-11e7-be21-6c0b84d59f30:1-14:20-30:40-50",
+		},
+		{mysql.MariaDBFlavor, "1-1-1,2-2-2", 2, "1-1-1,2-2-2"},
+		{mysql.MariaDBFlavor, "1-1-1,2-2-2,3-3-3", 2, "1-1-1,2-2-2...(3 intervals in total)"},
+	}
+	for _, tc := range testCases {
+		gset, err := ParserGTID(tc.flavor, tc.gsetStr)
+		require.NoError(t, err)
+		require.Equal(t, tc.summary, GTIDSetSummary(gset, tc.maxIntervals), tc.gsetStr)
+	}
+	require.Equal(t, "", GTIDSetSummary(nil, 2))
+
+	// a large GTID set.
+	var sb strings.Builder
+	sb.WriteString("3ccc475b-2343-11e7-be21-6c0b84d59f30")
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&sb, ":%d-%d", i*10+1, i*10+5)
+	}
+	gset, err := ParserGTID(mysql.MySQLFlavor, sb.String())
+	require.NoError(t, err)
+	summary := GTIDSetSummary(gset, LogMaxIntervals)
+	require.True(t, strings.HasPrefix(summary, "3ccc475b-2343-11e7-be21-6c0b84d59f30:1-5:11-15:"), summary)
+	require.True(t, strings.HasSuffix(summary, "...(1000 intervals in total)"), summary)
+	require.Less(t, len(summary), 300)
+}
+
+func TestValidateGTIDSetCrossParser(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		flavor string
+		gtid   string
+		valid  bool
+	}{
+		// overlapped and adjacent intervals are merged.
+		{mysql.MySQLFlavor, "3ccc475b-2343-11e7-be21-6c0b84d59f30:1-5:3-10", true},
+		{mysql.MySQLFlavor, "3CCC475B-2343-11E7-BE21-6C0B84D59F30:1-5:6-10", true},
+		// the same UUID appears twice.
+		{mysql.MySQLFlavor, "3ccc475b-2343-11e7-be21-6c0b84d59f30:1-5,3ccc475b-2343-11e7-be21-6c0b84d59f30:3-8", true},
+		{mysql.MySQLFlavor, " 3ccc475b-2343-11e7-be21-6c0b84d59f30:1-5 , 406a3f61-690d-11e7-87c5-6c92bf46f384:7", true},
+		{mysql.MySQLFlavor, "3ccc475b-2343-11e7-be21-6c0b84d59f30:0", true},
+		{mysql.MySQLFlavor, "", true},
+		{mysql.MySQLFlavor, "3ccc475b-2343-11e7-be21-6c0b84d59f30:5-1", false},
+		{mysql.MySQLFlavor, "3ccc475b-2343-11e7-be21-6c0b84d59f30:1-", false},
+		{mysql.MySQLFlavor, "3ccc475b-2343-11e7-be21-6c0b84d59f30:1-5:", false},
+		{mysql.MySQLFlavor, "3ccc475b-2343-11e7-be21-6c0b84d59f30:1-5,", false},
+		{mysql.MySQLFlavor, "3ccc475b-2343-11e7-be21-6c0b84d59f30", false},
+		// the same domain appears twice.
+		{mysql.MariaDBFlavor, "1-1-5,1-2-6", true},
+		{mysql.MariaDBFlavor, "1-1-5,2-2-6", true},
+		{mysql.MariaDBFlavor, "0-0-0", true},
+		{mysql.MariaDBFlavor, "1-1", false},
+	}
+	for _, tc := range testCases {
+		err := ValidateGTIDSetCrossParser(tc.flavor, tc.gtid)
+		if tc.valid {
+			require.NoError(t, err, tc.gtid)
+		} else {
+			require.True(t, terror.ErrParseGTID.Equal(err), tc.gtid)
+		}
+	}
+
+	err := ValidateGTIDSetCrossParser("unknown", "1-1-1")
+	require.True(t, terror.ErrParseGTID.Equal(err))
 }
@@ -201,6 +201,10 @@ const (
 
 	// pkg/utils.
 	codeIncorrectReturnColumnsNum
+
+	// pkg/conn.
+	codeTooManyDoTables
+	codeDBVersionTooLow
 )
 
 // Config related error code list.
@@ -907,6 +911,10 @@ var (
 	ErrNoMasterStatus            = New(codeNoMasterStatus, ClassFunctional, ScopeUpstream, LevelMedium, "upstream returns an empty result for SHOW MASTER STATUS", "Please make sure binlog is enabled, and check the upstream settings like privileges, RDS settings to read data from SHOW MASTER STATUS.")
 	ErrIncorrectReturnColumnsNum = New(codeIncorrectReturnColumnsNum, ClassFunctional, ScopeUpstream, LevelMedium, "upstream returns incorrect number of columns for SHOW MASTER STATUS", "Please check the upstream settings like privileges, RDS settings to read data from SHOW MASTER STATUS.")
 
+	// pkg/conn.
+	ErrTooManyDoTables = New(codeTooManyDoTables, ClassFunctional, ScopeUpstream, LevelHigh, "the number of tables to sync exceeds the limit, fetched at least %d tables, limit %d", "Please check `block-allow-list` config in task configuration file to reduce the tables to sync.")
+	ErrDBVersionTooLow = New(codeDBVersionTooLow, ClassFunctional, ScopeNotSet, LevelHigh, "the version %s of the %s server is lower than the minimum required version %s", "Please upgrade the database server.")
+
 	// pkg/binlog.
 	ErrBinlogNotLogColumn = New(codeBinlogNotLogColumn, ClassBinlogOp, ScopeUpstream, LevelHigh, "upstream didn't log enough columns in binlog", "Please check if session `binlog_row_image` variable is not FULL, restart task to the location from where FULL binlog_row_image is used.")
 
@@ -31,6 +31,7 @@ import (
 	"github.com/pingcap/tiflow/dm/pkg/binlog/event"
 	"github.com/pingcap/tiflow/dm/pkg/binlog/reader"
 	tcontext "github.com/pingcap/tiflow/dm/pkg/context"
+	"github.com/pingcap/tiflow/dm/pkg/gtid"
 	"github.com/pingcap/tiflow/dm/pkg/log"
 	"github.com/pingcap/tiflow/dm/pkg/terror"
 	"github.com/pingcap/tiflow/dm/pkg/utils"
@@ -255,7 +256,7 @@ func (r *BinlogReader) StartSyncByPos(pos mysql.Position) (reader.Streamer, erro
 
 // StartSyncByGTID start sync by gtid.
 func (r *BinlogReader) StartSyncByGTID(gset mysql.GTIDSet) (reader.Streamer, error) {
-	r.tctx.L().Info("begin to sync binlog", zap.Stringer("GTID Set", gset))
+	r.tctx.L().Info("begin to sync binlog", zap.String("GTID Set", gtid.GTIDSetSummary(gset, gtid.LogMaxIntervals)))
 	r.usingGTID = true
 
 	if r.running {
@@ -270,7 +271,7 @@ func (r *BinlogReader) StartSyncByGTID(gset mysql.GTIDSet) (reader.Streamer, err
 	if err != nil {
 		return nil, err
 	}
-	r.tctx.L().Info("get pos by gtid", zap.Stringer("GTID Set", gset), zap.Stringer("Position", pos))
+	r.tctx.L().Info("get pos by gtid", zap.String("GTID Set", gtid.GTIDSetSummary(gset, gtid.LogMaxIntervals)), zap.Stringer("Position", pos))
 
 	r.prevGset = gset
 	r.currGset = nil
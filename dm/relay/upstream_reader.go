@@ -21,6 +21,7 @@ import (
 	"github.com/go-mysql-org/go-mysql/replication"
 	"github.com/pingcap/tiflow/dm/pkg/binlog/common"
 	"github.com/pingcap/tiflow/dm/pkg/binlog/reader"
+	"github.com/pingcap/tiflow/dm/pkg/gtid"
 	"github.com/pingcap/tiflow/dm/pkg/log"
 	"github.com/pingcap/tiflow/dm/pkg/terror"
 	"go.uber.org/zap"
@@ -142,7 +143,7 @@ func (r *upstreamReader) GetEvent(ctx context.Context) (RResult, error) {
 
 func (r *upstreamReader) setUpReaderByGTID() error {
 	gs := r.cfg.GTIDs
-	r.logger.Info("start sync", zap.String("master", r.cfg.MasterID), zap.Stringer("from GTID set", gs))
+	r.logger.Info("start sync", zap.String("master", r.cfg.MasterID), zap.String("from GTID set", gtid.GTIDSetSummary(gs, gtid.LogMaxIntervals)))
 	return r.in.StartSyncByGTID(gs)
 }
 
@@ -53,6 +53,9 @@ func CloseBaseDB(logCtx *tcontext.Context, baseDB *conn.BaseDB) {
 type DBConn struct {
 	cfg      *config.SubTaskConfig
 	baseConn *conn.BaseConn
+	// lockWaitTimeout is `innodb_lock_wait_timeout` of the connection, zero
+	// means unknown.
+	lockWaitTimeout time.Duration
 
 	// generate new BaseConn and close old one
 	ResetBaseConnFn func(*tcontext.Context, *conn.BaseConn) (*conn.BaseConn, error)
@@ -164,7 +167,7 @@ func (conn *DBConn) ExecuteSQLWithIgnore(
 	// nolint:dupl
 	params := retry.Params{
 		RetryCount:         100,
-		FirstRetryDuration: retryTimeout,
+		FirstRetryDuration: conn.retryDuration(),
 		BackoffStrategy:    retry.Stable,
 		IsRetryableFn:      conn.retryableFn(tctx, queries, args),
 	}
@@ -239,6 +242,16 @@ func (conn *DBConn) ExecuteSQLAutoSplit(
 	return conn.baseConn.ExecuteSQLsAutoSplit(tctx, m, conn.cfg.Name, queries, args...)
 }
 
+// retryDuration returns how long to wait before retrying statements. A failed
+// statement may have waited `innodb_lock_wait_timeout` for row locks already, so
+// don't wait longer than it if a short one is configured in downstream.
+func (conn *DBConn) retryDuration() time.Duration {
+	if conn.lockWaitTimeout > 0 && conn.lockWaitTimeout < retryTimeout {
+		return conn.lockWaitTimeout
+	}
+	return retryTimeout
+}
+
 func (conn *DBConn) retryableFn(tctx *tcontext.Context, queries, args any) func(int, error) bool {
 	return func(retryTime int, err error) bool {
 		if retry.IsConnectionError(err) {
@@ -265,6 +278,20 @@ func (conn *DBConn) retryableFn(tctx *tcontext.Context, queries, args any) func(
 	}
 }
 
+// warmUpConn raises too short session timeouts of the long-lived connection and
+// caches its session variables, a failure is only logged because the connection
+// is still usable. The network timeouts are raised too if the connection is used
+// to stream large result sets. It returns `innodb_lock_wait_timeout` of the
+// connection, zero is returned if it's unknown.
+func warmUpConn(tctx *tcontext.Context, baseConn *conn.BaseConn, streaming bool) time.Duration {
+	lockWaitTimeout, err := conn.WarmUpConn(tctx, baseConn, streaming)
+	if err != nil {
+		tctx.L().Warn("failed to warm up connection", log.ShortError(err))
+		return 0
+	}
+	return time.Duration(lockWaitTimeout) * time.Second
+}
+
 // CreateConns returns a opened DB from dbCfg and number of `count` connections of that DB.
 func CreateConns(tctx *tcontext.Context, cfg *config.SubTaskConfig, dbCfg conn.ScopedDBConfig, count int, ioCounter *atomic.Uint64, uuid string) (*conn.BaseDB, []*DBConn, error) {
 	if ioCounter != nil {
@@ -286,6 +313,8 @@ func CreateConns(tctx *tcontext.Context, cfg *config.SubTaskConfig, dbCfg conn.S
 		dbCfg.Net = uuid
 	}
 
+	// Upstream connections read large result sets, e.g. the dumped data.
+	streaming := dbCfg.Scope == terror.ScopeUpstream
 	conns := make([]*DBConn, 0, count)
 	baseDB, err := conn.DefaultDBProvider.Apply(dbCfg)
 	if err != nil {
@@ -297,14 +326,26 @@ func CreateConns(tctx *tcontext.Context, cfg *config.SubTaskConfig, dbCfg conn.S
 			CloseBaseDB(tctx, baseDB)
 			return nil, nil, terror.WithScope(err, terror.ScopeDownstream)
 		}
-		resetBaseConnFn := func(tctx *tcontext.Context, baseConn *conn.BaseConn) (*conn.BaseConn, error) {
+		dbConn := &DBConn{
+			baseConn:        baseConn,
+			cfg:             cfg,
+			lockWaitTimeout: warmUpConn(tctx, baseConn, streaming),
+		}
+		dbConn.ResetBaseConnFn = func(tctx *tcontext.Context, baseConn *conn.BaseConn) (*conn.BaseConn, error) {
 			err := baseDB.ForceCloseConn(baseConn)
 			if err != nil {
 				tctx.L().Warn("failed to close BaseConn in reset")
 			}
-			return baseDB.GetBaseConn(tctx.Context())
+			newConn, err := baseDB.GetBaseConn(tctx.Context())
+			if err != nil {
+				return nil, err
+			}
+			// The new connection may get another `innodb_lock_wait_timeout`
+			// after the global one is changed.
+			dbConn.lockWaitTimeout = warmUpConn(tctx, newConn, streaming)
+			return newConn, nil
 		}
-		conns = append(conns, &DBConn{baseConn: baseConn, cfg: cfg, ResetBaseConnFn: resetBaseConnFn})
+		conns = append(conns, dbConn)
 	}
 	return baseDB, conns, nil
 }
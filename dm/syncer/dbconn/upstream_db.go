@@ -109,14 +109,14 @@ func (c *UpStreamConn) GetParser(ctx context.Context) (*parser.Parser, error) {
 	return conn.GetParser(tcontext.NewContext(ctx, log.L()), c.BaseDB)
 }
 
-// KillConn kills a connection in upstream.
-func (c *UpStreamConn) KillConn(ctx context.Context, connID uint32) error {
-	return conn.KillConn(tcontext.NewContext(ctx, log.L()), c.BaseDB, connID)
+// KillConn kills a connection in upstream, except the protected one.
+func (c *UpStreamConn) KillConn(ctx context.Context, connID, protectedID uint32) error {
+	return conn.KillConn(tcontext.NewContext(ctx, log.L()), c.BaseDB, connID, protectedID, false)
 }
 
 // FetchAllDoTables returns tables matches allow-list.
 func (c *UpStreamConn) FetchAllDoTables(ctx context.Context, bw *filter.Filter) (map[string][]string, error) {
-	return conn.FetchAllDoTables(ctx, c.BaseDB, bw)
+	return conn.FetchAllDoTables(ctx, c.BaseDB, bw, 0)
 }
 
 // CloseUpstreamConn closes the UpStreamConn.
@@ -74,7 +74,6 @@ import (
 	"fmt"
 	"sync"
 
-	"github.com/pingcap/tidb/util/dbutil"
 	"github.com/pingcap/tidb/util/filter"
 	"github.com/pingcap/tiflow/dm/config"
 	"github.com/pingcap/tiflow/dm/config/dbconfig"
@@ -403,7 +402,7 @@ func NewShardingGroupKeeper(
 	}
 	k.shardMetaSchema = cfg.MetaSchema
 	k.shardMetaTable = cputil.SyncerShardMeta(cfg.Name)
-	k.shardMetaTableName = dbutil.TableName(k.shardMetaSchema, k.shardMetaTable)
+	k.shardMetaTableName = conn.QuoteSchema(k.shardMetaSchema, k.shardMetaTable)
 	return k
 }
 
@@ -699,7 +698,7 @@ func (k *ShardingGroupKeeper) Close() {
 }
 
 func (k *ShardingGroupKeeper) createSchema() error {
-	stmt := fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS `%s`", k.shardMetaSchema)
+	stmt := fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", conn.QuoteName(k.shardMetaSchema))
 	_, err := k.dbConn.ExecuteSQL(k.tctx, k.metricProxies, []string{stmt})
 	k.tctx.L().Info("execute sql", zap.String("statement", stmt))
 	return terror.WithScope(err, terror.ScopeDownstream)
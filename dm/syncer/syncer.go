@@ -444,7 +444,7 @@ func (s *Syncer) Init(ctx context.Context) (err error) {
 	var tableMap map[string]map[string]string
 	if s.SourceTableNamesFlavor == conn.LCTableNamesSensitive {
 		// TODO: we should avoid call this function multi times
-		allTables, err1 := conn.FetchAllDoTables(ctx, s.fromDB.BaseDB, s.baList)
+		allTables, err1 := conn.FetchAllDoTables(ctx, s.fromDB.BaseDB, s.baList, 0)
 		if err1 != nil {
 			return err1
 		}
@@ -3555,7 +3555,7 @@ func (s *Syncer) adjustGlobalPointGTID(tctx *tcontext.Context) (bool, error) {
 	err = location.SetGTID(gs)
 	if err != nil {
 		s.tctx.L().Warn("fail to set gtid for global location", zap.Stringer("pos", location),
-			zap.String("adjusted_gtid", gs.String()), zap.Error(err))
+			zap.String("adjusted_gtid", gtid.GTIDSetSummary(gs, gtid.LogMaxIntervals)), zap.Error(err))
 		return false, err
 	}
 	s.saveGlobalPoint(location)
@@ -3563,7 +3563,7 @@ func (s *Syncer) adjustGlobalPointGTID(tctx *tcontext.Context) (bool, error) {
 	err = s.streamerController.ResetReplicationSyncer(tctx, location)
 	if err != nil {
 		s.tctx.L().Warn("fail to redirect streamer for global location", zap.Stringer("pos", location),
-			zap.String("adjusted_gtid", gs.String()), zap.Error(err))
+			zap.String("adjusted_gtid", gtid.GTIDSetSummary(gs, gtid.LogMaxIntervals)), zap.Error(err))
 		return false, err
 	}
 	return true, nil
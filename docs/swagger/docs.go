@@ -1793,6 +1793,10 @@ var doc = `{
                         "$ref": "#/definitions/config.DispatchRule"
                     }
                 },
+                "emit-retry-limit": {
+                    "description": "EmitRetryLimit is how many times to retry emitting events to a table sink\nif the error is transient. Zero means never retry.",
+                    "type": "integer"
+                },
                 "enable-kafka-sink-v2": {
                     "description": "EnableKafkaSinkV2 enabled then the kafka-go sink will be used.\nIt is only available when the downstream is MQ.",
                     "type": "boolean"
@@ -1815,6 +1819,10 @@ var doc = `{
                 "mysql-config": {
                     "$ref": "#/definitions/config.MySQLConfig"
                 },
+                "non-split-txn-size-limit": {
+                    "description": "NonSplitTxnSizeLimit is the hard limit of buffered bytes of one transaction\nif transactions are not allowed to be split. A larger transaction fails the\ntable sink task with ErrSinkTxnTooLarge. Zero means no limit.",
+                    "type": "integer"
+                },
                 "only-output-updated-columns": {
                     "description": "OnlyOutputUpdatedColumns is only available when the downstream is MQ.",
                     "type": "boolean"
@@ -2990,6 +2998,9 @@ var doc = `{
                         "$ref": "#/definitions/v2.DispatchRule"
                     }
                 },
+                "emit_retry_limit": {
+                    "type": "integer"
+                },
                 "enable_kafka_sink_v2": {
                     "type": "boolean"
                 },
@@ -3008,6 +3019,9 @@ var doc = `{
                 "mysql_config": {
                     "$ref": "#/definitions/v2.MySQLConfig"
                 },
+                "non_split_txn_size_limit": {
+                    "type": "integer"
+                },
                 "only_output_updated_columns": {
                     "type": "boolean"
                 },
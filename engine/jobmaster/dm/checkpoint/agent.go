@@ -205,7 +205,7 @@ func (c *AgentImpl) FetchAllDoTables(ctx context.Context, cfg *config.JobCfg) (m
 			defer db.Close()
 
 			// fetch all do tables
-			sourceTables, err := conn.FetchAllDoTables(gCtx, db, baList)
+			sourceTables, err := conn.FetchAllDoTables(gCtx, db, baList, 0)
 			if err != nil {
 				return errors.Trace(err)
 			}
@@ -35,6 +35,8 @@ const (
 	DefaultMaxMessageBytes = 10 * 1024 * 1024 // 10M
 	// DefaultAdvanceTimeoutInSec sets the default value for advance-timeout-in-sec.
 	DefaultAdvanceTimeoutInSec = uint(150)
+	// DefaultEmitRetryLimit sets the default value for emit-retry-limit.
+	DefaultEmitRetryLimit = uint(3)
 
 	// TxnAtomicityKey specifies the key of the transaction-atomicity in the SinkURI.
 	TxnAtomicityKey = "transaction-atomicity"
@@ -165,6 +167,15 @@ type SinkConfig struct {
 	// AdvanceTimeoutInSec is a duration in second. If a table sink progress hasn't been
 	// advanced for this given duration, the sink will be canceled and re-established.
 	AdvanceTimeoutInSec *uint `toml:"advance-timeout-in-sec" json:"advance-timeout-in-sec,omitempty"`
+
+	// EmitRetryLimit is how many times to retry emitting events to a table sink
+	// if the error is transient. Zero means never retry.
+	EmitRetryLimit *uint `toml:"emit-retry-limit" json:"emit-retry-limit,omitempty"`
+
+	// NonSplitTxnSizeLimit is the hard limit of buffered bytes of one transaction
+	// if transactions are not allowed to be split. A larger transaction fails the
+	// table sink task with ErrSinkTxnTooLarge. Zero means no limit.
+	NonSplitTxnSizeLimit *uint64 `toml:"non-split-txn-size-limit" json:"non-split-txn-size-limit,omitempty"`
 }
 
 // MaskSensitiveData masks sensitive data in SinkConfig
@@ -396,6 +396,10 @@ var (
 		"sink config invalid",
 		errors.RFCCodeText("CDC:ErrSinkInvalidConfig"),
 	)
+	ErrSinkTxnTooLarge = errors.Normalize(
+		"transaction size %d exceeds the limit %d of a non-split transaction",
+		errors.RFCCodeText("CDC:ErrSinkTxnTooLarge"),
+	)
 	ErrCraftCodecInvalidData = errors.Normalize(
 		"craft codec invalid data",
 		errors.RFCCodeText("CDC:ErrCraftCodecInvalidData"),